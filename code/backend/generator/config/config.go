@@ -6,10 +6,15 @@ import (
 	"strings"
 )
 
+// minServiceKeySecretLength is the shortest service key secret the backend server accepts
+const minServiceKeySecretLength = 32
+
 // Config holds application configuration values
 type Config struct {
 	GeneratorServerPort   string // Port for the generator server to listen on
 	BackendServerEndpoint string // Endpoint URL for the backend server
+	ServiceKeyID          string // ID of the key signing requests to the backend server
+	ServiceKeySecret      string // Secret of the key signing requests to the backend server
 }
 
 // LoadConfig initializes and returns a Config struct, populated with environment variables or defaults
@@ -17,6 +22,8 @@ func LoadConfig() (*Config, error) {
 	config := &Config{
 		GeneratorServerPort:   getEnv("GENERATOR_SERVER_PORT", "8080"),
 		BackendServerEndpoint: ensureNoTrailingSlash(getEnv("BACKEND_SERVER_ENDPOINT", "http://localhost")),
+		ServiceKeyID:          getEnv("SERVICE_KEY_ID", ""),
+		ServiceKeySecret:      getEnv("SERVICE_KEY_SECRET", ""),
 	}
 
 	// Ensure BackendServerEndpoint is set
@@ -24,6 +31,14 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("backend server endpoint is not set in environment variables")
 	}
 
+	// Ensure requests to the backend server can be signed, which rejects unsigned ones with 401
+	if config.ServiceKeyID == "" {
+		return nil, fmt.Errorf("SERVICE_KEY_ID is not set in environment variables")
+	}
+	if len(config.ServiceKeySecret) < minServiceKeySecretLength {
+		return nil, fmt.Errorf("SERVICE_KEY_SECRET must be set to a secret of at least %d characters", minServiceKeySecretLength)
+	}
+
 	return config, nil
 }
 
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/xzz8868/titansoft-pre-test/code/backend/generator/models"
 	"github.com/xzz8868/titansoft-pre-test/code/backend/generator/services"
 
 	"github.com/labstack/echo/v4"
@@ -48,55 +49,68 @@ func (cc *customerController) GenerateAndSendCustomerData(ctx echo.Context) erro
 	var generateDuration time.Duration
 	var sendDuration time.Duration
 
-	// Loop to attempt data generation and sending until successful or retry limit reached
-	for {
-		generateStartTime := time.Now()
-		log.Println("Starting generation of customer data")
-		// Generate customer data using the service interface
-		customers, err := cc.customerService.GenerateCustomerData(num)
-		if err != nil {
-			log.Printf("Error generating customer data: %v", err)
-			return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate customer data"})
-		}
-		generateDuration += time.Since(generateStartTime)
+	generateStartTime := time.Now()
+	log.Println("Starting generation of customer data")
+	// Generate customer data using the service interface
+	customers, err := cc.customerService.GenerateCustomerData(num)
+	if err != nil {
+		log.Printf("Error generating customer data: %v", err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate customer data"})
+	}
+	generateDuration += time.Since(generateStartTime)
 
+	// Loop to send customer data until no email collisions remain or retry limit reached
+	for {
 		sendAPIStartTime := time.Now()
 		log.Println("Starting API call to send customer data")
 		// Send customer data to the backend server using the service interface
-		_, failedCount, err := cc.customerService.CreateMultiCustomersAPICall(customers)
+		result, err := cc.customerService.CreateMultiCustomersAPICall(customers)
 		if err != nil {
 			log.Printf("Error during API call to backend: %v", err)
 			return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to send customer data to backend: %s", err)})
 		}
 		sendDuration += time.Since(sendAPIStartTime)
 
-		log.Printf("API call complete with %d failures", failedCount)
-		// Check if there is a decrease in failures or if failures are constant
-		if failedCount > 0 {
-			if failedCount == num {
-				// Increment counter if failure count remains constant
-				sameFailureCounter++
-				log.Printf("Failure count remained constant at %d, retry %d", failedCount, sameFailureCounter)
-				if sameFailureCounter >= 5 {
-					// Stop retries after 5 consecutive constant failures
-					log.Println("Persistent failures reached, stopping retries")
-					return ctx.JSON(http.StatusInternalServerError, map[string]string{
-						"error":  "Persistent failures, stopping retries",
-						"failed": strconv.Itoa(failedCount),
-					})
-				}
-			} else {
-				// Reset counter if failure count decreases
-				sameFailureCounter = 0
-				log.Println("Failure count decreased, resetting sameFailureCounter")
+		log.Printf("API call complete with %d failures", result.FailCount)
+		// Only rows that collided on email can succeed when resent with a new identity;
+		// any other rejection would fail again with the same data
+		generateStartTime := time.Now()
+		var retries []models.CustomerDTO
+		for _, item := range result.Results {
+			if item.Reason == models.ReasonDuplicateEmail {
+				retries = append(retries, cc.customerService.RenewIdentity(customers[item.Index]))
+			} else if item.Reason != "" {
+				log.Printf("Customer %d rejected: %s (%s)", item.Index, item.Reason, item.Message)
+			}
+		}
+		generateDuration += time.Since(generateStartTime)
+
+		if len(retries) == 0 {
+			// Break loop if there are no email collisions left
+			log.Println("No email collisions, breaking loop")
+			break
+		}
+
+		// Check if there is a decrease in collisions or if collisions are constant
+		if len(retries) == len(customers) {
+			// Increment counter if collision count remains constant
+			sameFailureCounter++
+			log.Printf("Collision count remained constant at %d, retry %d", len(retries), sameFailureCounter)
+			if sameFailureCounter >= 5 {
+				// Stop retries after 5 consecutive constant failures
+				log.Println("Persistent failures reached, stopping retries")
+				return ctx.JSON(http.StatusInternalServerError, map[string]string{
+					"error":  "Persistent failures, stopping retries",
+					"failed": strconv.Itoa(len(retries)),
+				})
 			}
-			// Set num to failedCount for the next iteration
-			num = failedCount
 		} else {
-			// Break loop if there are no failures
-			log.Println("No failures, breaking loop")
-			break
+			// Reset counter if collision count decreases
+			sameFailureCounter = 0
+			log.Println("Collision count decreased, resetting sameFailureCounter")
 		}
+		// Resend only the collided customers in the next iteration
+		customers = retries
 	}
 
 	// Return success response with generation and sending durations
@@ -1,6 +1,10 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Gender string
 
@@ -11,9 +15,29 @@ const (
 )
 
 type CustomerDTO struct {
-	ID       uuid.UUID `json:"id"`
-	Name     string    `json:"name"`
-	Password string    `json:"password"`
-	Email    string    `json:"email"`
-	Gender   Gender    `json:"gender"`
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Password  string    `json:"password"`
+	Email     string    `json:"email"`
+	Gender    Gender    `json:"gender"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CustomerItemResult is the backend's outcome for one customer of a bulk request
+type CustomerItemResult struct {
+	Index   int       `json:"index"`
+	ID      uuid.UUID `json:"id"`
+	Status  string    `json:"status"`
+	Reason  string    `json:"reason"`
+	Message string    `json:"message"`
 }
+
+// CustomerBatchResult is the backend's report for a bulk customer insert
+type CustomerBatchResult struct {
+	SuccessCount int                  `json:"successCount"`
+	FailCount    int                  `json:"failCount"`
+	Results      []CustomerItemResult `json:"results"`
+}
+
+// ReasonDuplicateEmail is the reject reason the backend reports for an email collision
+const ReasonDuplicateEmail = "duplicate_email"
@@ -8,7 +8,18 @@ import (
 
 type TransactionDTO struct {
 	CustomerID uuid.UUID `json:"customer_id"`
-	Amount     float64   `json:"amount"`
+	Amount     string    `json:"amount"` // decimal string with two places, e.g. "1234.50"
 	Time       time.Time `json:"time"`
 	CreatedAt  time.Time `json:"created_at"`
 }
+
+// TransactionBatchResult is the backend's report for a bulk transaction insert
+type TransactionBatchResult struct {
+	Accepted   int `json:"accepted"`
+	Rejected   int `json:"rejected"`
+	Rejections []struct {
+		Index   int    `json:"index"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	} `json:"rejections"`
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/generator/config"
+)
+
+// Retry policy of batches sent to the backend server
+const (
+	sendAttempts   = 4
+	sendRetryDelay = time.Second // multiplied by the attempt number
+)
+
+// postBatch posts a batch to the backend server, retrying network errors, 5xx responses and 409 responses
+// (an earlier attempt still in progress). Every attempt carries the same Idempotency-Key, so the backend
+// server stores the batch once even when an attempt succeeded but its response was lost.
+func postBatch(url string, body []byte, cfg *config.Config) (*http.Response, error) {
+	idempotencyKey := uuid.NewString()
+	client := &http.Client{}
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+		// Each attempt is signed anew so its timestamp stays within the backend server's allowed skew
+		signBackendRequest(req, body, cfg)
+
+		resp, err := client.Do(req)
+		if err == nil {
+			if resp.StatusCode != http.StatusConflict && resp.StatusCode < http.StatusInternalServerError {
+				return resp, nil
+			}
+			resp.Body.Close()
+			err = fmt.Errorf("backend server responded with status: %d", resp.StatusCode)
+		}
+		if attempt == sendAttempts {
+			return nil, err
+		}
+		log.Printf("Attempt %d of %d to send batch failed: %v", attempt, sendAttempts, err)
+		time.Sleep(time.Duration(attempt) * sendRetryDelay)
+	}
+}
@@ -1,12 +1,12 @@
 package services
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
+	"time"
 
 	"github.com/xzz8868/titansoft-pre-test/code/backend/generator/config"
 	"github.com/xzz8868/titansoft-pre-test/code/backend/generator/models"
@@ -17,7 +17,8 @@ const str = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 // CustomerService defines the interface for customer-related operations
 type CustomerService interface {
 	GenerateCustomerData(num int) ([]models.CustomerDTO, error)
-	CreateMultiCustomersAPICall(customers []models.CustomerDTO) (int, int, error)
+	CreateMultiCustomersAPICall(customers []models.CustomerDTO) (*models.CustomerBatchResult, error)
+	RenewIdentity(customer models.CustomerDTO) models.CustomerDTO
 }
 
 // customerService is the concrete implementation of CustomerService
@@ -40,10 +41,11 @@ func (cs *customerService) GenerateCustomerData(num int) ([]models.CustomerDTO,
 	for i := 0; i < num; i++ {
 		name := cs.generateRandomName()
 		customer := models.CustomerDTO{
-			Name:     name,
-			Password: cs.generateRandomPassword(),
-			Email:    cs.generateRandomEmail(name),
-			Gender:   cs.randomGender(),
+			Name:      name,
+			Password:  cs.generateRandomPassword(),
+			Email:     cs.generateRandomEmail(name),
+			Gender:    cs.randomGender(),
+			CreatedAt: cs.randomRegistrationTime(),
 		}
 		customers = append(customers, customer)
 	}
@@ -52,60 +54,52 @@ func (cs *customerService) GenerateCustomerData(num int) ([]models.CustomerDTO,
 }
 
 // CreateMultiCustomersAPICall sends a batch of customer data to the backend API
-func (cs *customerService) CreateMultiCustomersAPICall(customers []models.CustomerDTO) (int, int, error) {
-    successCount := 0
-    failCount := 0
-
-    // Serialize customer data to JSON
-    customersJSON, err := json.Marshal(customers)
-    if err != nil {
-        log.Printf("JSON marshalling error: %v", err)
-        return successCount, failCount, err
-    }
-
-    // Construct the API request
-    url := fmt.Sprintf("%s/customers/multi", cs.cfg.BackendServerEndpoint)
-    req, err := http.NewRequest("POST", url, bytes.NewBuffer(customersJSON))
-    if err != nil {
-        log.Printf("Request creation error: %v", err)
-        return successCount, failCount, err
-    }
-    req.Header.Set("Content-Type", "application/json")
-
-    // Execute the HTTP request
-    client := &http.Client{}
-    resp, err := client.Do(req)
-    if err != nil {
-        log.Printf("HTTP request error: %v", err)
-        return successCount, failCount, err
-    }
-    defer resp.Body.Close()
-
-    // Handle response based on status code
-    if resp.StatusCode == http.StatusCreated {
-        var result map[string]int
-        if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-            log.Printf("JSON decoding error: %v", err)
-            return successCount, failCount, err
-        }
-        successCount = result["successCount"]
-        failCount = result["failCount"]
-    } else {
-        log.Printf("HTTP response status error: %d", resp.StatusCode)
-        return successCount, failCount, fmt.Errorf("failed to create customers with status code: %d", resp.StatusCode)
-    }
-
-    log.Printf("API calls completed with %d successes and %d failures", successCount, failCount)
-    return successCount, failCount, nil
+func (cs *customerService) CreateMultiCustomersAPICall(customers []models.CustomerDTO) (*models.CustomerBatchResult, error) {
+	// Serialize customer data to JSON
+	customersJSON, err := json.Marshal(customers)
+	if err != nil {
+		log.Printf("JSON marshalling error: %v", err)
+		return nil, err
+	}
+
+	// Send the batch, retrying transient failures with the same Idempotency-Key
+	url := fmt.Sprintf("%s/customers/multi", cs.cfg.BackendServerEndpoint)
+	resp, err := postBatch(url, customersJSON, cs.cfg)
+	if err != nil {
+		log.Printf("HTTP request error: %v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Handle response based on status code
+	if resp.StatusCode != http.StatusCreated {
+		log.Printf("HTTP response status error: %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to create customers with status code: %d", resp.StatusCode)
+	}
+
+	var result models.CustomerBatchResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Printf("JSON decoding error: %v", err)
+		return nil, err
+	}
+
+	log.Printf("API calls completed with %d successes and %d failures", result.SuccessCount, result.FailCount)
+	return &result, nil
 }
 
+// RenewIdentity gives a customer a new random name and email, keeping the rest of its data,
+// so it can be resent after its email collided with an existing customer
+func (cs *customerService) RenewIdentity(customer models.CustomerDTO) models.CustomerDTO {
+	customer.Name = cs.generateRandomName()
+	customer.Email = cs.generateRandomEmail(customer.Name)
+	return customer
+}
 
 // generateRandomName generates a random 8-character string as a name
 func (cs *customerService) generateRandomName() string {
 	return generateRandomString(8)
 }
 
-
 // generateRandomPassword generates a random 16-character password
 func (cs *customerService) generateRandomPassword() string {
 	return generateRandomString(16)
@@ -129,6 +123,13 @@ func (cs *customerService) generateRandomEmail(name string) string {
 	return email
 }
 
+// randomRegistrationTime picks a registration time within the transaction period,
+// so that generated transactions have room to fall after it
+func (cs *customerService) randomRegistrationTime() time.Time {
+	now := time.Now()
+	return randomTimeBetween(now.AddDate(0, -transactionPeriodMonths, 0), now)
+}
+
 // randomGender randomly selects a gender from predefined options
 func (cs *customerService) randomGender() models.Gender {
 	genders := []models.Gender{models.Male, models.Female, models.Other}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/generator/config"
+)
+
+// serviceAuthVector is a signed request shared with the backend server's tests, which verify the same
+// Authorization headers with its ServiceAuthService
+type serviceAuthVector struct {
+	Name           string `json:"name"`
+	KeyID          string `json:"key_id"`
+	Secret         string `json:"secret"`
+	Timestamp      int64  `json:"timestamp"`
+	Method         string `json:"method"`
+	URI            string `json:"uri"`
+	IdempotencyKey string `json:"idempotency_key"`
+	Body           string `json:"body"`
+	Authorization  string `json:"authorization"`
+}
+
+func TestSignBackendRequestMatchesServerVectors(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/service_auth_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []serviceAuthVector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) == 0 {
+		t.Fatal("no service auth vectors")
+	}
+
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			req, err := http.NewRequest(v.Method, "http://backend.example"+v.URI, strings.NewReader(v.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.IdempotencyKey != "" {
+				req.Header.Set("Idempotency-Key", v.IdempotencyKey)
+			}
+			cfg := &config.Config{ServiceKeyID: v.KeyID, ServiceKeySecret: v.Secret}
+			var body []byte
+			if v.Body != "" {
+				body = []byte(v.Body)
+			}
+
+			signBackendRequestAt(req, body, cfg, time.Unix(v.Timestamp, 0))
+			if got := req.Header.Get("Authorization"); got != v.Authorization {
+				t.Errorf("Authorization = %q, want %q", got, v.Authorization)
+			}
+		})
+	}
+}
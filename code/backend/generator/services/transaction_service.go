@@ -10,11 +10,13 @@ import (
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/xzz8868/titansoft-pre-test/code/backend/generator/config"
 	"github.com/xzz8868/titansoft-pre-test/code/backend/generator/models"
 )
 
+// transactionPeriodMonths is how far back generated transactions may go
+const transactionPeriodMonths = 18
+
 // TransactionService defines the interface for transaction-related operations
 type TransactionService interface {
 	GenerateAndSendTransactions(numTransactions int, numCustomers int) error
@@ -32,18 +34,18 @@ func NewTransactionService(cfg *config.Config) TransactionService {
 
 // GenerateAndSendTransactions generates transaction data and sends it to the backend server
 func (ts *transactionService) GenerateAndSendTransactions(numTransactions int, numCustomers int) error {
-	// Step 1: Retrieve customer IDs
-	customerIDs, err := ts.getCustomerIDs(numCustomers)
+	// Step 1: Retrieve customers
+	customers, err := ts.getCustomers(numCustomers)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve customer IDs: %w", err)
+		return fmt.Errorf("failed to retrieve customers: %w", err)
 	}
 
-	if len(customerIDs) == 0 {
+	if len(customers) == 0 {
 		return fmt.Errorf("no customer data")
 	}
 
 	// Step 2: Generate transactions
-	transactions := ts.generateTransactions(numTransactions, customerIDs)
+	transactions := ts.generateTransactions(numTransactions, customers)
 
 	// Step 3: Send transactions to backend
 	if err := ts.sendTransactions(transactions); err != nil {
@@ -53,8 +55,8 @@ func (ts *transactionService) GenerateAndSendTransactions(numTransactions int, n
 	return nil
 }
 
-// getCustomerIDs retrieves customer IDs from the backend server
-func (ts *transactionService) getCustomerIDs(numCustomers int) ([]uuid.UUID, error) {
+// getCustomers retrieves customers and their registration times from the backend server
+func (ts *transactionService) getCustomers(numCustomers int) ([]models.CustomerDTO, error) {
 	// Construct the API request
 	url := fmt.Sprintf("%s/customers/limit/%d", ts.cfg.BackendServerEndpoint, numCustomers)
 	req, err := http.NewRequest("GET", url, nil)
@@ -62,6 +64,7 @@ func (ts *transactionService) getCustomerIDs(numCustomers int) ([]uuid.UUID, err
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	signBackendRequest(req, nil, ts.cfg)
 
 	// Execute the HTTP request
 	client := &http.Client{}
@@ -82,19 +85,13 @@ func (ts *transactionService) getCustomerIDs(numCustomers int) ([]uuid.UUID, err
 		return nil, err
 	}
 
-	// Extract customer ID
-	customerIDs := make([]uuid.UUID, len(customers))
-	for i, customer := range customers {
-		customerIDs[i] = customer.ID
-	}
-
-	return customerIDs, nil
+	return customers, nil
 }
 
 // generateTransactions creates a list of random transactions
-func (ts *transactionService) generateTransactions(numTransactions int, customerIDs []uuid.UUID) []models.TransactionDTO {
+func (ts *transactionService) generateTransactions(numTransactions int, customers []models.CustomerDTO) []models.TransactionDTO {
 	transactions := make([]models.TransactionDTO, numTransactions)
-	customerCount := len(customerIDs)
+	customerCount := len(customers)
 	var wg sync.WaitGroup
 	wg.Add(numTransactions)
 
@@ -102,10 +99,11 @@ func (ts *transactionService) generateTransactions(numTransactions int, customer
 	for i := 0; i < numTransactions; i++ {
 		go func(i int) {
 			defer wg.Done()
+			customer := customers[rand.Intn(customerCount)]
 			transactions[i] = models.TransactionDTO{
-				CustomerID: customerIDs[rand.Intn(customerCount)],
-				Amount:     rand.Float64() * 1000000, // Random amount up to $1000000
-				Time:       ts.randomTimeWithinMonths(18),
+				CustomerID: customer.ID,
+				Amount:     randomAmount(100000000), // Random amount up to $1000000
+				Time:       ts.randomTimeWithinMonths(transactionPeriodMonths, customer.CreatedAt),
 			}
 		}(i)
 	}
@@ -122,37 +120,63 @@ func (ts *transactionService) sendTransactions(transactions []models.Transaction
 		return err
 	}
 
-	// Create POST request with transaction data
+	// Post the batch; the backend records the generator service as the actor of the transactions in the audit trail
 	url := fmt.Sprintf("%s/transactions/multi", ts.cfg.BackendServerEndpoint)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(transactionsJSON))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	// Execute the HTTP request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := postBatch(url, transactionsJSON, ts.cfg)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	// Handle response based on status code
-	if resp.StatusCode != http.StatusCreated {
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusUnprocessableEntity {
 		return fmt.Errorf("backend server responded with status: %d", resp.StatusCode)
 	}
 
-	log.Println("Transactions successfully sent to backend server")
+	var result models.TransactionBatchResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Rejected > 0 {
+		first := result.Rejections[0]
+		return fmt.Errorf("backend server rejected %d transactions, first at index %d: %s (%s)",
+			result.Rejected, first.Index, first.Reason, first.Message)
+	}
+
+	log.Printf("%d transactions successfully sent to backend server", result.Accepted)
 	return nil
 }
 
-// randomTimeWithinMonths generates a random time within the past specified months
-func (ts *transactionService) randomTimeWithinMonths(months int) time.Time {
+// randomAmount generates a random positive amount of at most maxCents cents,
+// formatted as a decimal string so no precision is lost in transit
+func randomAmount(maxCents int64) string {
+	cents := rand.Int63n(maxCents) + 1
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
+
+// randomTimeWithinMonths generates a random time within the past specified months,
+// but never before the customer's registration
+func (ts *transactionService) randomTimeWithinMonths(months int, registeredAt time.Time) time.Time {
 	now := time.Now()
 	past := now.AddDate(0, -months, 0)
-	delta := now.Unix() - past.Unix()
-	sec := rand.Int63n(delta) + past.Unix()
+	if registeredAt.After(past) {
+		past = registeredAt
+	}
+	return randomTimeBetween(past, now)
+}
+
+// randomTimeBetween generates a random time, at second precision, in [from, to]
+func randomTimeBetween(from, to time.Time) time.Time {
+	// Round up so the result never falls before from
+	start := from.Truncate(time.Second)
+	if start.Before(from) {
+		start = start.Add(time.Second)
+	}
+	delta := to.Unix() - start.Unix()
+	if delta <= 0 {
+		return start
+	}
+	sec := rand.Int63n(delta+1) + start.Unix()
 	return time.Unix(sec, 0)
 }
 
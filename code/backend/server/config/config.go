@@ -3,6 +3,16 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notifiers selectable with NOTIFIER
+const (
+	NotifierLog      = "log"
+	NotifierFile     = "file"
+	NotifierDisabled = "disabled"
 )
 
 // Config holds the application configuration values.
@@ -13,7 +23,44 @@ type Config struct {
 	DBPort     string
 	DBName     string
 	ServerPort string
-	Salt       string
+	// Salt is the global salt of password hashes stored before per-password salts, kept to verify them
+	Salt string
+	// PasswordHashAlgorithm is the algorithm of new password hashes, argon2id or scrypt
+	PasswordHashAlgorithm string
+	// PasswordPepper is a secret mixed into new password hashes besides their salt; empty disables it
+	PasswordPepper string
+	// PasswordResetTTL is how long a password reset link can be used
+	PasswordResetTTL time.Duration
+	// PasswordResetURL is the page password reset links point to
+	PasswordResetURL string
+	// Notifier selects how notifications such as reset links are delivered: "log" or "file", which are
+	// for development only, or "disabled", which turns password resets off
+	Notifier string
+	// NotifierFile is the file the "file" notifier appends notifications to
+	NotifierFile string
+	// ImportMaxBytes is the largest upload accepted by the bulk import endpoint
+	ImportMaxBytes int64
+	// RFMInterval is how often RFM scores are recomputed in the background; zero disables it
+	RFMInterval time.Duration
+	// RFMWindow names the aggregation window scheduled RFM recomputations cover
+	RFMWindow string
+	// AuthSecret signs the access and refresh tokens issued to customers and operators
+	AuthSecret string
+	// LedgerSecret keys the hash chains of the transaction ledgers; changing it requires rekeying them
+	LedgerSecret string
+	// ServiceKeys are the HMAC keys, by key ID, that services sign their requests with. Two keys are
+	// configured while a key is being rotated.
+	ServiceKeys map[string]string
+	// ServiceAuthMaxSkew is how far the timestamp of a signed request may be from the server's clock
+	ServiceAuthMaxSkew time.Duration
+	// IdempotencyPendingTimeout is how long an Idempotency-Key stays claimed by a request that has not
+	// completed before it is assumed abandoned; it must exceed the longest request
+	IdempotencyPendingTimeout time.Duration
+	// IdempotencyTTL is how long responses are replayed for a repeated Idempotency-Key
+	IdempotencyTTL time.Duration
+	// AccessTokenTTL and RefreshTokenTTL are the lifetimes of access tokens and of idle sessions
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
 }
 
 // LoadConfig initializes the configuration with environment variables,
@@ -27,14 +74,81 @@ func LoadConfig() (*Config, error) {
 		DBName:     getEnv("DB_NAME", "pretest"),
 		ServerPort: getEnv("PORT", "8080"),
 		Salt:       getEnv("SALT", "default_salt_value"),
+		RFMWindow:  getEnv("RFM_WINDOW", "past_year"),
+
+		PasswordHashAlgorithm: getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		PasswordPepper:        getEnv("PASSWORD_PEPPER", ""),
+		PasswordResetURL:      getEnv("PASSWORD_RESET_URL", "http://localhost/reset_password.html"),
+		Notifier:              getEnv("NOTIFIER", ""),
+		NotifierFile:          getEnv("NOTIFIER_FILE", ""),
+	}
+
+	if config.PasswordHashAlgorithm != "argon2id" && config.PasswordHashAlgorithm != "scrypt" {
+		return nil, fmt.Errorf("invalid PASSWORD_HASH_ALGORITHM: %q, expected argon2id or scrypt", config.PasswordHashAlgorithm)
+	}
+
+	switch config.Notifier {
+	case NotifierLog, NotifierDisabled:
+	case NotifierFile:
+		if config.NotifierFile == "" {
+			return nil, fmt.Errorf("NOTIFIER_FILE must be set when NOTIFIER is %s", NotifierFile)
+		}
+	default:
+		return nil, fmt.Errorf("invalid NOTIFIER: %q, expected %s or %s for development, or %s",
+			config.Notifier, NotifierLog, NotifierFile, NotifierDisabled)
+	}
+
+	maxBytes, err := strconv.ParseInt(getEnv("IMPORT_MAX_BYTES", "104857600"), 10, 64)
+	if err != nil || maxBytes <= 0 {
+		return nil, fmt.Errorf("invalid IMPORT_MAX_BYTES: %q", os.Getenv("IMPORT_MAX_BYTES"))
+	}
+	config.ImportMaxBytes = maxBytes
+
+	interval, err := time.ParseDuration(getEnv("RFM_INTERVAL", "24h"))
+	if err != nil || interval < 0 {
+		return nil, fmt.Errorf("invalid RFM_INTERVAL: %q", os.Getenv("RFM_INTERVAL"))
+	}
+	config.RFMInterval = interval
+
+	if config.AccessTokenTTL, err = getPositiveDuration("ACCESS_TOKEN_TTL", "15m"); err != nil {
+		return nil, err
+	}
+	if config.RefreshTokenTTL, err = getPositiveDuration("REFRESH_TOKEN_TTL", "720h"); err != nil {
+		return nil, err
+	}
+	if config.PasswordResetTTL, err = getPositiveDuration("PASSWORD_RESET_TTL", "30m"); err != nil {
+		return nil, err
+	}
+	if config.ServiceAuthMaxSkew, err = getPositiveDuration("SERVICE_AUTH_MAX_SKEW", "5m"); err != nil {
+		return nil, err
+	}
+	if config.IdempotencyPendingTimeout, err = getPositiveDuration("IDEMPOTENCY_PENDING_TIMEOUT", "10m"); err != nil {
+		return nil, err
+	}
+	if config.IdempotencyTTL, err = getPositiveDuration("IDEMPOTENCY_TTL", "24h"); err != nil {
+		return nil, err
+	}
+	if config.IdempotencyTTL < config.IdempotencyPendingTimeout {
+		return nil, fmt.Errorf("IDEMPOTENCY_TTL must not be shorter than IDEMPOTENCY_PENDING_TIMEOUT")
+	}
+	if config.AuthSecret, err = getSecret("AUTH_SECRET"); err != nil {
+		return nil, err
+	}
+	if config.LedgerSecret, err = getSecret("LEDGER_SECRET"); err != nil {
+		return nil, err
+	}
+	if config.ServiceKeys, err = parseServiceKeys(getEnv("SERVICE_KEYS", "")); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
 
 // GetDSN constructs the Data Source Name (DSN) for database connection.
+// Both the driver and the session work in UTC so that results do not depend on the zone
+// the server or the database runs in.
 func (c *Config) GetDSN() string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=UTC&time_zone=%%27%%2B00%%3A00%%27",
 		c.DBUser, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
 }
 
@@ -46,3 +160,49 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getPositiveDuration parses an environment variable as a positive duration, falling back to a
+// default value if the variable is not set.
+func getPositiveDuration(key, defaultValue string) (time.Duration, error) {
+	d, err := time.ParseDuration(getEnv(key, defaultValue))
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, os.Getenv(key))
+	}
+	return d, nil
+}
+
+// minSecretLength is the minimum length of the secrets the server signs and keys hashes with,
+// including the secrets of service keys
+const minSecretLength = 32
+
+// getSecret reads a required secret from an environment variable
+func getSecret(key string) (string, error) {
+	secret := os.Getenv(key)
+	if len(secret) < minSecretLength {
+		return "", fmt.Errorf("%s must be set to a secret of at least %d characters", key, minSecretLength)
+	}
+	return secret, nil
+}
+
+// parseServiceKeys parses a comma-separated list of <key ID>:<secret> pairs
+func parseServiceKeys(value string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid SERVICE_KEYS: expected <key ID>:<secret> pairs")
+		}
+		if len(secret) < minSecretLength {
+			return nil, fmt.Errorf("invalid SERVICE_KEYS: secret of key %s must be at least %d characters", id, minSecretLength)
+		}
+		if _, ok := keys[id]; ok {
+			return nil, fmt.Errorf("invalid SERVICE_KEYS: duplicate key %s", id)
+		}
+		keys[id] = secret
+	}
+	return keys, nil
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+// AnalyticsController defines the interface for analytics handlers
+type AnalyticsController interface {
+	GetCustomerAnalytics(ctx echo.Context) error
+}
+
+// analyticsController is the concrete implementation of AnalyticsController
+type analyticsController struct {
+	analyticsService services.AnalyticsService
+}
+
+// NewAnalyticsController initializes a new AnalyticsController.
+func NewAnalyticsController(analyticsService services.AnalyticsService) AnalyticsController {
+	return &analyticsController{
+		analyticsService: analyticsService,
+	}
+}
+
+// GetCustomerAnalytics reports count, sum, average, min, max and percentiles of a customer's net amounts
+// over the 'from'/'to' range, overall and per 'bucket' (day, week, month or quarter, default day) in the
+// optional IANA 'tz' zone. Amounts are converted into the 'currency' query parameter, the base currency by default.
+func (ac *analyticsController) GetCustomerAnalytics(ctx echo.Context) error {
+	customerID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid Customer ID"})
+	}
+	report, err := parseReportQuery(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	analytics, err := ac.analyticsService.GetCustomerAnalytics(&models.AnalyticsQuery{CustomerID: customerID, ReportQuery: *report})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Customer not found"})
+	}
+	return respondReport(ctx, analytics, err)
+}
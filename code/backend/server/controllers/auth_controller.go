@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/middlewares"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+// AuthController defines the interface for customer authentication handlers
+type AuthController interface {
+	Login(ctx echo.Context) error
+	OperatorLogin(ctx echo.Context) error
+	Refresh(ctx echo.Context) error
+	Logout(ctx echo.Context) error
+}
+
+// authController is the concrete implementation of AuthController
+type authController struct {
+	authService services.AuthService
+}
+
+// NewAuthController initializes a new AuthController.
+func NewAuthController(authService services.AuthService) AuthController {
+	return &authController{
+		authService: authService,
+	}
+}
+
+// Login issues an access and a refresh token for a customer's email and password.
+func (ac *authController) Login(ctx echo.Context) error {
+	return ac.login(ctx, ac.authService.Login)
+}
+
+// OperatorLogin issues an access and a refresh token for an operator's email and password.
+func (ac *authController) OperatorLogin(ctx echo.Context) error {
+	return ac.login(ctx, ac.authService.OperatorLogin)
+}
+
+// login reads the credentials of a login request and passes them to login
+func (ac *authController) login(ctx echo.Context, login func(email, password string) (*models.TokenPair, error)) error {
+	var req models.LoginRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if req.Email == "" || req.Password == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "email and password are required"})
+	}
+	tokens, err := login(req.Email, req.Password)
+	return respondTokens(ctx, tokens, err)
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token.
+func (ac *authController) Refresh(ctx echo.Context) error {
+	var req models.RefreshRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if req.RefreshToken == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "refresh_token is required"})
+	}
+	tokens, err := ac.authService.Refresh(req.RefreshToken)
+	return respondTokens(ctx, tokens, err)
+}
+
+// Logout revokes the session of the access token the request is authenticated with.
+// Service principals have no session and nothing to revoke.
+func (ac *authController) Logout(ctx echo.Context) error {
+	principal := middlewares.CurrentPrincipal(ctx)
+	if principal == nil || principal.SessionID == uuid.Nil {
+		return ctx.NoContent(http.StatusNoContent)
+	}
+	if err := ac.authService.Logout(principal.SessionID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// respondTokens writes a token pair, or the status matching the error that prevented issuing it
+func respondTokens(ctx echo.Context, tokens *models.TokenPair, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidCredentials), errors.Is(err, services.ErrInvalidToken):
+		return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case err != nil:
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	ctx.Response().Header().Set("Cache-Control", "no-store")
+	return ctx.JSON(http.StatusOK, tokens)
+}
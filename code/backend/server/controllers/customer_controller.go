@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -19,7 +22,6 @@ type CustomerController interface {
 	CreateMultiCustomers(ctx echo.Context) error
 	GetCustomerByID(ctx echo.Context) error
 	UpdateCustomer(ctx echo.Context) error
-	UpdateCustomerPassword(ctx echo.Context) error
 	ResetAllCustomerData(ctx echo.Context) error
 	// DeleteCustomer(ctx echo.Context) error
 }
@@ -36,13 +38,115 @@ func NewCustomerController(customerService services.CustomerService) CustomerCon
 	}
 }
 
-// GetAllCustomers retrieves all customers
+const (
+	defaultCustomerPageSize = 50
+	maxCustomerPageSize     = 500
+)
+
+// GetAllCustomers retrieves a page of customers using the paging, sorting and filter query parameters
 func (cc *customerController) GetAllCustomers(ctx echo.Context) error {
-	customers, err := cc.customerService.GetAllCustomers()
+	query, err := parseCustomerQuery(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	page, err := cc.customerService.QueryCustomers(query)
+	var missingRate *models.MissingFXRateError
+	if errors.As(err, &missingRate) {
+		return ctx.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	}
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-	return ctx.JSON(http.StatusOK, customers)
+	return ctx.JSON(http.StatusOK, page)
+}
+
+// parseCustomerQuery builds and validates a CustomerQuery from the request query parameters
+func parseCustomerQuery(ctx echo.Context) (*models.CustomerQuery, error) {
+	query := &models.CustomerQuery{
+		PageSize:    defaultCustomerPageSize,
+		SortBy:      models.SortByName,
+		Order:       models.Asc,
+		Gender:      models.Gender(ctx.QueryParam("gender")),
+		NamePrefix:  ctx.QueryParam("name_prefix"),
+		EmailPrefix: ctx.QueryParam("email_prefix"),
+		Segment:     models.RFMSegment(ctx.QueryParam("segment")),
+	}
+
+	if v := ctx.QueryParam("page_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size <= 0 || size > maxCustomerPageSize {
+			return nil, fmt.Errorf("page_size must be between 1 and %d", maxCustomerPageSize)
+		}
+		query.PageSize = size
+	}
+	if v := ctx.QueryParam("sort"); v != "" {
+		query.SortBy = models.CustomerSortKey(v)
+		if !query.SortBy.IsValid() {
+			return nil, fmt.Errorf("invalid sort key: %s", v)
+		}
+	}
+	if v := ctx.QueryParam("order"); v != "" {
+		query.Order = models.SortOrder(v)
+		if !query.Order.IsValid() {
+			return nil, fmt.Errorf("invalid order: %s", v)
+		}
+	}
+	if query.Gender != "" && !query.Gender.IsValid() {
+		return nil, fmt.Errorf("invalid gender: %s", query.Gender)
+	}
+	if query.Segment != "" && !query.Segment.IsValid() {
+		return nil, fmt.Errorf("invalid segment: %s", query.Segment)
+	}
+	if v := ctx.QueryParam("min_total"); v != "" {
+		minTotal, err := models.ParseMoney(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_total: %s", v)
+		}
+		query.MinTotal = &minTotal
+	}
+	if v := ctx.QueryParam("max_total"); v != "" {
+		maxTotal, err := models.ParseMoney(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_total: %s", v)
+		}
+		query.MaxTotal = &maxTotal
+	}
+	window, err := parseAggregationWindow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query.Window = window
+	currency, err := parseCurrency(ctx, models.BaseCurrency)
+	if err != nil {
+		return nil, err
+	}
+	query.Currency = currency
+
+	if v := ctx.QueryParam("cursor"); v != "" {
+		cursor, err := models.DecodeCustomerCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.SortBy != query.SortBy || cursor.Order != query.Order {
+			return nil, fmt.Errorf("cursor does not match the requested sort")
+		}
+		if cursor.Currency != query.Currency {
+			return nil, fmt.Errorf("cursor does not match the requested currency")
+		}
+		query.Cursor = cursor
+	}
+	return query, nil
+}
+
+// parseAggregationWindow reads the window, from, to and year query parameters
+func parseAggregationWindow(ctx echo.Context) (*models.AggregationWindow, error) {
+	return models.ParseAggregationWindow(
+		ctx.QueryParam("window"),
+		ctx.QueryParam("from"),
+		ctx.QueryParam("to"),
+		ctx.QueryParam("year"),
+		time.Now(),
+	)
 }
 
 // GetLimitedCustomers retrieves a limited number of customers based on 'num' parameter
@@ -64,17 +168,19 @@ func (cc *customerController) CreateCustomer(ctx echo.Context) error {
 	if err := ctx.Bind(customer); err != nil {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
-	if len(customer.Password) < 8 {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Password must be at least 8 characters"})
-	}
 	customer.ID = uuid.New()
-	if err := cc.customerService.CreateCustomer(customer); err != nil {
+	customer.CreatedAt = time.Now()
+	err := cc.customerService.CreateCustomer(customer)
+	if errors.Is(err, services.ErrWeakPassword) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 	return ctx.JSON(http.StatusCreated, customer)
 }
 
-// CreateMultiCustomers adds multiple customers at once
+// CreateMultiCustomers adds multiple customers at once and reports the outcome for each of them
 func (cc *customerController) CreateMultiCustomers(ctx echo.Context) error {
 	var customers []*models.Customer
 	if err := ctx.Bind(&customers); err != nil {
@@ -85,15 +191,10 @@ func (cc *customerController) CreateMultiCustomers(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Customers length over 1000"})
 	}
 
-	successCount, failCount, err := cc.customerService.CreateMultiCustomers(customers)
+	result, err := cc.customerService.CreateMultiCustomers(customers)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-
-	result := map[string]int{
-		"successCount": successCount,
-		"failCount":    failCount,
-	}
 	return ctx.JSON(http.StatusCreated, result)
 }
 
@@ -103,32 +204,27 @@ func (cc *customerController) GetCustomerByID(ctx echo.Context) error {
 	if err != nil {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
 	}
-	customer, err := cc.customerService.GetCustomerByID(id)
+	window, err := parseAggregationWindow(ctx)
 	if err != nil {
-		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
-	return ctx.JSON(http.StatusOK, customer)
-}
-
-// UpdateCustomer updates customer details by ID
-func (cc *customerController) UpdateCustomer(ctx echo.Context) error {
-	id, err := uuid.Parse(ctx.Param("id"))
+	currency, err := parseCurrency(ctx, models.BaseCurrency)
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
-	}
-	customer := new(models.Customer)
-	if err := ctx.Bind(customer); err != nil {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
-	customer.ID = id
-	if err := cc.customerService.UpdateCustomer(customer); err != nil {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	customer, err := cc.customerService.GetCustomerByID(id, window, currency)
+	var missingRate *models.MissingFXRateError
+	if errors.As(err, &missingRate) {
+		return ctx.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
 	}
 	return ctx.JSON(http.StatusOK, customer)
 }
 
-// UpdateCustomerPassword updates only the password of a customer by ID
-func (cc *customerController) UpdateCustomerPassword(ctx echo.Context) error {
+// UpdateCustomer updates customer details by ID
+func (cc *customerController) UpdateCustomer(ctx echo.Context) error {
 	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
@@ -137,11 +233,8 @@ func (cc *customerController) UpdateCustomerPassword(ctx echo.Context) error {
 	if err := ctx.Bind(customer); err != nil {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
-	if len(customer.Password) < 8 {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Password must be at least 8 characters"})
-	}
 	customer.ID = id
-	if err := cc.customerService.UpdateCustomerPassword(customer); err != nil {
+	if err := cc.customerService.UpdateCustomer(customer); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 	return ctx.JSON(http.StatusOK, customer)
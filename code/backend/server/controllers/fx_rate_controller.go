@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+// FXRateController defines the interface for FX rate handlers
+type FXRateController interface {
+	GetRates(ctx echo.Context) error
+	UpsertRates(ctx echo.Context) error
+	ImportRates(ctx echo.Context) error
+}
+
+// fxRateController is the concrete implementation of FXRateController
+type fxRateController struct {
+	fxRateService services.FXRateService
+}
+
+// NewFXRateController initializes a new FXRateController.
+func NewFXRateController(fxRateService services.FXRateService) FXRateController {
+	return &fxRateController{
+		fxRateService: fxRateService,
+	}
+}
+
+// GetRates lists the stored rates, optionally restricted by the 'currency' query parameter.
+func (fc *fxRateController) GetRates(ctx echo.Context) error {
+	currency, err := parseCurrency(ctx, "")
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	rates, err := fc.fxRateService.GetRates(currency)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, rates)
+}
+
+// UpsertRates stores a JSON array of rates, replacing rates of the same currency and date.
+func (fc *fxRateController) UpsertRates(ctx echo.Context) error {
+	var rates []*models.FXRateDTO
+	if err := ctx.Bind(&rates); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	stored, err := fc.fxRateService.UpsertRates(rates)
+	return fc.respondStored(ctx, stored, err)
+}
+
+// ImportRates stores the rates of a CSV upload with a currency, effective_date, rate header.
+func (fc *fxRateController) ImportRates(ctx echo.Context) error {
+	stored, err := fc.fxRateService.LoadRatesCSV(ctx.Request().Body)
+	return fc.respondStored(ctx, stored, err)
+}
+
+// respondStored reports how many rates were stored, or why none were
+func (fc *fxRateController) respondStored(ctx echo.Context, stored int, err error) error {
+	if errors.Is(err, services.ErrInvalidFXRate) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, map[string]int{"stored": stored})
+}
+
+// parseCurrency reads the 'currency' query parameter, falling back to defaultCurrency when it is absent
+func parseCurrency(ctx echo.Context, defaultCurrency models.Currency) (models.Currency, error) {
+	v := ctx.QueryParam("currency")
+	if v == "" {
+		return defaultCurrency, nil
+	}
+	currency, ok := models.ParseCurrency(v)
+	if !ok {
+		return "", fmt.Errorf("unsupported currency: %s", v)
+	}
+	return currency, nil
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+// ImportJobController defines the interface for bulk import job handlers
+type ImportJobController interface {
+	StartImport(ctx echo.Context) error
+	GetJobByID(ctx echo.Context) error
+	CancelJob(ctx echo.Context) error
+}
+
+// importJobController is the concrete implementation of ImportJobController
+type importJobController struct {
+	importService services.ImportService
+}
+
+// NewImportJobController initializes a new ImportJobController.
+func NewImportJobController(importService services.ImportService) ImportJobController {
+	return &importJobController{
+		importService: importService,
+	}
+}
+
+// StartImport accepts an NDJSON or CSV upload and starts importing it in the background.
+// The 'type' query parameter selects customers or transactions; 'format' defaults from the Content-Type.
+func (jc *importJobController) StartImport(ctx echo.Context) error {
+	kind := models.ImportKind(ctx.QueryParam("type"))
+	if !kind.IsValid() {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "type must be customers or transactions"})
+	}
+
+	format := models.ImportFormat(ctx.QueryParam("format"))
+	if format == "" {
+		format = models.FormatNDJSON
+		if strings.HasPrefix(ctx.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+			format = models.FormatCSV
+		}
+	}
+	if !format.IsValid() {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "format must be ndjson or csv"})
+	}
+
+	job, err := jc.importService.StartImport(kind, format, ctx.Request().Body, requestActor(ctx))
+	if errors.Is(err, services.ErrUploadTooLarge) {
+		return ctx.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusAccepted, job)
+}
+
+// GetJobByID reports the status, progress and errors of an import job.
+func (jc *importJobController) GetJobByID(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+	job, err := jc.importService.GetJobByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Job not found"})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, job)
+}
+
+// CancelJob stops a queued or running import job.
+func (jc *importJobController) CancelJob(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+	if _, err := jc.importService.GetJobByID(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Job not found"})
+		}
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	cancelled, err := jc.importService.CancelJob(id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !cancelled {
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Job has already finished"})
+	}
+	return ctx.JSON(http.StatusOK, map[string]string{"message": "Job cancelled"})
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+// OperatorController defines the interface for operator account management handlers
+type OperatorController interface {
+	GetAllOperators(ctx echo.Context) error
+	CreateOperator(ctx echo.Context) error
+	UpdateOperator(ctx echo.Context) error
+	DeleteOperator(ctx echo.Context) error
+}
+
+// operatorController is the concrete implementation of OperatorController
+type operatorController struct {
+	operatorService services.OperatorService
+}
+
+// NewOperatorController initializes a new OperatorController.
+func NewOperatorController(operatorService services.OperatorService) OperatorController {
+	return &operatorController{
+		operatorService: operatorService,
+	}
+}
+
+// GetAllOperators lists all operator accounts.
+func (oc *operatorController) GetAllOperators(ctx echo.Context) error {
+	operators, err := oc.operatorService.GetAllOperators()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, operators)
+}
+
+// CreateOperator creates an operator account with a name, email, password and role.
+func (oc *operatorController) CreateOperator(ctx echo.Context) error {
+	req := new(models.CreateOperatorRequest)
+	if err := ctx.Bind(req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	operator, err := oc.operatorService.CreateOperator(req)
+	if err != nil {
+		return respondOperatorError(ctx, err)
+	}
+	return ctx.JSON(http.StatusCreated, operator)
+}
+
+// UpdateOperator changes an operator's name, role or disabled state by ID.
+func (oc *operatorController) UpdateOperator(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+	req := new(models.UpdateOperatorRequest)
+	if err := ctx.Bind(req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	operator, err := oc.operatorService.UpdateOperator(id, req)
+	if err != nil {
+		return respondOperatorError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, operator)
+}
+
+// DeleteOperator deletes an operator account by ID.
+func (oc *operatorController) DeleteOperator(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+	if err := oc.operatorService.DeleteOperator(id); err != nil {
+		return respondOperatorError(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// respondOperatorError writes the status matching an error of the operator service
+func respondOperatorError(ctx echo.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidOperator):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Operator not found"})
+	case errors.Is(err, services.ErrOperatorEmailTaken), errors.Is(err, services.ErrLastAdmin):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
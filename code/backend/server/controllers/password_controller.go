@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/middlewares"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+// PasswordController defines the interface for password change and reset handlers
+type PasswordController interface {
+	ChangePassword(ctx echo.Context) error
+	ForgotPassword(ctx echo.Context) error
+	ResetPassword(ctx echo.Context) error
+}
+
+// passwordController is the concrete implementation of PasswordController
+type passwordController struct {
+	passwordService services.PasswordService
+}
+
+// NewPasswordController initializes a new PasswordController.
+func NewPasswordController(passwordService services.PasswordService) PasswordController {
+	return &passwordController{
+		passwordService: passwordService,
+	}
+}
+
+// ChangePassword sets a customer's new password given their current one. The caller's own session stays
+// active; the customer's other sessions are revoked.
+func (pc *passwordController) ChangePassword(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+	var req models.ChangePasswordRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "current_password and new_password are required"})
+	}
+
+	keepSessionID := uuid.Nil
+	if principal := middlewares.CurrentPrincipal(ctx); principal != nil && principal.ID == id {
+		keepSessionID = principal.SessionID
+	}
+	err = pc.passwordService.ChangePassword(id, &req, keepSessionID)
+	return respondPasswordError(ctx, err)
+}
+
+// ForgotPassword sends a password reset link to the customer with the email. It answers 202 whether or not
+// the email has an account, or 503 if password resets are disabled or too many are queued.
+func (pc *passwordController) ForgotPassword(ctx echo.Context) error {
+	var req models.ForgotPasswordRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if req.Email == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "email is required"})
+	}
+	err := pc.passwordService.RequestReset(req.Email)
+	if errors.Is(err, services.ErrPasswordResetDisabled) || errors.Is(err, services.ErrPasswordResetBusy) {
+		return ctx.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password with the token of a reset link
+func (pc *passwordController) ResetPassword(ctx echo.Context) error {
+	var req models.ResetPasswordRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if req.Token == "" || req.NewPassword == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "token and new_password are required"})
+	}
+	err := pc.passwordService.ResetPassword(&req)
+	return respondPasswordError(ctx, err)
+}
+
+// respondPasswordError writes 204 after a password was set, or the status matching the error that prevented it
+func respondPasswordError(ctx echo.Context, err error) error {
+	switch {
+	case err == nil:
+		return ctx.NoContent(http.StatusNoContent)
+	case errors.Is(err, services.ErrWeakPassword), errors.Is(err, services.ErrInvalidResetToken):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrIncorrectPassword):
+		return ctx.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Customer not found"})
+	}
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
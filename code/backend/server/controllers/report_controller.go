@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+// ReportController defines the interface for cross-customer report handlers
+type ReportController interface {
+	GetRevenue(ctx echo.Context) error
+	GetActiveCustomers(ctx echo.Context) error
+	GetNewCustomers(ctx echo.Context) error
+	GetTopCustomers(ctx echo.Context) error
+	GetRevenueByGender(ctx echo.Context) error
+	GetCohortRetention(ctx echo.Context) error
+}
+
+// reportController is the concrete implementation of ReportController
+type reportController struct {
+	reportService services.ReportService
+	cohortService services.CohortService
+}
+
+// NewReportController initializes a new ReportController.
+func NewReportController(reportService services.ReportService, cohortService services.CohortService) ReportController {
+	return &reportController{
+		reportService: reportService,
+		cohortService: cohortService,
+	}
+}
+
+// GetRevenue reports the net revenue per bucket over the 'from'/'to' range.
+func (rc *reportController) GetRevenue(ctx echo.Context) error {
+	query, err := parseReportQuery(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	report, err := rc.reportService.GetRevenueReport(query)
+	return respondReport(ctx, report, err)
+}
+
+// GetActiveCustomers reports the number of customers making purchases per bucket over the 'from'/'to' range.
+func (rc *reportController) GetActiveCustomers(ctx echo.Context) error {
+	query, err := parseReportQuery(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	report, err := rc.reportService.GetActiveCustomersReport(query)
+	return respondReport(ctx, report, err)
+}
+
+// GetNewCustomers reports the number of customers registering per bucket over the 'from'/'to' range.
+func (rc *reportController) GetNewCustomers(ctx echo.Context) error {
+	query, err := parseReportQuery(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	report, err := rc.reportService.GetNewCustomersReport(query)
+	return respondReport(ctx, report, err)
+}
+
+// GetTopCustomers reports the 'limit' customers with the highest net spend in the aggregation window.
+func (rc *reportController) GetTopCustomers(ctx echo.Context) error {
+	window, err := parseAggregationWindow(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	currency, err := parseCurrency(ctx, models.BaseCurrency)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	limit := models.DefaultTopCustomers
+	if v := ctx.QueryParam("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > models.MaxTopCustomers {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d", models.MaxTopCustomers)})
+		}
+	}
+	report, err := rc.reportService.GetTopCustomersReport(window, currency, limit)
+	return respondReport(ctx, report, err)
+}
+
+// GetRevenueByGender reports the net revenue in the aggregation window split by customer gender.
+func (rc *reportController) GetRevenueByGender(ctx echo.Context) error {
+	window, err := parseAggregationWindow(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	currency, err := parseCurrency(ctx, models.BaseCurrency)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	report, err := rc.reportService.GetRevenueByGenderReport(window, currency)
+	return respondReport(ctx, report, err)
+}
+
+// GetCohortRetention reports the retention and revenue of each monthly registration cohort, as JSON or,
+// with 'format=csv', as a CSV attachment.
+func (rc *reportController) GetCohortRetention(ctx echo.Context) error {
+	query, err := parseCohortQuery(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	format := ctx.QueryParam("format")
+	if format != "" && format != "json" && format != "csv" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "format must be json or csv"})
+	}
+
+	report, err := rc.cohortService.GetRetentionReport(query)
+	if err != nil || format != "csv" {
+		return respondReport(ctx, report, err)
+	}
+	ctx.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=UTF-8")
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="cohort-retention.csv"`)
+	ctx.Response().WriteHeader(http.StatusOK)
+	return services.WriteCohortRetentionCSV(ctx.Response(), report)
+}
+
+// parseCohortQuery reads the optional 'from'/'to' registration months (YYYY-MM, both inclusive), the
+// optional IANA 'tz' zone, the number of 'months' to follow each cohort for and the 'currency'
+func parseCohortQuery(ctx echo.Context) (*models.CohortQuery, error) {
+	loc, err := models.ParseTimeZone(ctx.QueryParam("tz"))
+	if err != nil {
+		return nil, err
+	}
+	query := &models.CohortQuery{Location: loc, Months: models.DefaultCohortMonths}
+	if v := ctx.QueryParam("from"); v != "" {
+		if query.From, err = models.ParseCohortMonth(v, loc); err != nil {
+			return nil, err
+		}
+	}
+	if v := ctx.QueryParam("to"); v != "" {
+		to, err := models.ParseCohortMonth(v, loc)
+		if err != nil {
+			return nil, err
+		}
+		query.To = to.AddDate(0, 1, 0)
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return nil, fmt.Errorf("from must not be after to")
+	}
+	if v := ctx.QueryParam("months"); v != "" {
+		query.Months, err = strconv.Atoi(v)
+		if err != nil || query.Months < 0 || query.Months > models.MaxCohortMonths {
+			return nil, fmt.Errorf("months must be between 0 and %d", models.MaxCohortMonths)
+		}
+	}
+	if query.Currency, err = parseCurrency(ctx, models.BaseCurrency); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// parseReportQuery reads the 'from'/'to' range, the optional IANA 'tz' zone, the 'bucket' size
+// (day by default) and the 'currency' (the base currency by default)
+func parseReportQuery(ctx echo.Context) (*models.ReportQuery, error) {
+	loc, err := models.ParseTimeZone(ctx.QueryParam("tz"))
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := models.ParseTransactionRange(ctx.QueryParam("from"), ctx.QueryParam("to"), loc)
+	if err != nil {
+		return nil, err
+	}
+	bucket := models.BucketDay
+	if v := ctx.QueryParam("bucket"); v != "" {
+		bucket = models.BucketSize(v)
+		if !bucket.IsValid() {
+			return nil, fmt.Errorf("bucket must be day, week, month or quarter")
+		}
+	}
+	currency, err := parseCurrency(ctx, models.BaseCurrency)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReportQuery{From: from, To: to, Location: loc, Bucket: bucket, Currency: currency}, nil
+}
+
+// respondReport writes a report, or the status matching the error that prevented it
+func respondReport(ctx echo.Context, report interface{}, err error) error {
+	var missingRate *models.MissingFXRateError
+	switch {
+	case errors.Is(err, services.ErrInvalidAnalyticsQuery):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.As(err, &missingRate):
+		return ctx.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	case err != nil:
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
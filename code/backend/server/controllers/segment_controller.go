@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+// SegmentController defines the interface for customer segmentation handlers
+type SegmentController interface {
+	GetRFMSummary(ctx echo.Context) error
+	GetRFMSegmentMembers(ctx echo.Context) error
+	RecomputeRFM(ctx echo.Context) error
+}
+
+// segmentController is the concrete implementation of SegmentController
+type segmentController struct {
+	rfmService services.RFMService
+}
+
+// NewSegmentController initializes a new SegmentController.
+func NewSegmentController(rfmService services.RFMService) SegmentController {
+	return &segmentController{
+		rfmService: rfmService,
+	}
+}
+
+// GetRFMSummary reports the latest RFM run and the number of customers in each segment.
+func (sc *segmentController) GetRFMSummary(ctx echo.Context) error {
+	summary, err := sc.rfmService.GetSummary()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "RFM scores have not been computed yet"})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, summary)
+}
+
+// GetRFMSegmentMembers lists a page of the customers in a segment, highest monetary value first,
+// using the 'page_size' and 'cursor' query parameters.
+func (sc *segmentController) GetRFMSegmentMembers(ctx echo.Context) error {
+	segment := models.RFMSegment(ctx.Param("segment"))
+	if !segment.IsValid() {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("unknown segment: %s", segment)})
+	}
+	pageSize := defaultCustomerPageSize
+	if v := ctx.QueryParam("page_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size <= 0 || size > maxCustomerPageSize {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("page_size must be between 1 and %d", maxCustomerPageSize)})
+		}
+		pageSize = size
+	}
+	var cursor *models.RFMMemberCursor
+	if v := ctx.QueryParam("cursor"); v != "" {
+		var err error
+		if cursor, err = models.DecodeRFMMemberCursor(v); err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+	}
+
+	page, err := sc.rfmService.GetSegmentMembers(segment, pageSize, cursor)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, page)
+}
+
+// RecomputeRFM recomputes the RFM scores over the aggregation window given by the query parameters
+// and returns the new summary.
+func (sc *segmentController) RecomputeRFM(ctx echo.Context) error {
+	window, err := parseAggregationWindow(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	summary, err := sc.rfmService.Recompute(window, models.RFMTriggerManual)
+	var missingRate *models.MissingFXRateError
+	switch {
+	case errors.Is(err, services.ErrRFMRecomputeRunning):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.As(err, &missingRate):
+		return ctx.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	case err != nil:
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusCreated, summary)
+}
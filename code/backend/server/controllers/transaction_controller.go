@@ -1,11 +1,16 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/middlewares"
 	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
 	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
 )
@@ -15,9 +20,9 @@ type TransactionController interface {
 	GetTransactionsByCustomerID(ctx echo.Context) error
 	GetDateRangeTransactionsByCustomerID(ctx echo.Context) error
 	CreateMultiTransactions(ctx echo.Context) error
+	CorrectTransaction(ctx echo.Context) error
+	GetTransactionHistory(ctx echo.Context) error
 	// CreateTransaction(ctx echo.Context) error
-	// UpdateTransaction(ctx echo.Context) error
-	// DeleteTransaction(ctx echo.Context) error
 }
 
 // transactionController is the concrete implementation of TransactionController
@@ -32,37 +37,116 @@ func NewTransactionController(transactionService services.TransactionService) Tr
 	}
 }
 
-// GetTransactionsByCustomerID retrieves all transactions for a specified customer.
+const (
+	defaultTransactionPageSize = 50
+	maxTransactionPageSize     = 500
+)
+
+// GetTransactionsByCustomerID retrieves a page of transactions for a specified customer using the
+// paging, order and amount filter query parameters.
+// The optional 'currency' query parameter adds each amount converted into that currency.
 func (tc *transactionController) GetTransactionsByCustomerID(ctx echo.Context) error {
-	customerID, err := uuid.Parse(ctx.Param("id"))
+	query, err := parseTransactionQuery(ctx)
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid Customer ID"})
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
-	transactions, err := tc.transactionService.GetTransactionsByCustomerID(customerID)
+	return tc.respondTransactionPage(ctx, query)
+}
+
+// GetDateRangeTransactionsByCustomerID retrieves a page of transactions within a date range for a specified customer.
+// 'from' and 'to' are RFC3339 timestamps or dates in the optional IANA 'tz' zone; a date as 'to' includes that day.
+// The optional 'currency' query parameter adds each amount converted into that currency.
+func (tc *transactionController) GetDateRangeTransactionsByCustomerID(ctx echo.Context) error {
+	query, err := parseTransactionQuery(ctx)
 	if err != nil {
-		return ctx.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
-	return ctx.JSON(http.StatusOK, transactions)
+	loc, err := models.ParseTimeZone(ctx.QueryParam("tz"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	query.From, query.To, err = models.ParseTransactionRange(ctx.QueryParam("from"), ctx.QueryParam("to"), loc)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return tc.respondTransactionPage(ctx, query)
 }
 
-// GetDateRangeTransactionsByCustomerID retrieves transactions within a date range for a specified customer.
-func (tc *transactionController) GetDateRangeTransactionsByCustomerID(ctx echo.Context) error {
-	customerID, err := uuid.Parse(ctx.Param("id"))
+// respondTransactionPage runs the query and writes the page, or 422 if an amount cannot be converted
+func (tc *transactionController) respondTransactionPage(ctx echo.Context, query *models.TransactionQuery) error {
+	page, err := tc.transactionService.QueryTransactions(query)
+	var missingRate *models.MissingFXRateError
+	if errors.As(err, &missingRate) {
+		return ctx.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	}
 	if err != nil {
-		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid Customer ID"})
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	return ctx.JSON(http.StatusOK, page)
+}
 
-	from := ctx.QueryParam("from")
-	to := ctx.QueryParam("to")
+// parseTransactionQuery builds and validates a TransactionQuery from the customer ID and query parameters
+func parseTransactionQuery(ctx echo.Context) (*models.TransactionQuery, error) {
+	customerID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return nil, errors.New("Invalid Customer ID")
+	}
+	query := &models.TransactionQuery{
+		CustomerID: customerID,
+		PageSize:   defaultTransactionPageSize,
+		Order:      models.Asc,
+	}
 
-	transactions, err := tc.transactionService.GetDateRangeTransactionsByCustomerID(customerID, from, to)
+	if v := ctx.QueryParam("page_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size <= 0 || size > maxTransactionPageSize {
+			return nil, fmt.Errorf("page_size must be between 1 and %d", maxTransactionPageSize)
+		}
+		query.PageSize = size
+	}
+	if v := ctx.QueryParam("order"); v != "" {
+		query.Order = models.SortOrder(v)
+		if !query.Order.IsValid() {
+			return nil, fmt.Errorf("invalid order: %s", v)
+		}
+	}
+	if v := ctx.QueryParam("min_amount"); v != "" {
+		minAmount, err := models.ParseMoney(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_amount: %s", v)
+		}
+		query.MinAmount = &minAmount
+	}
+	if v := ctx.QueryParam("max_amount"); v != "" {
+		maxAmount, err := models.ParseMoney(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_amount: %s", v)
+		}
+		query.MaxAmount = &maxAmount
+	}
+	currency, err := parseCurrency(ctx, "")
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return nil, err
 	}
-	return ctx.JSON(http.StatusOK, transactions)
+	query.Currency = currency
+
+	if v := ctx.QueryParam("cursor"); v != "" {
+		cursor, err := models.DecodeTransactionCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Order != query.Order {
+			return nil, fmt.Errorf("cursor does not match the requested order")
+		}
+		if cursor.Currency != query.Currency {
+			return nil, fmt.Errorf("cursor does not match the requested currency")
+		}
+		query.Cursor = cursor
+	}
+	return query, nil
 }
 
-// CreateMultiTransactions creates multiple transactions from the provided DTOs.
+// CreateMultiTransactions creates multiple transactions from the provided DTOs and reports rejected items.
 func (tc *transactionController) CreateMultiTransactions(ctx echo.Context) error {
 	var transactions []*models.TransactionDTO
 	if err := ctx.Bind(&transactions); err != nil {
@@ -73,17 +157,76 @@ func (tc *transactionController) CreateMultiTransactions(ctx echo.Context) error
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "transactions length over 5000"})
 	}
 
-	if err := tc.transactionService.CreateMultiTransactions(transactions); err != nil {
+	mode := models.AllOrNothing
+	if v := ctx.QueryParam("mode"); v != "" {
+		mode = models.BatchMode(v)
+		if !mode.IsValid() {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "mode must be all_or_nothing or best_effort"})
+		}
+	}
+
+	result, err := tc.transactionService.CreateMultiTransactions(transactions, mode, requestActor(ctx))
+	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	result := map[string]string{
-		"result": "success",
+	// In all-or-nothing mode a single rejected item means nothing was stored
+	if mode == models.AllOrNothing && result.Rejected > 0 {
+		return ctx.JSON(http.StatusUnprocessableEntity, result)
 	}
+	return ctx.JSON(http.StatusCreated, result)
+}
 
+// CorrectTransaction corrects a purchase by appending its reversal and a replacement with the
+// corrected amount, currency or time; transactions themselves are never modified.
+func (tc *transactionController) CorrectTransaction(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+	correction := new(models.TransactionCorrection)
+	if err := ctx.Bind(correction); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	result, err := tc.transactionService.CorrectTransaction(id, correction, requestActor(ctx))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Transaction not found"})
+	case errors.Is(err, services.ErrInvalidCorrection):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, services.ErrCorrectionConflict):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case err != nil:
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
 	return ctx.JSON(http.StatusCreated, result)
 }
 
+// GetTransactionHistory lists the audit entries of a transaction and of the entries recorded against it.
+func (tc *transactionController) GetTransactionHistory(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+	history, err := tc.transactionService.GetTransactionHistory(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Transaction not found"})
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return ctx.JSON(http.StatusOK, history)
+}
+
+// requestActor identifies the authenticated caller of the request for the audit trail
+func requestActor(ctx echo.Context) string {
+	if principal := middlewares.CurrentPrincipal(ctx); principal != nil {
+		return principal.Actor()
+	}
+	return "anonymous"
+}
+
 // CreateTransaction creates a new transaction with a generated ID.
 // func (tc *transactionController) CreateTransaction(ctx echo.Context) error {
 // 	transaction := new(models.Transaction)
@@ -96,32 +239,3 @@ func (tc *transactionController) CreateMultiTransactions(ctx echo.Context) error
 // 	}
 // 	return ctx.JSON(http.StatusCreated, transaction)
 // }
-
-// UpdateTransaction updates an existing transaction specified by ID.
-// func (tc *transactionController) UpdateTransaction(ctx echo.Context) error {
-// 	id, err := uuid.Parse(ctx.Param("id"))
-// 	if err != nil {
-// 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
-// 	}
-// 	transaction := new(models.Transaction)
-// 	if err := ctx.Bind(transaction); err != nil {
-// 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
-// 	}
-// 	transaction.ID = id // Set the transaction ID for updating
-// 	if err := tc.transactionService.UpdateTransaction(transaction); err != nil {
-// 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-// 	}
-// 	return ctx.JSON(http.StatusOK, transaction)
-// }
-
-// DeleteTransaction deletes a transaction specified by ID.
-// func (tc *transactionController) DeleteTransaction(ctx echo.Context) error {
-// 	id, err := uuid.Parse(ctx.Param("id"))
-// 	if err != nil {
-// 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
-// 	}
-// 	if err := tc.transactionService.DeleteTransaction(id); err != nil {
-// 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-// 	}
-// 	return ctx.NoContent(http.StatusNoContent) // Return 204 No Content on successful deletion
-// }
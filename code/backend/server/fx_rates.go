@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+const fxRatesUsage = "usage: server fx-rates load <file.csv>"
+
+// runFXRatesCommand executes the fx-rates subcommand with the arguments following "fx-rates"
+func runFXRatesCommand(fxRateService services.FXRateService, args []string) error {
+	if len(args) != 2 || args[0] != "load" {
+		return fmt.Errorf(fxRatesUsage)
+	}
+
+	file, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stored, err := fxRateService.LoadRatesCSV(file)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("stored %d FX rates\n", stored)
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+const ledgerUsage = "usage: server ledger verify|rekey"
+
+// runLedgerCommand executes the ledger subcommand with the arguments following "ledger"
+func runLedgerCommand(transactionService services.TransactionService, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf(ledgerUsage)
+	}
+
+	switch args[0] {
+	case "verify":
+		return verifyLedgers(transactionService)
+	case "rekey":
+		return rekeyLedgers(transactionService)
+	default:
+		return fmt.Errorf(ledgerUsage)
+	}
+}
+
+// verifyLedgers prints the violations of every ledger's hash chain and head
+func verifyLedgers(transactionService services.TransactionService) error {
+	report, err := transactionService.VerifyLedgers()
+	if err != nil {
+		return err
+	}
+	printLedgerViolations(report.Violations)
+	fmt.Printf("checked %d entries of %d customers, %d violations\n",
+		report.EntriesChecked, report.CustomersChecked, len(report.Violations))
+	if len(report.Violations) > 0 {
+		return fmt.Errorf("the transaction ledger has been tampered with")
+	}
+	return nil
+}
+
+// rekeyLedgers rechains the ledgers hashed before hashes were keyed and prints the ones that could not be
+func rekeyLedgers(transactionService services.TransactionService) error {
+	report, err := transactionService.RekeyLedgers()
+	if err != nil {
+		return err
+	}
+	printLedgerViolations(report.Violations)
+	fmt.Printf("rekeyed the ledgers of %d customers, %d violations\n",
+		report.CustomersChecked, len(report.Violations))
+	if len(report.Violations) > 0 {
+		return fmt.Errorf("ledgers with broken chains were not rekeyed")
+	}
+	return nil
+}
+
+// printLedgerViolations prints one line per violation
+func printLedgerViolations(violations []models.LedgerViolation) {
+	for _, violation := range violations {
+		fmt.Printf("customer %s seq %d transaction %s: %s\n",
+			violation.CustomerID, violation.LedgerSeq, violation.TransactionID, violation.Problem)
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -10,6 +13,8 @@ import (
 
 	"github.com/xzz8868/titansoft-pre-test/code/backend/server/config"
 	"github.com/xzz8868/titansoft-pre-test/code/backend/server/controllers"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/middlewares"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/migrations"
 	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
 	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
 	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
@@ -29,22 +34,138 @@ func main() {
 		log.Fatalf("Database connection failed: %v", err)
 	}
 
-	// Auto-migrate database models
-	if err := db.AutoMigrate(&models.Customer{}, &models.Transaction{}); err != nil {
-		log.Fatalf("Database migration failed: %v", err)
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
 	}
 
 	// Initialize repositories
 	customerRepo := repositories.NewCustomerRepository(db)
-	transactionRepo := repositories.NewTransactionRepository(db)
+	ledgerKey := models.NewLedgerKey(cfg.LedgerSecret)
+	transactionRepo := repositories.NewTransactionRepository(db, ledgerKey)
+	idempotencyRepo := repositories.NewIdempotencyRepository(db)
+	importJobRepo := repositories.NewImportJobRepository(db)
+	fxRateRepo := repositories.NewFXRateRepository(db)
+	reportRepo := repositories.NewReportRepository(db)
+	rfmRepo := repositories.NewRFMRepository(db)
+	authSessionRepo := repositories.NewAuthSessionRepository(db)
+	operatorRepo := repositories.NewOperatorRepository(db)
+	passwordResetRepo := repositories.NewPasswordResetRepository(db)
 
 	// Initialize services
-	customerService := services.NewCustomerService(customerRepo, transactionRepo, cfg.Salt)
-	transactionService := services.NewTransactionService(transactionRepo)
+	passwordHasher := services.NewPasswordHasher(services.PasswordSettings{
+		Algorithm:  cfg.PasswordHashAlgorithm,
+		Pepper:     cfg.PasswordPepper,
+		LegacySalt: cfg.Salt,
+	})
+	customerService := services.NewCustomerService(customerRepo, transactionRepo, passwordHasher)
+	transactionService := services.NewTransactionService(transactionRepo, customerRepo, ledgerKey)
+	importService := services.NewImportService(importJobRepo, customerService, transactionService, cfg.ImportMaxBytes)
+	fxRateService := services.NewFXRateService(fxRateRepo)
+	analyticsService := services.NewAnalyticsService(transactionRepo, customerRepo)
+	reportService := services.NewReportService(reportRepo)
+	cohortService := services.NewCohortService(transactionRepo)
+	authService := services.NewAuthService(customerRepo, operatorRepo, authSessionRepo, passwordHasher, services.AuthSettings{
+		Secret:     cfg.AuthSecret,
+		AccessTTL:  cfg.AccessTokenTTL,
+		RefreshTTL: cfg.RefreshTokenTTL,
+	})
+	serviceAuthService := services.NewServiceAuthService(cfg.ServiceKeys, cfg.ServiceAuthMaxSkew)
+	operatorService := services.NewOperatorService(operatorRepo, authSessionRepo, passwordHasher)
+	var notifier services.Notifier
+	switch cfg.Notifier {
+	case config.NotifierLog:
+		log.Println("NOTIFIER is log: password reset links are written to the server log, which is for development only")
+		notifier = services.NewLogNotifier()
+	case config.NotifierFile:
+		log.Printf("NOTIFIER is file: password reset links are written to %s, which is for development only", cfg.NotifierFile)
+		notifier = services.NewFileNotifier(cfg.NotifierFile)
+	}
+	passwordService := services.NewPasswordService(customerRepo, passwordResetRepo, authSessionRepo, passwordHasher, notifier, services.PasswordResetSettings{
+		TTL: cfg.PasswordResetTTL,
+		URL: cfg.PasswordResetURL,
+	})
+	rfmService := services.NewRFMService(rfmRepo)
+	idempotencyService := services.NewIdempotencyService(idempotencyRepo, cfg.IdempotencyTTL)
+
+	// Run the migrate subcommand instead of the server when requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(migrator, transactionService, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// Refuse to serve against a schema this binary does not expect
+	if err := migrator.CheckUpToDate(); err != nil {
+		log.Fatalf("%v; run `server migrate up` first", err)
+	}
+
+	// Load FX rates from a CSV file instead of serving when requested
+	if len(os.Args) > 1 && os.Args[1] == "fx-rates" {
+		if err := runFXRatesCommand(fxRateService, os.Args[2:]); err != nil {
+			log.Fatalf("Loading FX rates failed: %v", err)
+		}
+		return
+	}
+
+	// Verify the transaction ledgers instead of serving when requested
+	if len(os.Args) > 1 && os.Args[1] == "ledger" {
+		if err := runLedgerCommand(transactionService, os.Args[2:]); err != nil {
+			log.Fatalf("Ledger verification failed: %v", err)
+		}
+		return
+	}
+
+	// Create the first admin instead of serving when requested
+	if len(os.Args) > 1 && os.Args[1] == "operators" {
+		if err := runOperatorsCommand(operatorService, os.Args[2:]); err != nil {
+			log.Fatalf("Creating the admin failed: %v", err)
+		}
+		return
+	}
+
+	// Ledgers left unkeyed by `server migrate up` have broken chains and refuse new transactions
+	if unkeyed, err := transactionService.CountUnkeyedLedgers(); err != nil {
+		log.Fatalf("Checking the ledger keys failed: %v", err)
+	} else if unkeyed > 0 {
+		log.Printf("WARNING: %d customer ledgers are not keyed with LEDGER_SECRET and refuse new transactions; "+
+			"run `server ledger verify` to list their broken chains", unkeyed)
+	}
 
 	// Initialize controllers
 	customerController := controllers.NewCustomerController(customerService)
 	transactionController := controllers.NewTransactionController(transactionService)
+	importJobController := controllers.NewImportJobController(importService)
+	fxRateController := controllers.NewFXRateController(fxRateService)
+	analyticsController := controllers.NewAnalyticsController(analyticsService)
+	reportController := controllers.NewReportController(reportService, cohortService)
+	segmentController := controllers.NewSegmentController(rfmService)
+	authController := controllers.NewAuthController(authService)
+	operatorController := controllers.NewOperatorController(operatorService)
+	passwordController := controllers.NewPasswordController(passwordService)
+
+	// Recompute RFM scores in the background on a schedule
+	if cfg.RFMInterval > 0 {
+		if _, err := models.ParseAggregationWindow(cfg.RFMWindow, "", "", "", time.Now()); err != nil {
+			log.Fatalf("Invalid RFM_WINDOW: %v", err)
+		}
+		go rfmService.RunSchedule(context.Background(), cfg.RFMInterval, cfg.RFMWindow)
+	}
+
+	// Fail the import jobs left behind by stopped replicas, now and whenever another replica stops
+	if failed, err := importService.FailOrphanedJobs(); err != nil {
+		log.Printf("Failing orphaned import jobs failed: %v", err)
+	} else if failed > 0 {
+		log.Printf("Marked %d orphaned import jobs as failed", failed)
+	}
+	go importService.RunOrphanSweep(context.Background())
+
+	// Delete idempotency records whose responses are no longer replayed
+	go idempotencyService.RunPurge(context.Background())
+
+	// Deliver password reset links off the request path
+	go passwordService.RunResetDelivery(context.Background())
 
 	// Initialize Echo instance
 	e := echo.New()
@@ -52,30 +173,83 @@ func main() {
 	// Add CORS middleware
 	e.Use(middleware.CORS())
 
+	// Authenticate callers and enforce the permission each route requires
+	e.Use(middlewares.Authorize(authService, serviceAuthService, routePolicy))
+
+	// Idempotency-Key support for create endpoints
+	idempotency := middlewares.Idempotency(idempotencyRepo, middlewares.IdempotencySettings{
+		PendingTimeout: cfg.IdempotencyPendingTimeout,
+		TTL:            cfg.IdempotencyTTL,
+	})
+
 	// Set up routes
 	// Routes for FrontEnd
 	e.GET("/customers", customerController.GetAllCustomers)
 	e.GET("/customers/:id", customerController.GetCustomerByID)
-	e.POST("/customers", customerController.CreateCustomer)
+	e.POST("/customers", customerController.CreateCustomer, idempotency)
 	e.PUT("/customers/:id", customerController.UpdateCustomer)
-	e.PUT("/customers/password/:id", customerController.UpdateCustomerPassword)
+	e.PUT("/customers/password/:id", passwordController.ChangePassword)
 
 	e.GET("/customers/:id/transactions", transactionController.GetTransactionsByCustomerID)
 	e.GET("/customers/:id/transactions/date", transactionController.GetDateRangeTransactionsByCustomerID)
+	e.GET("/customers/:id/analytics", analyticsController.GetCustomerAnalytics)
 
 	e.DELETE("/customers/reset", customerController.ResetAllCustomerData)
 
+	// Routes for authentication
+	e.POST("/auth/login", authController.Login)
+	e.POST("/auth/operators/login", authController.OperatorLogin)
+	e.POST("/auth/refresh", authController.Refresh)
+	e.POST("/auth/logout", authController.Logout)
+	e.POST("/auth/password/forgot", passwordController.ForgotPassword)
+	e.POST("/auth/password/reset", passwordController.ResetPassword)
+
 	// Routes for Generator
 	e.GET("/customers/limit/:num", customerController.GetLimitedCustomers)
-	e.POST("/customers/multi", customerController.CreateMultiCustomers)
+	e.POST("/customers/multi", customerController.CreateMultiCustomers, idempotency)
+
+	e.POST("/transactions/multi", transactionController.CreateMultiTransactions, idempotency)
 
-	e.POST("/transactions/multi", transactionController.CreateMultiTransactions)
+	// Transactions are append-only; corrections add compensating entries
+	e.POST("/transactions/:id/corrections", transactionController.CorrectTransaction)
+	e.GET("/transactions/:id/history", transactionController.GetTransactionHistory)
+
+	// Routes for bulk imports
+	e.POST("/jobs/imports", importJobController.StartImport)
+	e.GET("/jobs/:id", importJobController.GetJobByID)
+	e.POST("/jobs/:id/cancel", importJobController.CancelJob)
+
+	// Routes for FX rates
+	e.GET("/fx-rates", fxRateController.GetRates)
+	e.PUT("/admin/fx-rates", fxRateController.UpsertRates)
+	e.POST("/admin/fx-rates/import", fxRateController.ImportRates)
+
+	// Routes for cross-customer reports
+	e.GET("/reports/revenue", reportController.GetRevenue)
+	e.GET("/reports/active-customers", reportController.GetActiveCustomers)
+	e.GET("/reports/new-customers", reportController.GetNewCustomers)
+	e.GET("/reports/top-customers", reportController.GetTopCustomers)
+	e.GET("/reports/revenue-by-gender", reportController.GetRevenueByGender)
+	e.GET("/reports/cohort-retention", reportController.GetCohortRetention)
+
+	// Routes for customer segments
+	e.GET("/segments/rfm", segmentController.GetRFMSummary)
+	e.GET("/segments/rfm/:segment", segmentController.GetRFMSegmentMembers)
+	e.POST("/admin/segments/rfm/recompute", segmentController.RecomputeRFM)
+
+	// Routes for operator accounts
+	e.GET("/admin/operators", operatorController.GetAllOperators)
+	e.POST("/admin/operators", operatorController.CreateOperator)
+	e.PUT("/admin/operators/:id", operatorController.UpdateOperator)
+	e.DELETE("/admin/operators/:id", operatorController.DeleteOperator)
 
 	// Disabled routes
 	// e.DELETE("/customers/:id", customerController.DeleteCustomer)
 	// e.POST("/transactions", transactionController.CreateTransaction)
-	// e.PUT("/transactions/:id", transactionController.UpdateTransaction)
-	// e.DELETE("/transactions/:id", transactionController.DeleteTransaction)
+
+	if err := routePolicy.Check(e.Routes()); err != nil {
+		log.Fatalf("%v", err)
+	}
 
 	// Start the server
 	e.Logger.Fatal(e.Start(":" + cfg.ServerPort))
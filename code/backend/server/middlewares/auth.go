@@ -0,0 +1,132 @@
+package middlewares
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+// principalKey is the context key under which Authorize stores the caller
+const principalKey = "auth.principal"
+
+// RouteRule grants access to a route to the principals whose role has Permission. If OwnerParam is set,
+// a customer may also call the route for the customer ID in that path parameter, which must be their own.
+type RouteRule struct {
+	Permission models.Permission
+	OwnerParam string
+}
+
+// RoutePolicy maps every route, keyed by RouteKey, to the rule for calling it
+type RoutePolicy map[string]RouteRule
+
+// RouteKey returns the policy key of the route with the given method and path pattern
+func RouteKey(method, path string) string {
+	return method + " " + path
+}
+
+// Check reports the routes that have no rule in the policy, so that new routes cannot end up unprotected
+func (p RoutePolicy) Check(routes []*echo.Route) error {
+	var missing []string
+	for _, route := range routes {
+		if _, ok := p[RouteKey(route.Method, route.Path)]; !ok {
+			missing = append(missing, RouteKey(route.Method, route.Path))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("routes without an access policy: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Authorize enforces the policy of the matched route. Users present credentials in the Authorization
+// header as "Bearer <token>" and services as a request signed with a service key; requests without valid
+// credentials get 401, and principals the rule does not admit get 403. Requests matching no route pass
+// through for the router to reject.
+func Authorize(authService services.AuthService, serviceAuthService services.ServiceAuthService, policy RoutePolicy) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			rule, ok := policy[RouteKey(ctx.Request().Method, ctx.Path())]
+			if !ok || rule.Permission == models.PermissionPublic {
+				return next(ctx)
+			}
+
+			principal, err := authenticate(ctx, authService, serviceAuthService)
+			if errors.Is(err, errMissingCredentials) || errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrInvalidSignature) {
+				return unauthorized(ctx, err.Error())
+			}
+			if err != nil {
+				return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			if !rule.admits(ctx, principal) {
+				return ctx.JSON(http.StatusForbidden, map[string]string{"error": "Forbidden"})
+			}
+
+			ctx.Set(principalKey, principal)
+			return next(ctx)
+		}
+	}
+}
+
+// errMissingCredentials is returned for requests without credentials of a supported scheme
+var errMissingCredentials = errors.New("missing bearer token or request signature")
+
+// authenticate identifies the caller by the credentials of the request's Authorization header
+func authenticate(ctx echo.Context, authService services.AuthService, serviceAuthService services.ServiceAuthService) (*models.Principal, error) {
+	header := ctx.Request().Header.Get(echo.HeaderAuthorization)
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok && token != "" {
+		return authService.Authenticate(token)
+	}
+	credentials, ok := strings.CutPrefix(header, models.ServiceAuthScheme+" ")
+	if !ok || credentials == "" {
+		return nil, errMissingCredentials
+	}
+
+	// Read the body for the signature and put it back for the handler
+	body, err := io.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Request().Body = io.NopCloser(bytes.NewReader(body))
+	return serviceAuthService.Authenticate(&models.SignedRequest{
+		Credentials:    credentials,
+		Method:         ctx.Request().Method,
+		URI:            ctx.Request().URL.RequestURI(),
+		IdempotencyKey: ctx.Request().Header.Get(IdempotencyKeyHeader),
+		Body:           body,
+	})
+}
+
+// admits reports whether the rule lets the principal call the route of ctx
+func (r RouteRule) admits(ctx echo.Context, principal *models.Principal) bool {
+	if r.Permission == models.PermissionAuthenticated || principal.Role.HasPermission(r.Permission) {
+		return true
+	}
+	if r.OwnerParam == "" || principal.Role != models.RoleCustomer {
+		return false
+	}
+	id, err := uuid.Parse(ctx.Param(r.OwnerParam))
+	return err == nil && id == principal.ID
+}
+
+// CurrentPrincipal returns the caller authenticated by Authorize, or nil on public routes
+func CurrentPrincipal(ctx echo.Context) *models.Principal {
+	principal, _ := ctx.Get(principalKey).(*models.Principal)
+	return principal
+}
+
+// unauthorized rejects the request with 401 and challenges for both supported schemes
+func unauthorized(ctx echo.Context, message string) error {
+	ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer, "+models.ServiceAuthScheme)
+	return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": message})
+}
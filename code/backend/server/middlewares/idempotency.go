@@ -0,0 +1,151 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+// IdempotencyKeyHeader is the request header carrying the client-chosen idempotency key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	maxIdempotencyKeyLength = 255
+	maxPrincipalLength      = 100
+)
+
+// IdempotencySettings configures the Idempotency middleware
+type IdempotencySettings struct {
+	// PendingTimeout is how long a key stays claimed by a request that has not completed. It must exceed
+	// the longest request, as the key is then assumed abandoned by a crashed server and can be claimed again.
+	PendingTimeout time.Duration
+	// TTL is how long a response is replayed; older records are reused as new keys and purged
+	TTL time.Duration
+}
+
+// Idempotency replays the stored response when a request is repeated with the same
+// Idempotency-Key header by the same caller, and rejects reuse of a key for a different
+// request with 409. Requests without the header pass through unchanged.
+func Idempotency(repo repositories.IdempotencyRepository, settings IdempotencySettings) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			key := ctx.Request().Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				return next(ctx)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Idempotency-Key is too long"})
+			}
+
+			// Read the body for the fingerprint and put it back for the handler
+			body, err := io.ReadAll(ctx.Request().Body)
+			if err != nil {
+				return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			ctx.Request().Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := requestFingerprint(ctx.Request(), body)
+
+			record := &models.IdempotencyRecord{Principal: idempotencyScope(ctx), Key: key, Fingerprint: fingerprint}
+			now := time.Now()
+			claimed, err := repo.ClaimRecord(record, now.Add(-settings.PendingTimeout), now.Add(-settings.TTL))
+			if err != nil {
+				return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			if !claimed {
+				return replayRecord(ctx, repo, record.Principal, key, fingerprint)
+			}
+
+			// Capture the response while it is written to the client
+			var captured bytes.Buffer
+			writer := ctx.Response().Writer
+			ctx.Response().Writer = &captureResponseWriter{Writer: io.MultiWriter(writer, &captured), ResponseWriter: writer}
+
+			if err := next(ctx); err != nil {
+				ctx.Error(err)
+			}
+
+			// Server errors are not stored, so the client may retry with the same key
+			status := ctx.Response().Status
+			if status >= http.StatusInternalServerError {
+				if err := repo.DeleteRecord(record); err != nil {
+					ctx.Logger().Errorf("failed to release idempotency key %s: %v", key, err)
+				}
+				return nil
+			}
+
+			record.StatusCode = status
+			record.ResponseBody = captured.String()
+			stored, err := repo.CompleteRecord(record)
+			if err != nil {
+				ctx.Logger().Errorf("failed to store response for idempotency key %s: %v", key, err)
+			} else if !stored {
+				ctx.Logger().Errorf("idempotency key %s was reclaimed before its request completed; raise the pending timeout", key)
+			}
+			return nil
+		}
+	}
+}
+
+// replayRecord answers a request whose key is already taken
+func replayRecord(ctx echo.Context, repo repositories.IdempotencyRepository, principal, key, fingerprint string) error {
+	record, err := repo.GetRecord(principal, key)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if record == nil {
+		// The original request failed and released the key in the meantime
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Request with this Idempotency-Key was interrupted, please retry"})
+	}
+	if record.Fingerprint != fingerprint {
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Idempotency-Key was already used for a different request"})
+	}
+	if record.StatusCode == 0 {
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Request with this Idempotency-Key is still in progress"})
+	}
+	return ctx.Blob(record.StatusCode, echo.MIMEApplicationJSON, []byte(record.ResponseBody))
+}
+
+// idempotencyScope identifies the caller whose keys the request's key is compared with. Anonymous
+// requests share one scope.
+func idempotencyScope(ctx echo.Context) string {
+	principal := CurrentPrincipal(ctx)
+	if principal == nil {
+		return ""
+	}
+	actor := principal.Actor()
+	if len(actor) > maxPrincipalLength {
+		sum := sha256.Sum256([]byte(actor))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return actor
+}
+
+// requestFingerprint hashes the method, URI and body that identify a request
+func requestFingerprint(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, req.Method+" "+req.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// captureResponseWriter copies everything written to the response into Writer
+type captureResponseWriter struct {
+	io.Writer
+	http.ResponseWriter
+}
+
+func (w *captureResponseWriter) WriteHeader(code int) {
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *captureResponseWriter) Write(b []byte) (int, error) {
+	return w.Writer.Write(b)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/migrations"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+const migrateUsage = "usage: server migrate up|down [--force-data-loss]|status|to <version> [--force-data-loss]|force <version>"
+
+// forceDataLossFlag allows down and to to revert migrations that destroy data
+const forceDataLossFlag = "--force-data-loss"
+
+// runMigrateCommand executes the migrate subcommand with the arguments following "migrate"
+func runMigrateCommand(migrator *migrations.Migrator, transactionService services.TransactionService, args []string) error {
+	allowDataLoss := false
+	if len(args) > 0 && args[len(args)-1] == forceDataLossFlag {
+		allowDataLoss = true
+		args = args[:len(args)-1]
+		if len(args) == 0 || (args[0] != "down" && args[0] != "to") {
+			return fmt.Errorf(migrateUsage)
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		if err := migrator.Up(); err != nil {
+			return err
+		}
+		return rekeyAfterMigrating(transactionService)
+	case "down":
+		return migrator.Down(allowDataLoss)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-40s %s\n", status.Version, status.Name, applied)
+		}
+		return nil
+	case "to", "force":
+		if len(args) != 2 {
+			return fmt.Errorf(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+		if args[0] == "force" {
+			return migrator.Force(version)
+		}
+		return migrator.To(version, allowDataLoss)
+	}
+	return fmt.Errorf(migrateUsage)
+}
+
+// rekeyAfterMigrating keys the ledgers chained before LEDGER_SECRET existed, so that every deployment
+// running `server migrate up` accepts new transactions for their customers. Ledgers with broken chains
+// are printed without failing the migration; the server warns about them when it starts.
+func rekeyAfterMigrating(transactionService services.TransactionService) error {
+	report, err := transactionService.RekeyLedgers()
+	if err != nil {
+		return fmt.Errorf("rekeying the ledgers: %w", err)
+	}
+	if report.CustomersChecked == 0 {
+		return nil
+	}
+	printLedgerViolations(report.Violations)
+	fmt.Printf("rekeyed the ledgers of %d customers, %d violations\n",
+		report.CustomersChecked, len(report.Violations))
+	return nil
+}
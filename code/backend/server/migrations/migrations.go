@@ -0,0 +1,389 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var scripts embed.FS
+
+// lockName is the MySQL named lock that serializes migrations across replicas
+const lockName = "schema_migrations"
+
+// lockTimeoutSeconds is how long to wait for another process to finish migrating
+const lockTimeoutSeconds = 60
+
+// scriptPattern matches script names such as 0002_customer_registration_time.up.sql
+var scriptPattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// dataLossPrefix starts the comment line with which a down script declares the data it destroys
+const dataLossPrefix = "-- data-loss:"
+
+// createTableSQL creates the table recording applied migrations
+const createTableSQL = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version bigint NOT NULL,
+    name varchar(255) NOT NULL,
+    applied_at timestamp NULL DEFAULT current_timestamp,
+    PRIMARY KEY (version)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+
+// Migration is one versioned schema change with its up and down scripts. DataLoss describes the data
+// the down script destroys; such migrations are only reverted when data loss is allowed explicitly.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	DataLoss string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// schemaMigration is a row of the schema_migrations table
+type schemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time `gorm:"default:current_timestamp"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator applies the embedded migrations and records them in schema_migrations
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator loads the embedded migration scripts
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(scripts)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// loadMigrations pairs the up and down scripts of each version, ordered by version
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := scriptPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+			migration.DataLoss = declaredDataLoss(migration.Down)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d is missing its up or down script", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// LatestVersion returns the highest version known to this binary
+func (m *Migrator) LatestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status lists every known migration along with when it was applied, if it was
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.appliedMigrations(m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// CheckUpToDate returns an error if any known migration has not been applied
+func (m *Migrator) CheckUpToDate() error {
+	applied, err := m.appliedMigrations(m.db)
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, strconv.Itoa(migration.Version))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is behind, pending migrations: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up() error {
+	return m.To(m.LatestVersion(), false)
+}
+
+// Down reverts the most recently applied migration. It refuses to revert a migration that destroys
+// data unless allowDataLoss is set.
+func (m *Migrator) Down(allowDataLoss bool) error {
+	return m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; ok {
+				if err := checkDataLoss(m.migrations[i:i+1], allowDataLoss); err != nil {
+					return err
+				}
+				return m.revert(conn, m.migrations[i])
+			}
+		}
+		return nil
+	})
+}
+
+// To applies pending migrations up to and including version, and reverts applied
+// migrations above it. It refuses to revert any migration that destroys data unless
+// allowDataLoss is set, in which case nothing is reverted or applied.
+func (m *Migrator) To(version int, allowDataLoss bool) error {
+	if version != 0 && !m.isKnown(version) {
+		return fmt.Errorf("unknown migration version: %d", version)
+	}
+	return m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		var reverts []Migration
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; ok && migration.Version > version {
+				reverts = append(reverts, migration)
+			}
+		}
+		if err := checkDataLoss(reverts, allowDataLoss); err != nil {
+			return err
+		}
+		for _, migration := range reverts {
+			if err := m.revert(conn, migration); err != nil {
+				return err
+			}
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+				if err := m.apply(conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Force records every migration up to version as applied and the rest as pending,
+// without running any script. It is meant for adopting a database whose schema was
+// created before migrations were tracked.
+func (m *Migrator) Force(version int) error {
+	if version != 0 && !m.isKnown(version) {
+		return fmt.Errorf("unknown migration version: %d", version)
+	}
+	return m.withLock(func(conn *gorm.DB) error {
+		return conn.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("1 = 1").Delete(&schemaMigration{}).Error; err != nil {
+				return err
+			}
+			for _, migration := range m.migrations {
+				if migration.Version > version {
+					break
+				}
+				if err := tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// apply runs a migration's up script and records it
+func (m *Migrator) apply(conn *gorm.DB, migration Migration) error {
+	if err := execScript(conn, migration.Up); err != nil {
+		return fmt.Errorf("migration %d_%s up failed: %w", migration.Version, migration.Name, err)
+	}
+	return conn.Create(&schemaMigration{Version: migration.Version, Name: migration.Name}).Error
+}
+
+// checkDataLoss returns an error naming the migrations whose down scripts destroy data, unless
+// allowDataLoss is set
+func checkDataLoss(reverts []Migration, allowDataLoss bool) error {
+	if allowDataLoss {
+		return nil
+	}
+	var losses []string
+	for _, migration := range reverts {
+		if migration.DataLoss != "" {
+			losses = append(losses, fmt.Sprintf("%04d_%s %s", migration.Version, migration.Name, migration.DataLoss))
+		}
+	}
+	if len(losses) > 0 {
+		return fmt.Errorf("reverting would destroy data: %s; back up the database and rerun with --force-data-loss",
+			strings.Join(losses, "; "))
+	}
+	return nil
+}
+
+// declaredDataLoss returns the description of the data-loss comment of a down script, or "" if it has none
+func declaredDataLoss(script string) string {
+	for _, line := range strings.Split(script, "\n") {
+		if description, ok := strings.CutPrefix(strings.TrimSpace(line), dataLossPrefix); ok {
+			return strings.TrimSpace(description)
+		}
+	}
+	return ""
+}
+
+// revert runs a migration's down script and removes its record
+func (m *Migrator) revert(conn *gorm.DB, migration Migration) error {
+	if err := execScript(conn, migration.Down); err != nil {
+		return fmt.Errorf("migration %d_%s down failed: %w", migration.Version, migration.Name, err)
+	}
+	return conn.Delete(&schemaMigration{}, "version = ?", migration.Version).Error
+}
+
+// withLock runs fn on a single connection holding the migration lock, so that
+// replicas starting at the same time do not migrate concurrently
+func (m *Migrator) withLock(fn func(conn *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		var acquired int
+		if err := conn.Raw("SELECT GET_LOCK(?, ?)", lockName, lockTimeoutSeconds).Scan(&acquired).Error; err != nil {
+			return err
+		}
+		if acquired != 1 {
+			return fmt.Errorf("timed out waiting for the migration lock")
+		}
+		defer conn.Exec("SELECT RELEASE_LOCK(?)", lockName)
+
+		if err := conn.Exec(createTableSQL).Error; err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+// appliedMigrations returns the recorded migrations keyed by version
+func (m *Migrator) appliedMigrations(conn *gorm.DB) (map[int]schemaMigration, error) {
+	applied := make(map[int]schemaMigration)
+	if !conn.Migrator().HasTable(&schemaMigration{}) {
+		return applied, nil
+	}
+	var rows []schemaMigration
+	if err := conn.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// isKnown reports whether version belongs to an embedded migration
+func (m *Migrator) isKnown(version int) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// execScript runs each statement of a script in order. Statements end with a
+// semicolon at the end of a line.
+func execScript(conn *gorm.DB, script string) error {
+	for _, statement := range splitStatements(script) {
+		if err := conn.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements splits a script on semicolons that end a line, dropping
+// chunks that contain only comments or whitespace
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	hasCode := false
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			hasCode = true
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") && !strings.HasPrefix(trimmed, "--") {
+			if hasCode {
+				statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			}
+			current.Reset()
+			hasCode = false
+		}
+	}
+	if hasCode {
+		statements = append(statements, strings.TrimSpace(current.String()))
+	}
+	return statements
+}
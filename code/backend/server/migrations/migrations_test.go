@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrationsDataLoss(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0001_create.up.sql":   {Data: []byte("CREATE TABLE things (id int);\n")},
+		"sql/0001_create.down.sql": {Data: []byte("-- data-loss: drops every thing\nDROP TABLE things;\n")},
+		"sql/0002_index.up.sql":    {Data: []byte("ALTER TABLE things ADD KEY idx_things_id (id);\n")},
+		"sql/0002_index.down.sql":  {Data: []byte("-- Only the index goes\nALTER TABLE things DROP KEY idx_things_id;\n")},
+	}
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("loaded %d migrations, want 2", len(migrations))
+	}
+	if got := migrations[0].DataLoss; got != "drops every thing" {
+		t.Errorf("DataLoss of 0001 = %q, want %q", got, "drops every thing")
+	}
+	if got := migrations[1].DataLoss; got != "" {
+		t.Errorf("DataLoss of 0002 = %q, want none", got)
+	}
+}
+
+func TestCheckDataLoss(t *testing.T) {
+	lossy := Migration{Version: 7, Name: "transaction_types", DataLoss: "deletes every refund"}
+	safe := Migration{Version: 9, Name: "transaction_customer_time_index"}
+
+	tests := []struct {
+		name          string
+		reverts       []Migration
+		allowDataLoss bool
+		wantErr       string
+	}{
+		{name: "nothing to revert"},
+		{name: "no data loss", reverts: []Migration{safe}},
+		{name: "data loss refused", reverts: []Migration{safe, lossy}, wantErr: "0007_transaction_types deletes every refund"},
+		{name: "data loss forced", reverts: []Migration{safe, lossy}, allowDataLoss: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDataLoss(tt.reverts, tt.allowDataLoss)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkDataLoss() returned error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "--force-data-loss") {
+				t.Fatalf("checkDataLoss() error = %v, want one naming %q and --force-data-loss", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEmbeddedDataLossDeclarations(t *testing.T) {
+	migrations, err := loadMigrations(scripts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, migration := range migrations {
+		deletes := strings.Contains(migration.Down, "DELETE FROM") || strings.Contains(migration.Down, "DROP TABLE") ||
+			strings.Contains(migration.Down, "DROP COLUMN")
+		if deletes && migration.DataLoss == "" {
+			t.Errorf("migration %04d_%s destroys data in its down script without a %q comment",
+				migration.Version, migration.Name, dataLossPrefix)
+		}
+	}
+}
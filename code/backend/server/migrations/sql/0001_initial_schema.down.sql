@@ -0,0 +1,3 @@
+-- data-loss: drops every customer and transaction
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS customers;
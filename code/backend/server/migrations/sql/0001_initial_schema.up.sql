@@ -0,0 +1,21 @@
+-- Tables as created by the original AutoMigrate models
+CREATE TABLE IF NOT EXISTS customers (
+    id char(36) NOT NULL,
+    name varchar(255) NOT NULL,
+    password varchar(255) NOT NULL,
+    email varchar(255) NOT NULL,
+    gender enum('male','female','other') NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uni_customers_email (email)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id char(36) NOT NULL,
+    customer_id char(36) NOT NULL,
+    amount decimal(10,2) NOT NULL,
+    `time` timestamp NULL DEFAULT current_timestamp,
+    created_at timestamp NULL DEFAULT current_timestamp,
+    PRIMARY KEY (id),
+    KEY idx_transactions_customer_id (customer_id),
+    CONSTRAINT fk_customers_transactions FOREIGN KEY (customer_id) REFERENCES customers (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,2 @@
+-- data-loss: drops the registration time of every customer
+ALTER TABLE customers DROP COLUMN created_at;
@@ -0,0 +1,9 @@
+ALTER TABLE customers ADD COLUMN created_at timestamp NULL DEFAULT current_timestamp;
+
+-- Existing customers get the migration time; move it back to their first transaction
+-- so that every transaction stays after registration
+UPDATE customers
+JOIN (SELECT customer_id, MIN(`time`) AS first_time FROM transactions GROUP BY customer_id) AS firsts
+    ON firsts.customer_id = customers.id
+SET customers.created_at = firsts.first_time
+WHERE firsts.first_time < customers.created_at;
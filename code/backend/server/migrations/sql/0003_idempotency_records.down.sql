@@ -0,0 +1,2 @@
+-- data-loss: drops the stored idempotency records
+DROP TABLE idempotency_records;
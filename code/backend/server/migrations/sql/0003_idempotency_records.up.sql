@@ -0,0 +1,8 @@
+CREATE TABLE idempotency_records (
+    idempotency_key varchar(255) NOT NULL,
+    fingerprint char(64) NOT NULL,
+    status_code bigint NOT NULL DEFAULT 0,
+    response_body longtext,
+    created_at timestamp NULL DEFAULT current_timestamp,
+    PRIMARY KEY (idempotency_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
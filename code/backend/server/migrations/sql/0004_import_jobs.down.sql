@@ -0,0 +1,2 @@
+-- data-loss: drops the import job history
+DROP TABLE import_jobs;
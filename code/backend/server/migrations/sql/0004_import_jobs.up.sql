@@ -0,0 +1,16 @@
+CREATE TABLE import_jobs (
+    id char(36) NOT NULL,
+    kind varchar(32) NOT NULL,
+    format varchar(16) NOT NULL,
+    status varchar(16) NOT NULL,
+    processed bigint NOT NULL DEFAULT 0,
+    accepted bigint NOT NULL DEFAULT 0,
+    rejected bigint NOT NULL DEFAULT 0,
+    errors text,
+    message text,
+    created_at timestamp NULL DEFAULT current_timestamp,
+    updated_at timestamp NULL DEFAULT current_timestamp,
+    finished_at timestamp NULL,
+    PRIMARY KEY (id),
+    KEY idx_import_jobs_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
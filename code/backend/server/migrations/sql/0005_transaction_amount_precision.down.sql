@@ -0,0 +1,2 @@
+-- Fails if any amount no longer fits decimal(10,2)
+ALTER TABLE transactions MODIFY amount decimal(10,2) NOT NULL;
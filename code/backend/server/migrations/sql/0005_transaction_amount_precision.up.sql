@@ -0,0 +1,2 @@
+-- Widen amounts beyond 99,999,999.99; values are kept exactly
+ALTER TABLE transactions MODIFY amount decimal(18,2) NOT NULL;
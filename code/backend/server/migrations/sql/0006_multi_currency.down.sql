@@ -0,0 +1,3 @@
+-- data-loss: drops the FX rates and the currency of every transaction
+DROP TABLE fx_rates;
+ALTER TABLE transactions DROP COLUMN currency;
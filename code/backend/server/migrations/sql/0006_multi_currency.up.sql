@@ -0,0 +1,9 @@
+-- Existing transactions were all recorded in TWD
+ALTER TABLE transactions ADD COLUMN currency char(3) NOT NULL DEFAULT 'TWD' AFTER amount;
+CREATE TABLE fx_rates (
+    currency char(3) NOT NULL,
+    effective_date date NOT NULL,
+    rate decimal(18,8) NOT NULL,
+    updated_at timestamp NULL DEFAULT current_timestamp,
+    PRIMARY KEY (currency, effective_date)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,8 @@
+-- data-loss: deletes every refund, reversal and adjustment, breaking the ledger chains
+-- Refunds, reversals and adjustments cannot be represented without a type
+DELETE FROM transactions WHERE type <> 'purchase';
+ALTER TABLE transactions DROP FOREIGN KEY fk_transactions_original;
+ALTER TABLE transactions
+    DROP KEY idx_transactions_original_transaction_id,
+    DROP COLUMN original_transaction_id,
+    DROP COLUMN type;
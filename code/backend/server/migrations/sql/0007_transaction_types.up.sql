@@ -0,0 +1,6 @@
+-- Every existing transaction is a purchase
+ALTER TABLE transactions
+    ADD COLUMN type varchar(16) NOT NULL DEFAULT 'purchase' AFTER customer_id,
+    ADD COLUMN original_transaction_id char(36) NULL AFTER type,
+    ADD KEY idx_transactions_original_transaction_id (original_transaction_id),
+    ADD CONSTRAINT fk_transactions_original FOREIGN KEY (original_transaction_id) REFERENCES transactions (id) ON DELETE CASCADE;
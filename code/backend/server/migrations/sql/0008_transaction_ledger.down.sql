@@ -0,0 +1,7 @@
+-- data-loss: drops the audit trail and the ledger hash chains
+DROP TABLE transaction_audits;
+ALTER TABLE transactions
+    DROP KEY uni_transactions_customer_ledger_seq,
+    DROP COLUMN entry_hash,
+    DROP COLUMN prev_hash,
+    DROP COLUMN ledger_seq;
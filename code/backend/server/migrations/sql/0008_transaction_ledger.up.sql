@@ -0,0 +1,32 @@
+ALTER TABLE transactions
+    ADD COLUMN ledger_seq bigint NOT NULL DEFAULT 0 AFTER currency,
+    ADD COLUMN prev_hash char(64) NOT NULL DEFAULT '' AFTER ledger_seq,
+    ADD COLUMN entry_hash char(64) NOT NULL DEFAULT '' AFTER prev_hash;
+
+-- Chain the existing transactions of each customer in time order. Assignments run left to
+-- right and see the values assigned before them; the hashed fields match Transaction.ComputeHash.
+-- @customer starts out NULL so the first row compares as a new customer.
+SET @customer := NULL, @seq := 0, @prev := NULL;
+UPDATE transactions
+SET ledger_seq = (@seq := IF(customer_id = @customer, @seq + 1, 1)),
+    prev_hash = IF(customer_id = @customer, @prev, REPEAT('0', 64)),
+    entry_hash = (@prev := SHA2(CONCAT_WS('|', prev_hash, id, customer_id, ledger_seq, type,
+        IFNULL(original_transaction_id, ''), amount, currency, UNIX_TIMESTAMP(`time`)), 256)),
+    customer_id = (@customer := customer_id)
+ORDER BY customer_id, `time`, created_at, id;
+
+ALTER TABLE transactions ADD UNIQUE KEY uni_transactions_customer_ledger_seq (customer_id, ledger_seq);
+
+CREATE TABLE transaction_audits (
+    id bigint unsigned NOT NULL AUTO_INCREMENT,
+    transaction_id char(36) NOT NULL,
+    action varchar(16) NOT NULL,
+    actor varchar(255) NOT NULL,
+    reason text,
+    `before` text,
+    `after` text,
+    created_at timestamp NULL DEFAULT current_timestamp,
+    PRIMARY KEY (id),
+    KEY idx_transaction_audits_transaction_id (transaction_id),
+    CONSTRAINT fk_transaction_audits_transaction FOREIGN KEY (transaction_id) REFERENCES transactions (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+ALTER TABLE transactions
+    ADD KEY idx_transactions_customer_id (customer_id),
+    DROP KEY idx_transactions_customer_time;
@@ -0,0 +1,4 @@
+-- Serve per-customer listings ordered by time, and keyset pagination on (time, id), from the index
+ALTER TABLE transactions
+    ADD KEY idx_transactions_customer_time (customer_id, `time`, id),
+    DROP KEY idx_transactions_customer_id;
@@ -0,0 +1,3 @@
+-- data-loss: drops the RFM runs and scores
+DROP TABLE customer_rfm_scores;
+DROP TABLE rfm_runs;
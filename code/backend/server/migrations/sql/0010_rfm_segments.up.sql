@@ -0,0 +1,28 @@
+CREATE TABLE rfm_runs (
+    id bigint unsigned NOT NULL AUTO_INCREMENT,
+    triggered_by varchar(16) NOT NULL,
+    window_name varchar(32) NOT NULL,
+    window_from timestamp NULL,
+    window_to timestamp NULL,
+    currency char(3) NOT NULL,
+    customers bigint NOT NULL DEFAULT 0,
+    created_at timestamp NULL DEFAULT current_timestamp,
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Results of the latest run only; every run replaces them
+CREATE TABLE customer_rfm_scores (
+    customer_id char(36) NOT NULL,
+    run_id bigint unsigned NOT NULL,
+    recency_days int NOT NULL,
+    frequency bigint NOT NULL,
+    monetary decimal(18,2) NOT NULL,
+    r_score tinyint NOT NULL,
+    f_score tinyint NOT NULL,
+    m_score tinyint NOT NULL,
+    segment varchar(32) NOT NULL,
+    PRIMARY KEY (customer_id),
+    KEY idx_customer_rfm_scores_segment_monetary (segment, monetary, customer_id),
+    CONSTRAINT fk_customer_rfm_scores_customer FOREIGN KEY (customer_id) REFERENCES customers (id) ON DELETE CASCADE,
+    CONSTRAINT fk_customer_rfm_scores_run FOREIGN KEY (run_id) REFERENCES rfm_runs (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
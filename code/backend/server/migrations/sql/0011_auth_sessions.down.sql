@@ -0,0 +1,2 @@
+-- data-loss: drops every login session
+DROP TABLE auth_sessions;
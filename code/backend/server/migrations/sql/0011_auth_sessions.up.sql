@@ -0,0 +1,12 @@
+-- Customer logins; revoking a session invalidates its access and refresh tokens
+CREATE TABLE auth_sessions (
+    id char(36) NOT NULL,
+    customer_id char(36) NOT NULL,
+    refresh_id char(36) NOT NULL,
+    expires_at timestamp NULL,
+    revoked_at timestamp NULL,
+    created_at timestamp NULL DEFAULT current_timestamp,
+    PRIMARY KEY (id),
+    KEY idx_auth_sessions_customer_id (customer_id),
+    CONSTRAINT fk_auth_sessions_customer FOREIGN KEY (customer_id) REFERENCES customers (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
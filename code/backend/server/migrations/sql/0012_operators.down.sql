@@ -0,0 +1,9 @@
+-- data-loss: drops the operator accounts and their sessions
+DELETE FROM auth_sessions WHERE customer_id IS NULL;
+ALTER TABLE auth_sessions
+    DROP FOREIGN KEY fk_auth_sessions_operator,
+    DROP KEY idx_auth_sessions_operator_id,
+    DROP COLUMN role,
+    DROP COLUMN operator_id,
+    MODIFY customer_id char(36) NOT NULL;
+DROP TABLE operators;
@@ -0,0 +1,20 @@
+-- Staff accounts; customers authenticate against customers instead
+CREATE TABLE operators (
+    id char(36) NOT NULL,
+    name varchar(255) NOT NULL,
+    email varchar(255) NOT NULL,
+    password varchar(255) NOT NULL,
+    role enum('admin','operator') NOT NULL,
+    disabled_at timestamp NULL,
+    created_at timestamp NULL DEFAULT current_timestamp,
+    PRIMARY KEY (id),
+    UNIQUE KEY uni_operators_email (email)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- A session belongs to either a customer or an operator, with the role it was started with
+ALTER TABLE auth_sessions
+    MODIFY customer_id char(36) NULL,
+    ADD COLUMN operator_id char(36) NULL AFTER customer_id,
+    ADD COLUMN role varchar(32) NOT NULL DEFAULT 'customer' AFTER operator_id,
+    ADD KEY idx_auth_sessions_operator_id (operator_id),
+    ADD CONSTRAINT fk_auth_sessions_operator FOREIGN KEY (operator_id) REFERENCES operators (id) ON DELETE CASCADE;
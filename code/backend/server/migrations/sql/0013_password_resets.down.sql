@@ -0,0 +1,2 @@
+-- data-loss: drops the password reset tokens
+DROP TABLE password_reset_tokens;
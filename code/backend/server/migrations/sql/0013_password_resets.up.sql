@@ -0,0 +1,13 @@
+-- Single-use password reset tokens; only the SHA-256 of a token is stored
+CREATE TABLE password_reset_tokens (
+    id char(36) NOT NULL,
+    customer_id char(36) NOT NULL,
+    token_hash char(64) NOT NULL,
+    expires_at timestamp NULL,
+    used_at timestamp NULL,
+    created_at timestamp NULL DEFAULT current_timestamp,
+    PRIMARY KEY (id),
+    UNIQUE KEY uni_password_reset_tokens_token_hash (token_hash),
+    KEY idx_password_reset_tokens_customer_id (customer_id),
+    CONSTRAINT fk_password_reset_tokens_customer FOREIGN KEY (customer_id) REFERENCES customers (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
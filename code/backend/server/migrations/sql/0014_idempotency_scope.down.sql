@@ -0,0 +1,8 @@
+-- data-loss: deletes the stored idempotency records
+-- The same key may have been used by several callers; the records are only a replay cache
+DELETE FROM idempotency_records;
+ALTER TABLE idempotency_records
+    DROP INDEX idx_idempotency_records_created_at,
+    DROP PRIMARY KEY,
+    DROP COLUMN principal,
+    ADD PRIMARY KEY (idempotency_key);
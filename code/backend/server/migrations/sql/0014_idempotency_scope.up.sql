@@ -0,0 +1,6 @@
+-- Keys are scoped to the caller that used them; existing records predate the scope and belong to nobody
+ALTER TABLE idempotency_records
+    ADD COLUMN principal varchar(100) NOT NULL DEFAULT '' FIRST,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (principal, idempotency_key),
+    ADD INDEX idx_idempotency_records_created_at (created_at);
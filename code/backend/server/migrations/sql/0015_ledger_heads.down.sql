@@ -0,0 +1,7 @@
+-- data-loss: drops the signed ledger heads, so removed ledger entries are no longer detected
+ALTER TABLE transaction_audits
+    DROP FOREIGN KEY fk_transaction_audits_transaction,
+    ADD CONSTRAINT fk_transaction_audits_transaction FOREIGN KEY (transaction_id) REFERENCES transactions (id) ON DELETE CASCADE;
+
+-- Ledgers stay chained under the ledger key, which the previous verifier does not know
+DROP TABLE ledger_heads;
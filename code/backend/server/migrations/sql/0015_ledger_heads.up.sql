@@ -0,0 +1,17 @@
+-- Signed pointers to the last entry of each ledger. Existing ledgers get theirs from
+-- `server ledger rekey`, which rechains them under the ledger key.
+CREATE TABLE ledger_heads (
+    customer_id char(36) NOT NULL,
+    ledger_seq bigint NOT NULL,
+    entry_hash char(64) NOT NULL,
+    key_id varchar(16) NOT NULL,
+    head_mac char(64) NOT NULL,
+    updated_at timestamp NULL DEFAULT current_timestamp,
+    PRIMARY KEY (customer_id),
+    CONSTRAINT fk_ledger_heads_customer FOREIGN KEY (customer_id) REFERENCES customers (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Deleting an audited transaction must not silently take its audit trail along
+ALTER TABLE transaction_audits
+    DROP FOREIGN KEY fk_transaction_audits_transaction,
+    ADD CONSTRAINT fk_transaction_audits_transaction FOREIGN KEY (transaction_id) REFERENCES transactions (id) ON DELETE RESTRICT;
@@ -0,0 +1,3 @@
+-- data-loss: drops the principal that started each import job
+ALTER TABLE import_jobs
+    DROP COLUMN started_by;
@@ -0,0 +1,3 @@
+-- Imported ledger entries are audited under the principal that uploaded the file; existing jobs predate it
+ALTER TABLE import_jobs
+    ADD COLUMN started_by varchar(255) NOT NULL DEFAULT '' AFTER status;
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	WindowPastYear     = "past_year"
+	WindowLast30Days   = "30d"
+	WindowLast90Days   = "90d"
+	WindowLast365Days  = "365d"
+	WindowCalendarYear = "calendar_year"
+	WindowCustom       = "custom"
+)
+
+// maxWindowLength bounds custom windows so a single request cannot aggregate unbounded history
+const maxWindowLength = 10 * 366 * 24 * time.Hour
+
+// AggregationWindow is the half-open interval [From, To) over which transaction totals are summed
+type AggregationWindow struct {
+	Name string    `json:"name"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// PastYearWindow returns the default window: from the start of the day one year ago until now
+func PastYearWindow(now time.Time) *AggregationWindow {
+	return &AggregationWindow{
+		Name: WindowPastYear,
+		From: now.AddDate(-1, 0, 0).Truncate(24 * time.Hour),
+		To:   now,
+	}
+}
+
+// ParseAggregationWindow builds a window from its name and, depending on the name,
+// an explicit from/to pair or a calendar year. An empty name selects the past year.
+func ParseAggregationWindow(name, from, to, year string, now time.Time) (*AggregationWindow, error) {
+	switch name {
+	case "", WindowPastYear:
+		return PastYearWindow(now), nil
+	case WindowLast30Days:
+		return lastDaysWindow(name, 30, now), nil
+	case WindowLast90Days:
+		return lastDaysWindow(name, 90, now), nil
+	case WindowLast365Days:
+		return lastDaysWindow(name, 365, now), nil
+	case WindowCalendarYear:
+		y := now.Year()
+		if year != "" {
+			parsed, err := strconv.Atoi(year)
+			if err != nil || parsed < 1970 || parsed > 9999 {
+				return nil, fmt.Errorf("invalid year: %s", year)
+			}
+			y = parsed
+		}
+		start := time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return &AggregationWindow{Name: name, From: start, To: start.AddDate(1, 0, 0)}, nil
+	case WindowCustom:
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("custom window requires both from and to")
+		}
+		fromTime, err := parseWindowBound(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from: %s", from)
+		}
+		toTime, err := parseWindowBound(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to: %s", to)
+		}
+		if !fromTime.Before(toTime) {
+			return nil, fmt.Errorf("from must be before to")
+		}
+		if toTime.Sub(fromTime) > maxWindowLength {
+			return nil, fmt.Errorf("window must not exceed 10 years")
+		}
+		return &AggregationWindow{Name: name, From: fromTime, To: toTime}, nil
+	}
+	return nil, fmt.Errorf("invalid window: %s", name)
+}
+
+// lastDaysWindow returns a window covering the given number of days up to now
+func lastDaysWindow(name string, days int, now time.Time) *AggregationWindow {
+	return &AggregationWindow{Name: name, From: now.AddDate(0, 0, -days), To: now}
+}
+
+// parseWindowBound accepts an RFC3339 timestamp or a YYYY-MM-DD date (midnight UTC)
+func parseWindowBound(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BucketSize string
+
+const (
+	BucketDay     BucketSize = "day"
+	BucketWeek    BucketSize = "week"
+	BucketMonth   BucketSize = "month"
+	BucketQuarter BucketSize = "quarter"
+)
+
+// MaxBuckets bounds the number of buckets a single analytics request may produce
+const MaxBuckets = 1000
+
+// IsValid reports whether the bucket size is one of the supported sizes
+func (b BucketSize) IsValid() bool {
+	switch b {
+	case BucketDay, BucketWeek, BucketMonth, BucketQuarter:
+		return true
+	}
+	return false
+}
+
+// start returns the start of the bucket containing t in loc; weeks start on Monday
+func (b BucketSize) start(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch b {
+	case BucketWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case BucketMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	case BucketQuarter:
+		return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// next returns the start of the bucket following the one starting at start
+func (b BucketSize) next(start time.Time) time.Time {
+	switch b {
+	case BucketWeek:
+		return start.AddDate(0, 0, 7)
+	case BucketMonth:
+		return start.AddDate(0, 1, 0)
+	case BucketQuarter:
+		return start.AddDate(0, 3, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// TimeBucket is the half-open interval [Start, End) of one bucket
+type TimeBucket struct {
+	Start time.Time
+	End   time.Time
+}
+
+// SplitBuckets splits [from, to) into consecutive buckets aligned to calendar boundaries in loc.
+// The first and last buckets are clipped to the range.
+func SplitBuckets(from, to time.Time, size BucketSize, loc *time.Location) ([]TimeBucket, error) {
+	var buckets []TimeBucket
+	for start := from; start.Before(to); {
+		end := size.next(size.start(start, loc))
+		if end.After(to) {
+			end = to
+		}
+		if len(buckets) == MaxBuckets {
+			return nil, fmt.Errorf("range must not span more than %d buckets of one %s", MaxBuckets, size)
+		}
+		buckets = append(buckets, TimeBucket{Start: start, End: end})
+		start = end
+	}
+	return buckets, nil
+}
+
+// ReportQuery describes a range split into buckets in a time zone, with amounts in Currency
+type ReportQuery struct {
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+	Bucket   BucketSize
+	Currency Currency
+}
+
+// AnalyticsQuery describes a request for a customer's spending statistics over a range
+type AnalyticsQuery struct {
+	CustomerID uuid.UUID
+	ReportQuery
+}
+
+// SpendingStats summarizes the net amounts of the transactions in one interval.
+// Refunds and reversals count as negative amounts; percentiles use the nearest-rank method.
+// All amounts are zero when the interval has no transactions.
+type SpendingStats struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Count int64     `json:"count"`
+	Sum   Money     `json:"sum"`
+	Avg   Money     `json:"avg"`
+	Min   Money     `json:"min"`
+	Max   Money     `json:"max"`
+	P50   Money     `json:"p50"`
+	P90   Money     `json:"p90"`
+	P99   Money     `json:"p99"`
+}
+
+// CustomerAnalytics is the response of the customer analytics endpoint
+type CustomerAnalytics struct {
+	CustomerID uuid.UUID        `json:"customer_id"`
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+	TimeZone   string           `json:"tz"`
+	Bucket     BucketSize       `json:"bucket"`
+	Currency   Currency         `json:"currency"`
+	Summary    *SpendingStats   `json:"summary"`
+	Buckets    []*SpendingStats `json:"buckets"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// AuthSession is a login of a customer or an operator, whose ID is set accordingly, with the role it was
+// started with. Its refresh token rotates on every refresh; only the token carrying the current RefreshID
+// is accepted. Revoking the session invalidates all its tokens.
+type AuthSession struct {
+	ID         uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	CustomerID *uuid.UUID `gorm:"type:char(36);index" json:"customer_id"`
+	OperatorID *uuid.UUID `gorm:"type:char(36);index" json:"operator_id"`
+	Role       Role       `gorm:"type:varchar(32);not null;default:customer" json:"role"`
+	RefreshID  uuid.UUID  `gorm:"type:char(36);not null" json:"-"`
+	ExpiresAt  time.Time  `gorm:"type:timestamp" json:"expires_at"`
+	RevokedAt  *time.Time `gorm:"type:timestamp NULL" json:"revoked_at"`
+	CreatedAt  time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
+
+// TableName overrides the table name used by AuthSession
+func (AuthSession) TableName() string {
+	return "auth_sessions"
+}
+
+// PrincipalID returns the ID of the customer or operator the session belongs to
+func (s *AuthSession) PrincipalID() uuid.UUID {
+	if s.OperatorID != nil {
+		return *s.OperatorID
+	}
+	if s.CustomerID != nil {
+		return *s.CustomerID
+	}
+	return uuid.Nil
+}
+
+// IsActive reports whether the session is neither revoked nor expired at now
+func (s *AuthSession) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// LoginRequest is the body of a login request
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest is the body of a token refresh request
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenPair is the response of a successful login or refresh; ExpiresIn is the access token's lifetime in seconds
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultCohortMonths = 12
+	MaxCohortMonths     = 60
+	MaxCohorts          = 120
+)
+
+// CohortQuery describes a retention report over the customers registering in [From, To).
+// From and To are month starts in Location; they are zero when the range is left to the default.
+type CohortQuery struct {
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+	Months   int // months after registration to follow each cohort for
+	Currency Currency
+}
+
+// MonthStart returns the start of the calendar month containing t in loc
+func MonthStart(t time.Time, loc *time.Location) time.Time {
+	return BucketMonth.start(t, loc)
+}
+
+// ParseCohortMonth parses a YYYY-MM month into its start in loc
+func ParseCohortMonth(value string, loc *time.Location) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month: %s, expected YYYY-MM", value)
+	}
+	return t, nil
+}
+
+// CohortCell is the activity of a cohort in its Offset-th month after registration, month 0 being the
+// registration month. Retention is the share of the cohort making purchases in that month.
+type CohortCell struct {
+	Offset          int       `json:"offset"`
+	Start           time.Time `json:"start"`
+	ActiveCustomers int64     `json:"active_customers"`
+	Retention       float64   `json:"retention"`
+	Revenue         Money     `json:"revenue"`
+}
+
+// Cohort is the customers registering in one month; Months only covers months that have begun
+type Cohort struct {
+	Month     string        `json:"cohort"`
+	Start     time.Time     `json:"start"`
+	Customers int64         `json:"customers"`
+	Months    []*CohortCell `json:"months"`
+}
+
+// CohortRetentionReport is the response of the cohort retention report
+type CohortRetentionReport struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	TimeZone string    `json:"tz"`
+	Months   int       `json:"months"`
+	Currency Currency  `json:"currency"`
+	Cohorts  []*Cohort `json:"cohorts"`
+}
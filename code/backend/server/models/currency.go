@@ -0,0 +1,28 @@
+package models
+
+import "strings"
+
+type Currency string
+
+const (
+	TWD Currency = "TWD"
+	USD Currency = "USD"
+	JPY Currency = "JPY"
+)
+
+// BaseCurrency is the currency FX rates are quoted in; it converts to itself at a rate of 1
+const BaseCurrency = TWD
+
+// IsValid reports whether the currency is one of the supported currencies
+func (c Currency) IsValid() bool {
+	return c == TWD || c == USD || c == JPY
+}
+
+// ParseCurrency normalizes a currency code, defaulting an empty code to the base currency
+func ParseCurrency(code string) (Currency, bool) {
+	if code == "" {
+		return BaseCurrency, true
+	}
+	currency := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	return currency, currency.IsValid()
+}
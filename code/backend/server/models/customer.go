@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -12,11 +15,26 @@ const (
 	Other  Gender = "other"
 )
 
+// IsValid reports whether the gender is one of the supported values
+func (g Gender) IsValid() bool {
+	return g == Male || g == Female || g == Other
+}
+
 type Customer struct {
 	ID           uuid.UUID     `gorm:"type:char(36);primaryKey" json:"id"`
 	Name         string        `gorm:"type:varchar(255);not null" json:"name"`
 	Password     string        `gorm:"type:varchar(255);not null" json:"password"`
 	Email        string        `gorm:"type:varchar(255);unique;not null" json:"email"`
 	Gender       Gender        `gorm:"type:enum('male','female','other');not null" json:"gender"`
+	CreatedAt    time.Time     `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
 	Transactions []Transaction `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE"`
 }
+
+// MarshalJSON omits the password, which requests set but responses must never return in any form
+func (c Customer) MarshalJSON() ([]byte, error) {
+	type customer Customer
+	return json.Marshal(struct {
+		customer
+		Password string `json:"password,omitempty"`
+	}{customer: customer(c)})
+}
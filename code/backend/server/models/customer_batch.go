@@ -0,0 +1,29 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+type CustomerItemStatus string
+
+const (
+	CustomerItemCreated  CustomerItemStatus = "created"
+	CustomerItemRejected CustomerItemStatus = "rejected"
+)
+
+// CustomerItemResult reports the outcome for the customer at Index of a bulk request
+type CustomerItemResult struct {
+	Index   int                `json:"index"`
+	ID      *uuid.UUID         `json:"id,omitempty"`
+	Email   string             `json:"email"`
+	Status  CustomerItemStatus `json:"status"`
+	Reason  RejectReason       `json:"reason,omitempty"`
+	Message string             `json:"message,omitempty"`
+}
+
+// CustomerBatchResult reports the outcome of a bulk customer insert
+type CustomerBatchResult struct {
+	SuccessCount int                  `json:"successCount"`
+	FailCount    int                  `json:"failCount"`
+	Results      []CustomerItemResult `json:"results"`
+}
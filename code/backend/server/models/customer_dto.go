@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -9,5 +11,9 @@ type CustomerDTO struct {
 	Name                   string    `json:"name"`
 	Email                  string    `json:"email"`
 	Gender                 Gender    `json:"gender"`
-	TotalTransactionAmount float64   `json:"total_transaction_amount"`
+	TotalTransactionAmount Money     `json:"total_transaction_amount"`
+	Currency               Currency  `json:"currency" gorm:"-"` // currency of TotalTransactionAmount
+	CreatedAt              time.Time `json:"created_at"`
+	// Window is set on single-customer responses to report which window the total covers
+	Window *AggregationWindow `json:"window,omitempty" gorm:"-"`
 }
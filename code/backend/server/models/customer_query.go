@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type CustomerSortKey string
+
+const (
+	SortByName                   CustomerSortKey = "name"
+	SortByEmail                  CustomerSortKey = "email"
+	SortByGender                 CustomerSortKey = "gender"
+	SortByTotalTransactionAmount CustomerSortKey = "total_transaction_amount"
+)
+
+// IsValid reports whether the sort key is one of the supported keys
+func (k CustomerSortKey) IsValid() bool {
+	switch k {
+	case SortByName, SortByEmail, SortByGender, SortByTotalTransactionAmount:
+		return true
+	}
+	return false
+}
+
+type SortOrder string
+
+const (
+	Asc  SortOrder = "asc"
+	Desc SortOrder = "desc"
+)
+
+// IsValid reports whether the sort order is asc or desc
+func (o SortOrder) IsValid() bool {
+	return o == Asc || o == Desc
+}
+
+// CustomerQuery describes a page request against the customer list
+type CustomerQuery struct {
+	PageSize    int
+	Cursor      *CustomerCursor
+	SortBy      CustomerSortKey
+	Order       SortOrder
+	Gender      Gender
+	NamePrefix  string
+	EmailPrefix string
+	Segment     RFMSegment // customers scored into this segment by the latest RFM run
+	MinTotal    *Money
+	MaxTotal    *Money
+	Window      *AggregationWindow
+	Currency    Currency // reporting currency of totals and of the total filters
+}
+
+// CustomerCursor marks the last row of a page; the next page starts right after it
+type CustomerCursor struct {
+	SortBy   CustomerSortKey `json:"s"`
+	Order    SortOrder       `json:"o"`
+	Value    string          `json:"v"`
+	ID       uuid.UUID       `json:"id"`
+	Currency Currency        `json:"c"`
+}
+
+// Encode serializes the cursor into an opaque URL-safe token
+func (c *CustomerCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCustomerCursor parses a token produced by CustomerCursor.Encode
+func DecodeCustomerCursor(token string) (*CustomerCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cursor CustomerCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if !cursor.SortBy.IsValid() || !cursor.Order.IsValid() || !cursor.Currency.IsValid() {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &cursor, nil
+}
+
+// CustomerPage is the response envelope for a paginated customer list
+type CustomerPage struct {
+	Items      []*CustomerDTO     `json:"items"`
+	NextCursor string             `json:"next_cursor"`
+	TotalCount int64              `json:"total_count"`
+	Window     *AggregationWindow `json:"window"`
+	Currency   Currency           `json:"currency"`
+}
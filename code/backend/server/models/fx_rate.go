@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// fxRatePattern matches a positive rate that fits the decimal(18,8) column
+var fxRatePattern = regexp.MustCompile(`^\d{1,10}(\.\d{1,8})?$`)
+
+// FXRate is the value of one unit of Currency in BaseCurrency. It is in effect from
+// EffectiveDate until the next rate of the same currency.
+type FXRate struct {
+	Currency      Currency  `gorm:"type:char(3);primaryKey"`
+	EffectiveDate time.Time `gorm:"type:date;primaryKey"`
+	Rate          string    `gorm:"type:decimal(18,8);not null"`
+	UpdatedAt     time.Time `gorm:"type:timestamp;default:current_timestamp"`
+}
+
+func (FXRate) TableName() string {
+	return "fx_rates"
+}
+
+// FXRateDTO is the wire form of an FX rate, with the date as YYYY-MM-DD and the rate as a decimal string
+type FXRateDTO struct {
+	Currency      Currency `json:"currency"`
+	EffectiveDate string   `json:"effective_date"`
+	Rate          string   `json:"rate"`
+}
+
+// ToModel validates the DTO and converts it into an FXRate
+func (dto *FXRateDTO) ToModel() (*FXRate, error) {
+	currency, ok := ParseCurrency(string(dto.Currency))
+	if !ok || dto.Currency == "" {
+		return nil, fmt.Errorf("invalid currency: %q", dto.Currency)
+	}
+	if currency == BaseCurrency {
+		return nil, fmt.Errorf("%s is the base currency and always has a rate of 1", BaseCurrency)
+	}
+	date, err := time.Parse(time.DateOnly, strings.TrimSpace(dto.EffectiveDate))
+	if err != nil {
+		return nil, fmt.Errorf("invalid effective_date: %q, expected YYYY-MM-DD", dto.EffectiveDate)
+	}
+	rate := strings.TrimSpace(dto.Rate)
+	if !fxRatePattern.MatchString(rate) || strings.Trim(rate, "0.") == "" {
+		return nil, fmt.Errorf("invalid rate: %q, expected a positive decimal with up to 8 places", dto.Rate)
+	}
+	return &FXRate{Currency: currency, EffectiveDate: date, Rate: rate}, nil
+}
+
+// ToDTO converts the rate into its wire form
+func (r *FXRate) ToDTO() *FXRateDTO {
+	return &FXRateDTO{
+		Currency:      r.Currency,
+		EffectiveDate: r.EffectiveDate.Format(time.DateOnly),
+		Rate:          r.Rate,
+	}
+}
+
+// MissingFXRateError reports an amount that cannot be converted because no rate was in effect on its date
+type MissingFXRateError struct {
+	From Currency
+	To   Currency
+	Date time.Time
+}
+
+func (e *MissingFXRateError) Error() string {
+	return fmt.Sprintf("no FX rate in effect on %s to convert %s into %s", e.Date.Format(time.DateOnly), e.From, e.To)
+}
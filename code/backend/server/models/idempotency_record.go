@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyRecord stores the response to a request made with an Idempotency-Key header,
+// so that a retried request can be answered without executing it again
+type IdempotencyRecord struct {
+	// Principal is the actor of the caller that used the key, so that callers cannot see or block each other's keys
+	Principal    string    `gorm:"type:varchar(100);primaryKey"`
+	Key          string    `gorm:"column:idempotency_key;type:varchar(255);primaryKey"`
+	Fingerprint  string    `gorm:"type:char(64);not null"`
+	StatusCode   int       `gorm:"not null;default:0"` // zero while the original request is in progress
+	ResponseBody string    `gorm:"type:longtext"`
+	CreatedAt    time.Time `gorm:"type:timestamp;default:current_timestamp"` // when the request holding the key claimed it
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ImportKind string
+
+const (
+	ImportCustomers    ImportKind = "customers"
+	ImportTransactions ImportKind = "transactions"
+)
+
+// IsValid reports whether the import kind is one of the supported kinds
+func (k ImportKind) IsValid() bool {
+	return k == ImportCustomers || k == ImportTransactions
+}
+
+type ImportFormat string
+
+const (
+	FormatNDJSON ImportFormat = "ndjson"
+	FormatCSV    ImportFormat = "csv"
+)
+
+// IsValid reports whether the import format is one of the supported formats
+func (f ImportFormat) IsValid() bool {
+	return f == FormatNDJSON || f == FormatCSV
+}
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// IsFinished reports whether the job has reached a final status
+func (s JobStatus) IsFinished() bool {
+	return s == JobCompleted || s == JobFailed || s == JobCancelled
+}
+
+// ImportError describes a rejected row of an import, identified by its line in the upload
+type ImportError struct {
+	Line    int          `json:"line"`
+	Reason  RejectReason `json:"reason"`
+	Message string       `json:"message"`
+}
+
+// ImportJob tracks a background bulk import
+type ImportJob struct {
+	ID         uuid.UUID     `gorm:"type:char(36);primaryKey" json:"id"`
+	Kind       ImportKind    `gorm:"type:varchar(32);not null" json:"kind"`
+	Format     ImportFormat  `gorm:"type:varchar(16);not null" json:"format"`
+	Status     JobStatus     `gorm:"type:varchar(16);not null;index" json:"status"`
+	StartedBy  string        `gorm:"type:varchar(255);not null;default:''" json:"started_by"`
+	Processed  int           `gorm:"not null;default:0" json:"processed"`
+	Accepted   int           `gorm:"not null;default:0" json:"accepted"`
+	Rejected   int           `gorm:"not null;default:0" json:"rejected"`
+	Errors     []ImportError `gorm:"type:text;serializer:json" json:"errors"`
+	Message    string        `gorm:"type:text" json:"message,omitempty"`
+	CreatedAt  time.Time     `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+	UpdatedAt  time.Time     `gorm:"type:timestamp;default:current_timestamp" json:"updated_at"`
+	FinishedAt *time.Time    `gorm:"type:timestamp NULL" json:"finished_at"`
+}
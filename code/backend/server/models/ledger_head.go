@@ -0,0 +1,96 @@
+package models
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LedgerHead records the last entry of a customer's ledger, signed with the ledger key. Removing the last
+// entries of a ledger breaks the match between the head and the ledger, and the head cannot be moved back
+// without the key.
+type LedgerHead struct {
+	CustomerID uuid.UUID `gorm:"type:char(36);primaryKey"`
+	LedgerSeq  int64     `gorm:"not null"`
+	EntryHash  string    `gorm:"type:char(64);not null"`
+	KeyID      string    `gorm:"type:varchar(16);not null"`
+	HeadMAC    string    `gorm:"column:head_mac;type:char(64);not null"`
+	UpdatedAt  time.Time `gorm:"type:timestamp;default:current_timestamp"`
+}
+
+// ComputeMAC signs the head's position under key. The fields are prefixed so that a head can never be
+// mistaken for an entry hash.
+func (h *LedgerHead) ComputeMAC(key *LedgerKey) string {
+	return key.Sum("ledger-head", h.CustomerID.String(), strconv.FormatInt(h.LedgerSeq, 10), h.EntryHash)
+}
+
+// Sign points the head at the ledger's last entry and signs it under key
+func (h *LedgerHead) Sign(last *Transaction, key *LedgerKey) {
+	h.CustomerID = last.CustomerID
+	h.LedgerSeq = last.LedgerSeq
+	h.EntryHash = last.EntryHash
+	h.KeyID = key.ID
+	h.HeadMAC = h.ComputeMAC(key)
+}
+
+// Verify reports whether the head was signed under key
+func (h *LedgerHead) Verify(key *LedgerKey) bool {
+	return h.KeyID == key.ID && hmac.Equal([]byte(h.HeadMAC), []byte(h.ComputeMAC(key)))
+}
+
+// CheckChain recomputes the hash chain of a customer's entries, in ledger order, under key and reports
+// the entries that were changed, removed or inserted
+func CheckChain(customerID uuid.UUID, ledger []*Transaction, key *LedgerKey) []LedgerViolation {
+	var violations []LedgerViolation
+	prevHash := GenesisHash
+	for i, txn := range ledger {
+		violate := func(problem string) {
+			violations = append(violations, LedgerViolation{
+				CustomerID:    customerID,
+				TransactionID: txn.ID,
+				LedgerSeq:     txn.LedgerSeq,
+				Problem:       problem,
+			})
+		}
+		if txn.LedgerSeq != int64(i+1) {
+			violate(fmt.Sprintf("expected ledger_seq %d, an entry was removed or inserted", i+1))
+		}
+		if txn.PrevHash != prevHash {
+			violate("prev_hash does not match the hash of the previous entry")
+		}
+		if !hmac.Equal([]byte(txn.ComputeHash(txn.PrevHash, key)), []byte(txn.EntryHash)) {
+			violate("entry_hash does not match the entry's contents")
+		}
+		prevHash = txn.EntryHash
+	}
+	return violations
+}
+
+// CheckHead reports whether the signed head of a customer's ledger is missing, forged or does not point
+// at the last of the entries
+func CheckHead(customerID uuid.UUID, ledger []*Transaction, head *LedgerHead, key *LedgerKey) []LedgerViolation {
+	violation := LedgerViolation{CustomerID: customerID}
+	var last *Transaction
+	if len(ledger) > 0 {
+		last = ledger[len(ledger)-1]
+		violation.TransactionID = last.ID
+		violation.LedgerSeq = last.LedgerSeq
+	}
+
+	switch {
+	case head == nil:
+		violation.Problem = "the ledger has no signed head"
+	case head.KeyID != key.ID:
+		violation.Problem = fmt.Sprintf("the head is signed with key %s, not the configured ledger key", head.KeyID)
+	case !head.Verify(key):
+		violation.Problem = "the head's signature does not match, the head was modified"
+	case last == nil || head.LedgerSeq != last.LedgerSeq || head.EntryHash != last.EntryHash:
+		violation.Problem = fmt.Sprintf("the head points at ledger_seq %d, not the last entry; entries were removed from the end", head.LedgerSeq)
+	default:
+		return nil
+	}
+	return []LedgerViolation{violation}
+}
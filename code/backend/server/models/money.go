@@ -0,0 +1,156 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in minor units (cents), so sums and comparisons are exact.
+//
+// Rounding rule: input with more than two decimal places is rounded to the nearest
+// cent, with halves rounded away from zero (1.005 -> 1.01, -1.005 -> -1.01).
+// JSON encodes Money as a string with exactly two decimals, e.g. "1234.50";
+// JSON input may be such a string or a plain number.
+type Money int64
+
+// moneyDecimals is the number of decimal places kept, matching the decimal(18,2) column
+const moneyDecimals = 2
+
+// MaxTransactionAmount is the largest amount a decimal(18,2) column can hold
+const MaxTransactionAmount Money = 9999999999999999_99
+
+// ParseMoney parses a decimal string such as "12", "-0.5" or "1234.567" into Money
+func ParseMoney(s string) (Money, error) {
+	original := s
+	s = strings.TrimSpace(s)
+	invalid := fmt.Errorf("invalid amount: %q", original)
+
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, invalid
+	}
+
+	var cents int64
+	digits := intPart + (fracPart + strings.Repeat("0", moneyDecimals))[:moneyDecimals]
+	for _, d := range digits {
+		digit := int64(d - '0')
+		if cents > (math.MaxInt64-digit)/10 {
+			return 0, fmt.Errorf("amount out of range: %q", original)
+		}
+		cents = cents*10 + digit
+	}
+	// Round half away from zero on the first dropped digit
+	if len(fracPart) > moneyDecimals && fracPart[moneyDecimals] >= '5' {
+		if cents == math.MaxInt64 {
+			return 0, fmt.Errorf("amount out of range: %q", original)
+		}
+		cents++
+	}
+
+	if negative {
+		cents = -cents
+	}
+	return Money(cents), nil
+}
+
+// isDigits reports whether s consists of ASCII digits only
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount with exactly two decimals
+func (m Money) String() string {
+	sign := ""
+	cents := uint64(m)
+	if m < 0 {
+		sign = "-"
+		cents = uint64(-m)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// MarshalJSON encodes the amount as a fixed-scale decimal string
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts a decimal string or a JSON number, parsed without going through float64
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	} else if strings.ContainsAny(text, "eE") {
+		return fmt.Errorf("invalid amount: exponent notation is not supported: %s", text)
+	}
+	parsed, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value stores the amount as a decimal string so the database keeps it exact
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads a DECIMAL column or aggregate
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		parsed, err := ParseMoney(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case int64:
+		if v > math.MaxInt64/100 || v < math.MinInt64/100 {
+			return fmt.Errorf("amount out of range: %d", v)
+		}
+		*m = Money(v * 100)
+		return nil
+	case float64:
+		parsed, err := ParseMoney(strconv.FormatFloat(v, 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into Money", src)
+}
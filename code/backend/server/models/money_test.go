@@ -0,0 +1,186 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{input: "0", want: 0},
+		{input: "12", want: 1200},
+		{input: "12.3", want: 1230},
+		{input: "12.34", want: 1234},
+		{input: "+12.34", want: 1234},
+		{input: " 12.34 ", want: 1234},
+		{input: ".5", want: 50},
+		{input: "7.", want: 700},
+		{input: "-0.5", want: -50},
+		{input: "-0", want: 0},
+
+		// Halves are rounded away from zero on the first dropped digit
+		{input: "1.005", want: 101},
+		{input: "1.004", want: 100},
+		{input: "1.0049999", want: 100},
+		{input: "1.995", want: 200},
+		{input: "-1.005", want: -101},
+		{input: "-1.004", want: -100},
+		{input: "0.005", want: 1},
+		{input: "-0.005", want: -1},
+		{input: "0.00499", want: 0},
+		{input: "9.999", want: 1000},
+
+		// Many digits and the bounds of int64 cents
+		{input: "0.12345678901234567890", want: 12},
+		{input: "000000000000000000000000001.50", want: 150},
+		{input: "9999999999999999.99", want: MaxTransactionAmount},
+		{input: "92233720368547758.07", want: math.MaxInt64},
+		{input: "-92233720368547758.07", want: -math.MaxInt64},
+		{input: "92233720368547758.064", want: math.MaxInt64 - 1},
+		{input: "92233720368547758.075", wantErr: true},
+		{input: "92233720368547758.08", wantErr: true},
+		{input: "92233720368547759", wantErr: true},
+		{input: "100000000000000000000", wantErr: true},
+
+		{input: "", wantErr: true},
+		{input: "-", wantErr: true},
+		{input: ".", wantErr: true},
+		{input: "abc", wantErr: true},
+		{input: "1.2.3", wantErr: true},
+		{input: "1,000", wantErr: true},
+		{input: "--1", wantErr: true},
+		{input: "1e3", wantErr: true},
+		{input: "0x10", wantErr: true},
+		{input: "1 000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMoney(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseMoney(%q) = %d, want an error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q) returned error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMoney(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{0, "0.00"},
+		{1, "0.01"},
+		{-1, "-0.01"},
+		{50, "0.50"},
+		{-50, "-0.50"},
+		{123450, "1234.50"},
+		{-123456, "-1234.56"},
+		{MaxTransactionAmount, "9999999999999999.99"},
+		{math.MaxInt64, "92233720368547758.07"},
+		{math.MinInt64, "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(tt.money), got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	for _, money := range []Money{0, 1, -1, 99, 100, -12345, 123456789, MaxTransactionAmount, -MaxTransactionAmount, math.MaxInt64} {
+		data, err := json.Marshal(money)
+		if err != nil {
+			t.Fatalf("marshalling %d: %v", int64(money), err)
+		}
+		if want := `"` + money.String() + `"`; string(data) != want {
+			t.Errorf("json.Marshal(%d) = %s, want %s", int64(money), data, want)
+		}
+		var decoded Money
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("unmarshalling %s: %v", data, err)
+		}
+		if decoded != money {
+			t.Errorf("round trip of %d gave %d", int64(money), int64(decoded))
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{input: `"12.34"`, want: 1234},
+		{input: `12.34`, want: 1234},
+		{input: `-0.015`, want: -2},
+		{input: `0.1`, want: 10},
+		{input: `1234567890123456.78`, want: 1234567890123456_78}, // loses precision as a float64
+		{input: `null`, want: 0},
+		{input: `1e2`, wantErr: true},
+		{input: `"abc"`, wantErr: true},
+		{input: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got Money
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("unmarshalling %s gave %d, want an error", tt.input, int64(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unmarshalling %s: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("unmarshalling %s gave %d, want %d", tt.input, int64(got), int64(tt.want))
+			}
+		})
+	}
+}
+
+// TestMoneySumOfGeneratedAmounts sums many amounts formatted the way the generator sends them and
+// expects the exact total of their cents
+func TestMoneySumOfGeneratedAmounts(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var sum Money
+	var wantCents int64
+	for i := 0; i < 100000; i++ {
+		cents := rng.Int63n(100000000) + 1
+		text := fmt.Sprintf("%d.%02d", cents/100, cents%100)
+		amount, err := ParseMoney(text)
+		if err != nil {
+			t.Fatalf("ParseMoney(%q) returned error: %v", text, err)
+		}
+		var decoded Money
+		if err := json.Unmarshal([]byte(`"`+text+`"`), &decoded); err != nil || decoded != amount {
+			t.Fatalf("JSON %q decoded to %d (%v), want %d", text, int64(decoded), err, int64(amount))
+		}
+		sum += amount
+		wantCents += cents
+	}
+	if sum != Money(wantCents) {
+		t.Fatalf("sum = %s, want %s", sum, Money(wantCents))
+	}
+	if sum.String() != fmt.Sprintf("%d.%02d", wantCents/100, wantCents%100) {
+		t.Errorf("sum formats as %s", sum)
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Operator is a staff account with the admin or operator role
+type Operator struct {
+	ID         uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	Name       string     `gorm:"type:varchar(255);not null" json:"name"`
+	Email      string     `gorm:"type:varchar(255);unique;not null" json:"email"`
+	Password   string     `gorm:"type:varchar(255);not null" json:"-"`
+	Role       Role       `gorm:"type:enum('admin','operator');not null" json:"role"`
+	DisabledAt *time.Time `gorm:"type:timestamp NULL" json:"disabled_at"`
+	CreatedAt  time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
+
+// CreateOperatorRequest is the body of a request creating an operator account
+type CreateOperatorRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     Role   `json:"role"`
+}
+
+// UpdateOperatorRequest is the body of a request updating an operator account; omitted fields are kept
+type UpdateOperatorRequest struct {
+	Name     *string `json:"name"`
+	Role     *Role   `json:"role"`
+	Disabled *bool   `json:"disabled"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordResetToken lets a customer who forgot their password set a new one. Only the SHA-256 of the
+// token is stored; the token is sent to the customer and can be used once before ExpiresAt.
+type PasswordResetToken struct {
+	ID         uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	CustomerID uuid.UUID  `gorm:"type:char(36);index;not null" json:"customer_id"`
+	TokenHash  string     `gorm:"type:char(64);uniqueIndex:uni_password_reset_tokens_token_hash;not null" json:"-"`
+	ExpiresAt  time.Time  `gorm:"type:timestamp" json:"expires_at"`
+	UsedAt     *time.Time `gorm:"type:timestamp NULL" json:"used_at"`
+	CreatedAt  time.Time  `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
+
+// TableName overrides the table name used by PasswordResetToken
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
+
+// ChangePasswordRequest is the body of a password change, which requires the current password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ForgotPasswordRequest is the body of a request for a password reset token
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest is the body of a password reset with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// Notification is a message to a customer, such as a password reset link
+type Notification struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+type Role string
+
+const (
+	RoleAdmin            Role = "admin"
+	RoleOperator         Role = "operator"
+	RoleCustomer         Role = "customer"
+	RoleGeneratorService Role = "generator-service"
+)
+
+// IsOperatorRole reports whether the role is one an operator account may have
+func (r Role) IsOperatorRole() bool {
+	return r == RoleAdmin || r == RoleOperator
+}
+
+type Permission string
+
+const (
+	// PermissionPublic marks routes callable without credentials
+	PermissionPublic Permission = "public"
+	// PermissionAuthenticated marks routes callable by any authenticated principal
+	PermissionAuthenticated Permission = "authenticated"
+
+	PermCustomersRead       Permission = "customers:read"
+	PermCustomersWrite      Permission = "customers:write"
+	PermCustomersReset      Permission = "customers:reset"
+	PermCustomersSample     Permission = "customers:sample"
+	PermCustomersGenerate   Permission = "customers:generate"
+	PermTransactionsRead    Permission = "transactions:read"
+	PermTransactionsWrite   Permission = "transactions:write"
+	PermTransactionsCorrect Permission = "transactions:correct"
+	PermImportsManage       Permission = "imports:manage"
+	PermFXRatesRead         Permission = "fx_rates:read"
+	PermFXRatesWrite        Permission = "fx_rates:write"
+	PermReportsRead         Permission = "reports:read"
+	PermSegmentsRecompute   Permission = "segments:recompute"
+	PermOperatorsManage     Permission = "operators:manage"
+)
+
+// rolePermissions lists the permissions of every role but admin, which has them all. Customers have
+// none; they only reach their own resources through the owner rules of the route policy.
+var rolePermissions = map[Role][]Permission{
+	RoleOperator: {
+		PermCustomersRead, PermCustomersWrite, PermCustomersSample, PermCustomersGenerate,
+		PermTransactionsRead, PermTransactionsWrite, PermTransactionsCorrect,
+		PermImportsManage, PermFXRatesRead, PermReportsRead,
+	},
+	// The generator only samples customers to attach transactions to and inserts generated data; it cannot
+	// list, read or update customer records
+	RoleGeneratorService: {PermCustomersSample, PermCustomersGenerate, PermTransactionsWrite},
+}
+
+// HasPermission reports whether the role grants the permission
+func (r Role) HasPermission(permission Permission) bool {
+	if r == RoleAdmin {
+		return true
+	}
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal is the authenticated caller of a request. ID is the customer or operator ID and
+// SessionID their login session; both are nil for service principals, which have the KeyID of the
+// service key that signed the request instead.
+type Principal struct {
+	ID        uuid.UUID
+	Role      Role
+	SessionID uuid.UUID
+	KeyID     string
+}
+
+// Actor identifies the principal in audit trails
+func (p *Principal) Actor() string {
+	if p.KeyID != "" {
+		return string(p.Role) + ":" + p.KeyID
+	}
+	if p.ID == uuid.Nil {
+		return string(p.Role)
+	}
+	return string(p.Role) + ":" + p.ID.String()
+}
@@ -0,0 +1,34 @@
+package models
+
+import "testing"
+
+func TestRoleHasPermission(t *testing.T) {
+	tests := []struct {
+		role       Role
+		permission Permission
+		want       bool
+	}{
+		{RoleAdmin, PermOperatorsManage, true},
+		{RoleAdmin, PermCustomersSample, true},
+		{RoleOperator, PermCustomersRead, true},
+		{RoleOperator, PermCustomersSample, true},
+		{RoleOperator, PermOperatorsManage, false},
+		{RoleCustomer, PermCustomersRead, false},
+
+		// The generator may only sample customers and insert generated customers and transactions
+		{RoleGeneratorService, PermCustomersSample, true},
+		{RoleGeneratorService, PermCustomersGenerate, true},
+		{RoleGeneratorService, PermTransactionsWrite, true},
+		{RoleGeneratorService, PermCustomersRead, false},
+		{RoleGeneratorService, PermCustomersWrite, false},
+		{RoleGeneratorService, PermTransactionsRead, false},
+		{RoleGeneratorService, PermReportsRead, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.role)+" "+string(tt.permission), func(t *testing.T) {
+			if got := tt.role.HasPermission(tt.permission); got != tt.want {
+				t.Errorf("%s.HasPermission(%s) = %v, want %v", tt.role, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
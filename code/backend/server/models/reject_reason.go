@@ -0,0 +1,26 @@
+package models
+
+// RejectReason is a machine-readable code explaining why an item of a bulk request was not stored
+type RejectReason string
+
+const (
+	ReasonUnknownCustomer    RejectReason = "unknown_customer"
+	ReasonNonPositiveAmount  RejectReason = "non_positive_amount"
+	ReasonAmountOutOfRange   RejectReason = "amount_out_of_range"
+	ReasonInvalidCurrency    RejectReason = "invalid_currency"
+	ReasonInvalidType        RejectReason = "invalid_type"
+	ReasonZeroAmount         RejectReason = "zero_amount"
+	ReasonUnknownOriginal    RejectReason = "unknown_original"
+	ReasonInvalidOriginal    RejectReason = "invalid_original"
+	ReasonExceedsOriginal    RejectReason = "exceeds_original"
+	ReasonZeroTime           RejectReason = "zero_time"
+	ReasonBeforeRegistration RejectReason = "before_registration"
+	ReasonDuplicate          RejectReason = "duplicate"
+	ReasonInsertFailed       RejectReason = "insert_failed"
+	ReasonPasswordTooShort   RejectReason = "password_too_short"
+	ReasonWeakPassword       RejectReason = "weak_password"
+	ReasonInvalidGender      RejectReason = "invalid_gender"
+	ReasonHashFailed         RejectReason = "hash_failed"
+	ReasonDuplicateEmail     RejectReason = "duplicate_email"
+	ReasonInvalidRow         RejectReason = "invalid_row"
+)
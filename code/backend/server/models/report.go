@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultTopCustomers = 10
+	MaxTopCustomers     = 100
+)
+
+// RevenuePeriod is the net revenue of all customers in one bucket
+type RevenuePeriod struct {
+	Start            time.Time `json:"start"`
+	End              time.Time `json:"end"`
+	Revenue          Money     `json:"revenue"`
+	TransactionCount int64     `json:"transaction_count"`
+}
+
+// RevenueReport is the response of the revenue report; Total covers the whole range
+type RevenueReport struct {
+	From     time.Time        `json:"from"`
+	To       time.Time        `json:"to"`
+	TimeZone string           `json:"tz"`
+	Bucket   BucketSize       `json:"bucket"`
+	Currency Currency         `json:"currency"`
+	Total    Money            `json:"total"`
+	Periods  []*RevenuePeriod `json:"periods"`
+}
+
+// CustomerCountPeriod is a number of customers in one bucket
+type CustomerCountPeriod struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Customers int64     `json:"customers"`
+}
+
+// CustomerCountReport is the response of the active and new customer reports
+type CustomerCountReport struct {
+	From     time.Time              `json:"from"`
+	To       time.Time              `json:"to"`
+	TimeZone string                 `json:"tz"`
+	Bucket   BucketSize             `json:"bucket"`
+	Periods  []*CustomerCountPeriod `json:"periods"`
+}
+
+// TopCustomer is a customer ranked by net spend in a window
+type TopCustomer struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	TotalAmount Money     `json:"total_amount"`
+}
+
+// TopCustomersReport is the response of the top customers report
+type TopCustomersReport struct {
+	Window    *AggregationWindow `json:"window"`
+	Currency  Currency           `json:"currency"`
+	Customers []*TopCustomer     `json:"customers"`
+}
+
+// GenderRevenue is the net revenue of the customers of one gender
+type GenderRevenue struct {
+	Gender    Gender `json:"gender"`
+	Customers int64  `json:"customers"` // customers with transactions in the window
+	Revenue   Money  `json:"revenue"`
+}
+
+// GenderRevenueReport is the response of the revenue by gender report
+type GenderRevenueReport struct {
+	Window   *AggregationWindow `json:"window"`
+	Currency Currency           `json:"currency"`
+	Genders  []*GenderRevenue   `json:"genders"`
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RFMScoreLevels is the number of quantiles each of the R, F and M scores is divided into
+const RFMScoreLevels = 5
+
+type RFMSegment string
+
+const (
+	SegmentChampions          RFMSegment = "champions"
+	SegmentLoyalCustomers     RFMSegment = "loyal_customers"
+	SegmentPotentialLoyalists RFMSegment = "potential_loyalists"
+	SegmentNewCustomers       RFMSegment = "new_customers"
+	SegmentPromising          RFMSegment = "promising"
+	SegmentNeedAttention      RFMSegment = "need_attention"
+	SegmentAboutToSleep       RFMSegment = "about_to_sleep"
+	SegmentAtRisk             RFMSegment = "at_risk"
+	SegmentCantLoseThem       RFMSegment = "cant_lose_them"
+	SegmentHibernating        RFMSegment = "hibernating"
+)
+
+// RFMSegmentRule assigns Segment to the customers whose R and F scores fall within the inclusive ranges
+type RFMSegmentRule struct {
+	Segment    RFMSegment
+	MinR, MaxR int
+	MinF, MaxF int
+}
+
+// RFMSegmentRules maps every combination of R and F scores to exactly one segment
+var RFMSegmentRules = []RFMSegmentRule{
+	{SegmentChampions, 5, 5, 4, 5},
+	{SegmentLoyalCustomers, 3, 4, 4, 5},
+	{SegmentPotentialLoyalists, 4, 5, 2, 3},
+	{SegmentNewCustomers, 5, 5, 1, 1},
+	{SegmentPromising, 4, 4, 1, 1},
+	{SegmentNeedAttention, 3, 3, 3, 3},
+	{SegmentAboutToSleep, 3, 3, 1, 2},
+	{SegmentCantLoseThem, 1, 2, 5, 5},
+	{SegmentAtRisk, 1, 2, 3, 4},
+	{SegmentHibernating, 1, 2, 1, 2},
+}
+
+// IsValid reports whether the segment is one of the segments of RFMSegmentRules
+func (s RFMSegment) IsValid() bool {
+	for _, rule := range RFMSegmentRules {
+		if rule.Segment == s {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	RFMTriggerManual   = "manual"
+	RFMTriggerSchedule = "schedule"
+)
+
+// RFMRun records one computation of the RFM scores
+type RFMRun struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Trigger    string    `gorm:"column:triggered_by;type:varchar(16);not null" json:"trigger"`
+	WindowName string    `gorm:"type:varchar(32);not null" json:"window"`
+	WindowFrom time.Time `gorm:"type:timestamp" json:"from"`
+	WindowTo   time.Time `gorm:"type:timestamp" json:"to"`
+	Currency   Currency  `gorm:"type:char(3);not null" json:"currency"`
+	Customers  int64     `gorm:"not null;default:0" json:"customers"`
+	CreatedAt  time.Time `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
+
+// TableName overrides the table name used by RFMRun
+func (RFMRun) TableName() string {
+	return "rfm_runs"
+}
+
+// CustomerRFMScore is a customer's result of the latest RFM run. Only customers with purchases in
+// the run's window are scored; higher scores are better.
+type CustomerRFMScore struct {
+	CustomerID  uuid.UUID  `gorm:"type:char(36);primaryKey" json:"customer_id"`
+	RunID       uint64     `gorm:"not null" json:"run_id"`
+	RecencyDays int        `gorm:"not null" json:"recency_days"` // days from the last purchase to the end of the window
+	Frequency   int64      `gorm:"not null" json:"frequency"`    // purchases in the window
+	Monetary    Money      `gorm:"type:decimal(18,2);not null;index:idx_customer_rfm_scores_segment_monetary,priority:2" json:"monetary"`
+	RScore      int        `gorm:"column:r_score;not null" json:"r_score"`
+	FScore      int        `gorm:"column:f_score;not null" json:"f_score"`
+	MScore      int        `gorm:"column:m_score;not null" json:"m_score"`
+	Segment     RFMSegment `gorm:"type:varchar(32);not null;index:idx_customer_rfm_scores_segment_monetary,priority:1" json:"segment"`
+}
+
+// TableName overrides the table name used by CustomerRFMScore
+func (CustomerRFMScore) TableName() string {
+	return "customer_rfm_scores"
+}
+
+// RFMSegmentCount is the number of customers in a segment
+type RFMSegmentCount struct {
+	Segment   RFMSegment `json:"segment"`
+	Customers int64      `json:"customers"`
+}
+
+// RFMSummary is the response of the segment overview: the latest run and the size of every segment
+type RFMSummary struct {
+	Run      *RFMRun            `json:"run"`
+	Segments []*RFMSegmentCount `json:"segments"`
+}
+
+// RFMMember is a scored customer listed as a member of a segment
+type RFMMember struct {
+	CustomerRFMScore
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// RFMMemberCursor marks the last member of a page; members are ordered by monetary value, highest first
+type RFMMemberCursor struct {
+	Monetary string    `json:"v"`
+	ID       uuid.UUID `json:"id"`
+}
+
+// Encode serializes the cursor into an opaque URL-safe token
+func (c *RFMMemberCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeRFMMemberCursor parses a token produced by RFMMemberCursor.Encode
+func DecodeRFMMemberCursor(token string) (*RFMMemberCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cursor RFMMemberCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if _, err := ParseMoney(cursor.Monetary); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &cursor, nil
+}
+
+// RFMMemberPage is the response envelope for a paginated segment member list
+type RFMMemberPage struct {
+	Segment    RFMSegment   `json:"segment"`
+	Items      []*RFMMember `json:"items"`
+	NextCursor string       `json:"next_cursor"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// ServiceAuthScheme is the Authorization scheme of requests signed with a service key. The credentials
+// are "KeyId=<key ID>, Timestamp=<unix seconds>, Signature=<hex HMAC-SHA256>".
+const ServiceAuthScheme = "HMAC-SHA256"
+
+// SignedRequest is a request presenting service key credentials, with the parts its signature covers
+type SignedRequest struct {
+	Credentials    string // Authorization header value after the scheme
+	Method         string
+	URI            string // path and query as sent by the client
+	IdempotencyKey string
+	Body           []byte
+}
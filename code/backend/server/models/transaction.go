@@ -1,16 +1,86 @@
 package models
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// GenesisHash is the previous hash of the first entry in a customer's ledger
+var GenesisHash = strings.Repeat("0", 64)
+
+// LedgerKey is the server-held secret the ledger hashes are keyed with, so that rows edited in the
+// database cannot be given valid hashes without it
+type LedgerKey struct {
+	// ID identifies the key in ledger heads without revealing it
+	ID     string
+	secret []byte
+}
+
+// NewLedgerKey creates a LedgerKey from its secret
+func NewLedgerKey(secret string) *LedgerKey {
+	sum := sha256.Sum256([]byte("ledger-key:" + secret))
+	return &LedgerKey{ID: base64.RawStdEncoding.EncodeToString(sum[:6]), secret: []byte(secret)}
+}
+
+// Sum returns the hex HMAC-SHA256 of the fields joined with "|". Without a key it returns their plain
+// SHA-256, the hash of the ledgers chained before hashes were keyed.
+func (k *LedgerKey) Sum(fields ...string) string {
+	data := []byte(strings.Join(fields, "|"))
+	if k == nil {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Transaction is a purchase, or a refund, reversal or adjustment of a customer's spending.
+// Amounts of refunds and reversals are positive and count against the total; adjustments are signed.
+// Rows are append-only: each customer's transactions form a ledger ordered by LedgerSeq, where every
+// entry's hash covers the previous entry's hash so that edits made directly in the database show up.
 type Transaction struct {
-	ID         uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
-	CustomerID uuid.UUID `gorm:"type:char(36);not null;index" json:"customer_id"`
-	Customer   Customer  `gorm:"foreignKey:CustomerID;references:ID;constraint:OnDelete:CASCADE"`
-	Amount     float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
-	Time       time.Time `gorm:"type:timestamp;default:current_timestamp" json:"time"`
-	CreatedAt  time.Time `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+	ID                    uuid.UUID       `gorm:"type:char(36);primaryKey" json:"id"`
+	CustomerID            uuid.UUID       `gorm:"type:char(36);not null;index:idx_transactions_customer_time,priority:1" json:"customer_id"`
+	Customer              Customer        `gorm:"foreignKey:CustomerID;references:ID;constraint:OnDelete:CASCADE"`
+	Type                  TransactionType `gorm:"type:varchar(16);not null;default:'purchase'" json:"type"`
+	OriginalTransactionID *uuid.UUID      `gorm:"type:char(36);index" json:"original_transaction_id,omitempty"` // set for refunds and reversals
+	Amount                Money           `gorm:"type:decimal(18,2);not null" json:"amount"`
+	Currency              Currency        `gorm:"type:char(3);not null;default:'TWD'" json:"currency"`
+	LedgerSeq             int64           `gorm:"not null" json:"ledger_seq"`
+	PrevHash              string          `gorm:"type:char(64);not null" json:"-"`
+	EntryHash             string          `gorm:"type:char(64);not null" json:"-"`
+	Time                  time.Time       `gorm:"type:timestamp;default:current_timestamp;index:idx_transactions_customer_time,priority:2" json:"time"`
+	CreatedAt             time.Time       `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+	// ConvertedAmount is only populated by queries that convert into a reporting currency
+	ConvertedAmount *Money `gorm:"->" json:"-"`
+	// Sequence is only populated by queries that number the customer's purchases
+	Sequence int `gorm:"->" json:"-"`
+}
+
+// ComputeHash hashes the entry's immutable fields together with the previous entry's hash under key.
+// The time is hashed in whole seconds, the precision of the time column.
+func (t *Transaction) ComputeHash(prevHash string, key *LedgerKey) string {
+	originalID := ""
+	if t.OriginalTransactionID != nil {
+		originalID = t.OriginalTransactionID.String()
+	}
+	return key.Sum(
+		prevHash,
+		t.ID.String(),
+		t.CustomerID.String(),
+		strconv.FormatInt(t.LedgerSeq, 10),
+		string(t.Type),
+		originalID,
+		t.Amount.String(),
+		string(t.Currency),
+		strconv.FormatInt(t.Time.Unix(), 10),
+	)
 }
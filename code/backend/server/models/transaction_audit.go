@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditCorrect AuditAction = "correct"
+)
+
+// TransactionSnapshot is the state of a transaction recorded in an audit entry
+type TransactionSnapshot struct {
+	ID                    uuid.UUID       `json:"id"`
+	CustomerID            uuid.UUID       `json:"customer_id"`
+	Type                  TransactionType `json:"type"`
+	OriginalTransactionID *uuid.UUID      `json:"original_transaction_id,omitempty"`
+	Amount                Money           `json:"amount"`
+	Currency              Currency        `json:"currency"`
+	Time                  time.Time       `json:"time"`
+	LedgerSeq             int64           `json:"ledger_seq"`
+}
+
+// Snapshot captures the fields of the transaction that an audit entry records
+func (t *Transaction) Snapshot() *TransactionSnapshot {
+	return &TransactionSnapshot{
+		ID:                    t.ID,
+		CustomerID:            t.CustomerID,
+		Type:                  t.Type,
+		OriginalTransactionID: t.OriginalTransactionID,
+		Amount:                t.Amount,
+		Currency:              t.Currency,
+		Time:                  t.Time,
+		LedgerSeq:             t.LedgerSeq,
+	}
+}
+
+// TransactionAudit records who changed the ledger, when, why, and the values before and after
+type TransactionAudit struct {
+	ID            uint64               `gorm:"primaryKey;autoIncrement" json:"id"`
+	TransactionID uuid.UUID            `gorm:"type:char(36);not null;index" json:"transaction_id"`
+	Action        AuditAction          `gorm:"type:varchar(16);not null" json:"action"`
+	Actor         string               `gorm:"type:varchar(255);not null" json:"actor"`
+	Reason        string               `gorm:"type:text" json:"reason,omitempty"`
+	Before        *TransactionSnapshot `gorm:"type:text;serializer:json" json:"before"`
+	After         *TransactionSnapshot `gorm:"type:text;serializer:json" json:"after"`
+	CreatedAt     time.Time            `gorm:"type:timestamp;default:current_timestamp" json:"created_at"`
+}
+
+// TransactionCorrection replaces a purchase's values. Fields left empty keep their current value.
+type TransactionCorrection struct {
+	Amount   *Money     `json:"amount"`
+	Currency Currency   `json:"currency"`
+	Time     *time.Time `json:"time"`
+	Reason   string     `json:"reason"`
+}
+
+// CorrectionResult lists the entries appended to the ledger by a correction
+type CorrectionResult struct {
+	Reversal    *TransactionDTO `json:"reversal"`
+	Replacement *TransactionDTO `json:"replacement"`
+}
+
+// LedgerViolation describes an entry whose position or hash does not match the recomputed chain
+type LedgerViolation struct {
+	CustomerID    uuid.UUID `json:"customer_id"`
+	TransactionID uuid.UUID `json:"transaction_id"`
+	LedgerSeq     int64     `json:"ledger_seq"`
+	Problem       string    `json:"problem"`
+}
+
+// LedgerReport is the outcome of verifying the hash chains of customer ledgers
+type LedgerReport struct {
+	CustomersChecked int               `json:"customers_checked"`
+	EntriesChecked   int               `json:"entries_checked"`
+	Violations       []LedgerViolation `json:"violations"`
+}
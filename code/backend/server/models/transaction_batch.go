@@ -0,0 +1,28 @@
+package models
+
+type BatchMode string
+
+const (
+	AllOrNothing BatchMode = "all_or_nothing"
+	BestEffort   BatchMode = "best_effort"
+)
+
+// IsValid reports whether the batch mode is one of the supported modes
+func (m BatchMode) IsValid() bool {
+	return m == AllOrNothing || m == BestEffort
+}
+
+// TransactionRejection describes why the item at Index of a batch was not stored
+type TransactionRejection struct {
+	Index   int          `json:"index"`
+	Reason  RejectReason `json:"reason"`
+	Message string       `json:"message"`
+}
+
+// TransactionBatchResult reports the outcome of a bulk transaction insert
+type TransactionBatchResult struct {
+	Mode       BatchMode              `json:"mode"`
+	Accepted   int                    `json:"accepted"`
+	Rejected   int                    `json:"rejected"`
+	Rejections []TransactionRejection `json:"rejections"`
+}
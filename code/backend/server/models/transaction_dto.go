@@ -7,9 +7,15 @@ import (
 )
 
 type TransactionDTO struct {
-	ID         uuid.UUID `json:"id"`
-	CustomerID uuid.UUID `json:"customer_id"`
-	Amount     float64   `json:"amount"`
-	Sequence   int       `json:"sequence"`
-	Time       time.Time `json:"time"`
+	ID                    uuid.UUID       `json:"id"`
+	CustomerID            uuid.UUID       `json:"customer_id"`
+	Type                  TransactionType `json:"type"`                              // defaults to purchase
+	OriginalTransactionID *uuid.UUID      `json:"original_transaction_id,omitempty"` // required for refunds and reversals
+	Amount                Money           `json:"amount"`
+	Currency              Currency        `json:"currency"`
+	Sequence              int             `json:"sequence,omitempty"` // counts purchases only
+	Time                  time.Time       `json:"time"`
+	// ConvertedAmount is Amount in ReportingCurrency, set when a reporting currency was requested
+	ConvertedAmount   *Money   `json:"converted_amount,omitempty"`
+	ReportingCurrency Currency `json:"reporting_currency,omitempty"`
 }
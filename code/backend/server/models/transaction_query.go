@@ -0,0 +1,129 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransactionQuery describes a page request against a customer's transactions, ordered by time
+type TransactionQuery struct {
+	CustomerID uuid.UUID
+	PageSize   int
+	Cursor     *TransactionCursor
+	Order      SortOrder
+	From       time.Time // optional half-open time range [From, To); zero when unbounded
+	To         time.Time
+	MinAmount  *Money
+	MaxAmount  *Money
+	Currency   Currency // reporting currency of converted amounts and of the amount filters; empty for none
+}
+
+// maxTransactionRange bounds date-range listings, like custom aggregation windows
+const maxTransactionRange = maxWindowLength
+
+// ParseTimeZone loads an IANA time zone such as Asia/Taipei; an empty name selects UTC
+func ParseTimeZone(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tz: %s", tz)
+	}
+	return loc, nil
+}
+
+// ParseTransactionRange parses the bounds of a date-range listing into the half-open interval
+// [from, to). Bounds are RFC3339 timestamps or YYYY-MM-DD dates at midnight in loc; a date given
+// as to includes that whole day.
+func ParseTransactionRange(from, to string, loc *time.Location) (time.Time, time.Time, error) {
+	if from == "" || to == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("from and to are required")
+	}
+	fromTime, _, err := parseRangeBound(from, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %s, expected RFC3339 or YYYY-MM-DD", from)
+	}
+	toTime, isDate, err := parseRangeBound(to, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %s, expected RFC3339 or YYYY-MM-DD", to)
+	}
+	if toTime.Before(fromTime) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must not be after to")
+	}
+	if isDate {
+		toTime = toTime.AddDate(0, 0, 1)
+	}
+	if toTime.Sub(fromTime) > maxTransactionRange {
+		return time.Time{}, time.Time{}, fmt.Errorf("range must not exceed 10 years")
+	}
+	return fromTime, toTime, nil
+}
+
+// parseRangeBound accepts an RFC3339 timestamp or a YYYY-MM-DD date at midnight in loc,
+// and reports which of the two it was
+func parseRangeBound(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, loc)
+	return t, true, err
+}
+
+type CursorDirection string
+
+const (
+	CursorNext CursorDirection = "next"
+	CursorPrev CursorDirection = "prev"
+)
+
+// TransactionCursor marks a transaction on the edge of a page. A next cursor continues right
+// after it in the requested order, a prev cursor returns the page right before it.
+type TransactionCursor struct {
+	Order     SortOrder       `json:"o"`
+	Direction CursorDirection `json:"d"`
+	Time      time.Time       `json:"t"`
+	ID        uuid.UUID       `json:"id"`
+	Currency  Currency        `json:"c,omitempty"`
+}
+
+// Encode serializes the cursor into an opaque URL-safe token
+func (c *TransactionCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeTransactionCursor parses a token produced by TransactionCursor.Encode
+func DecodeTransactionCursor(token string) (*TransactionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cursor TransactionCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if !cursor.Order.IsValid() || (cursor.Direction != CursorNext && cursor.Direction != CursorPrev) {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if cursor.Currency != "" && !cursor.Currency.IsValid() {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &cursor, nil
+}
+
+// TransactionPage is the response envelope for a paginated transaction list.
+// Subtotal nets out refunds and reversals on the page; it is in the reporting currency, or in the
+// transactions' own currency when none is requested, and null if that currency is not unique.
+type TransactionPage struct {
+	Items            []*TransactionDTO `json:"items"`
+	NextCursor       string            `json:"next_cursor"`
+	PrevCursor       string            `json:"prev_cursor"`
+	Subtotal         *Money            `json:"subtotal"`
+	SubtotalCurrency Currency          `json:"subtotal_currency,omitempty"`
+	Currency         Currency          `json:"currency,omitempty"`
+}
@@ -0,0 +1,32 @@
+package models
+
+type TransactionType string
+
+const (
+	TypePurchase   TransactionType = "purchase"
+	TypeRefund     TransactionType = "refund"
+	TypeReversal   TransactionType = "reversal"
+	TypeAdjustment TransactionType = "adjustment"
+)
+
+// IsValid reports whether the transaction type is one of the supported types
+func (t TransactionType) IsValid() bool {
+	switch t {
+	case TypePurchase, TypeRefund, TypeReversal, TypeAdjustment:
+		return true
+	}
+	return false
+}
+
+// LinksOriginal reports whether the type must reference the purchase it takes money back from
+func (t TransactionType) LinksOriginal() bool {
+	return t == TypeRefund || t == TypeReversal
+}
+
+// Net returns amount as it counts towards the customer's total: negated for refunds and reversals
+func (t TransactionType) Net(amount Money) Money {
+	if t == TypeRefund || t == TypeReversal {
+		return -amount
+	}
+	return amount
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/services"
+)
+
+const operatorsUsage = "usage: server operators create-admin <email> <name> (password on stdin)"
+
+// runOperatorsCommand executes the operators subcommand with the arguments following "operators".
+// It bootstraps the first admin, who can then manage the other operators through the API.
+func runOperatorsCommand(operatorService services.OperatorService, args []string) error {
+	if len(args) != 3 || args[0] != "create-admin" {
+		return fmt.Errorf(operatorsUsage)
+	}
+
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("reading the password from stdin: %w", err)
+	}
+	operator, err := operatorService.CreateOperator(&models.CreateOperatorRequest{
+		Email:    args[1],
+		Name:     args[2],
+		Password: strings.TrimRight(password, "\r\n"),
+		Role:     models.RoleAdmin,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created admin %s (%s)\n", operator.Email, operator.ID)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/middlewares"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+// routePolicy maps every route to the permission required to call it. The server refuses to start
+// if a route is missing, so that new routes are never left open by accident.
+var routePolicy = middlewares.RoutePolicy{
+	// Authentication
+	"POST /auth/login":           {Permission: models.PermissionPublic},
+	"POST /auth/operators/login": {Permission: models.PermissionPublic},
+	"POST /auth/refresh":         {Permission: models.PermissionPublic},
+	"POST /auth/logout":          {Permission: models.PermissionAuthenticated},
+	"POST /auth/password/forgot": {Permission: models.PermissionPublic},
+	"POST /auth/password/reset":  {Permission: models.PermissionPublic},
+
+	// Customers; a customer may read and update their own record and read their own transactions
+	"GET /customers":                       {Permission: models.PermCustomersRead},
+	"GET /customers/:id":                   {Permission: models.PermCustomersRead, OwnerParam: "id"},
+	"POST /customers":                      {Permission: models.PermCustomersWrite},
+	"PUT /customers/:id":                   {Permission: models.PermCustomersWrite, OwnerParam: "id"},
+	"PUT /customers/password/:id":          {Permission: models.PermCustomersWrite, OwnerParam: "id"},
+	"GET /customers/:id/transactions":      {Permission: models.PermTransactionsRead, OwnerParam: "id"},
+	"GET /customers/:id/transactions/date": {Permission: models.PermTransactionsRead, OwnerParam: "id"},
+	"GET /customers/:id/analytics":         {Permission: models.PermReportsRead, OwnerParam: "id"},
+	"DELETE /customers/reset":              {Permission: models.PermCustomersReset},
+
+	// Generator
+	"GET /customers/limit/:num": {Permission: models.PermCustomersSample},
+	"POST /customers/multi":     {Permission: models.PermCustomersGenerate},
+	"POST /transactions/multi":  {Permission: models.PermTransactionsWrite},
+
+	// Transactions
+	"POST /transactions/:id/corrections": {Permission: models.PermTransactionsCorrect},
+	"GET /transactions/:id/history":      {Permission: models.PermTransactionsRead},
+
+	// Bulk imports
+	"POST /jobs/imports":    {Permission: models.PermImportsManage},
+	"GET /jobs/:id":         {Permission: models.PermImportsManage},
+	"POST /jobs/:id/cancel": {Permission: models.PermImportsManage},
+
+	// FX rates
+	"GET /fx-rates":               {Permission: models.PermFXRatesRead},
+	"PUT /admin/fx-rates":         {Permission: models.PermFXRatesWrite},
+	"POST /admin/fx-rates/import": {Permission: models.PermFXRatesWrite},
+
+	// Reports and segments
+	"GET /reports/revenue":               {Permission: models.PermReportsRead},
+	"GET /reports/active-customers":      {Permission: models.PermReportsRead},
+	"GET /reports/new-customers":         {Permission: models.PermReportsRead},
+	"GET /reports/top-customers":         {Permission: models.PermReportsRead},
+	"GET /reports/revenue-by-gender":     {Permission: models.PermReportsRead},
+	"GET /reports/cohort-retention":      {Permission: models.PermReportsRead},
+	"GET /segments/rfm":                  {Permission: models.PermReportsRead},
+	"GET /segments/rfm/:segment":         {Permission: models.PermReportsRead},
+	"POST /admin/segments/rfm/recompute": {Permission: models.PermSegmentsRecompute},
+
+	// Operator accounts
+	"GET /admin/operators":        {Permission: models.PermOperatorsManage},
+	"POST /admin/operators":       {Permission: models.PermOperatorsManage},
+	"PUT /admin/operators/:id":    {Permission: models.PermOperatorsManage},
+	"DELETE /admin/operators/:id": {Permission: models.PermOperatorsManage},
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+// AuthSessionRepository defines the storage of customer login sessions
+type AuthSessionRepository interface {
+	CreateSession(session *models.AuthSession) error
+	GetSession(id uuid.UUID) (*models.AuthSession, error)
+	RotateRefreshID(id, currentRefreshID, newRefreshID uuid.UUID, expiresAt time.Time) (bool, error)
+	RevokeSession(id uuid.UUID) error
+	RevokeOperatorSessions(operatorID uuid.UUID) error
+	RevokeCustomerSessions(customerID, keepID uuid.UUID) error
+}
+
+// authSessionRepository implements AuthSessionRepository using Gorm
+type authSessionRepository struct {
+	db *gorm.DB
+}
+
+// NewAuthSessionRepository creates a new authSessionRepository instance
+func NewAuthSessionRepository(db *gorm.DB) AuthSessionRepository {
+	return &authSessionRepository{db}
+}
+
+// CreateSession inserts a new session
+func (ar *authSessionRepository) CreateSession(session *models.AuthSession) error {
+	return ar.db.Create(session).Error
+}
+
+// GetSession retrieves a session by ID
+func (ar *authSessionRepository) GetSession(id uuid.UUID) (*models.AuthSession, error) {
+	var session models.AuthSession
+	if err := ar.db.First(&session, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RotateRefreshID replaces the session's refresh ID and extends its expiry, provided the session is
+// not revoked and its refresh ID is still currentRefreshID. It reports whether the session was updated,
+// so that of two concurrent refreshes with the same token only one succeeds.
+func (ar *authSessionRepository) RotateRefreshID(id, currentRefreshID, newRefreshID uuid.UUID, expiresAt time.Time) (bool, error) {
+	result := ar.db.Model(&models.AuthSession{}).
+		Where("id = ? AND refresh_id = ? AND revoked_at IS NULL", id, currentRefreshID).
+		Updates(map[string]interface{}{"refresh_id": newRefreshID, "expires_at": expiresAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeSession marks the session as revoked; revoking it again keeps the original time
+func (ar *authSessionRepository) RevokeSession(id uuid.UUID) error {
+	return ar.db.Model(&models.AuthSession{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeOperatorSessions revokes all active sessions of an operator
+func (ar *authSessionRepository) RevokeOperatorSessions(operatorID uuid.UUID) error {
+	return ar.db.Model(&models.AuthSession{}).
+		Where("operator_id = ? AND revoked_at IS NULL", operatorID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeCustomerSessions revokes all active sessions of a customer except the one with keepID,
+// which may be uuid.Nil to revoke them all
+func (ar *authSessionRepository) RevokeCustomerSessions(customerID, keepID uuid.UUID) error {
+	return ar.db.Model(&models.AuthSession{}).
+		Where("customer_id = ? AND id <> ? AND revoked_at IS NULL", customerID, keepID).
+		Update("revoked_at", time.Now()).Error
+}
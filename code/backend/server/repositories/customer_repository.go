@@ -1,6 +1,10 @@
 package repositories
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -12,11 +16,17 @@ import (
 type CustomerRepository interface {
 	GetAllCustomers() ([]*models.Customer, error)
 	GetLimitedCustomers(num int) ([]*models.Customer, error)
+	QueryCustomers(query *models.CustomerQuery) ([]*models.CustomerDTO, int64, error)
 	CreateCustomer(customer *models.Customer) error
 	CreateMultiCustomers(customers []*models.Customer) (int64, error)
 	GetCustomerByID(id uuid.UUID) (*models.Customer, error)
+	GetCustomerByEmail(email string) (*models.Customer, error)
+	GetCustomerWithPassword(id uuid.UUID) (*models.Customer, error)
+	GetRegistrationTimes(ids []uuid.UUID) (map[uuid.UUID]time.Time, error)
+	GetExistingIDs(ids []uuid.UUID) (map[uuid.UUID]bool, error)
 	UpdateCustomer(customer *models.Customer) error
 	UpdatePassword(customer *models.Customer) error
+	ReplacePasswordHash(id uuid.UUID, oldHash, newHash string) error
 	ResetAllCustomerData() error
 	// DeleteCustomer(id uuid.UUID) error
 }
@@ -49,6 +59,126 @@ func (cr *customerRepository) GetLimitedCustomers(num int) ([]*models.Customer,
 	return customers, nil
 }
 
+// customerSortColumns maps sort keys to columns of the customer summary subquery
+var customerSortColumns = map[models.CustomerSortKey]string{
+	models.SortByName:                   "name",
+	models.SortByEmail:                  "email",
+	models.SortByGender:                 "gender",
+	models.SortByTotalTransactionAmount: "total_transaction_amount",
+}
+
+// QueryCustomers retrieves one page of customers with their totals in the query window, applying
+// filters, sorting and keyset pagination in SQL. Totals net out refunds and reversals and are
+// converted into the query currency.
+// Only sorting or filtering by total aggregates the transactions of all customers; otherwise the page
+// is selected first and only its customers' transactions are summed.
+// It also returns the number of customers matching the filters regardless of the page.
+func (cr *customerRepository) QueryCustomers(query *models.CustomerQuery) ([]*models.CustomerDTO, int64, error) {
+	byTotal := query.SortBy == models.SortByTotalTransactionAmount || query.MinTotal != nil || query.MaxTotal != nil
+
+	// Gender is cast to CHAR so that ordering and cursor comparisons both use string
+	// semantics instead of the enum index
+	columns := "customers.id, customers.name, customers.email, CAST(customers.gender AS CHAR) AS gender, customers.created_at"
+	summaries := cr.db.Model(&models.Customer{}).Select(columns)
+	if byTotal {
+		inWindow := cr.db.Table("transactions AS t").
+			Where("t.time >= ? AND t.time < ?", query.Window.From, query.Window.To).
+			Session(&gorm.Session{})
+		if err := findMissingRate(inWindow, query.Currency); err != nil {
+			return nil, 0, err
+		}
+		totals := inWindow.Select("t.customer_id, SUM(?) AS total_amount", netAmount(query.Currency)).
+			Group("t.customer_id")
+		summaries = summaries.
+			Select(columns+", COALESCE(totals.total_amount, 0) AS total_transaction_amount").
+			Joins("LEFT JOIN (?) AS totals ON totals.customer_id = customers.id", totals)
+	}
+
+	filtered := cr.db.Table("(?) AS summaries", summaries)
+	if query.Gender != "" {
+		filtered = filtered.Where("gender = ?", query.Gender)
+	}
+	if query.NamePrefix != "" {
+		filtered = filtered.Where("name LIKE ?", escapeLike(query.NamePrefix)+"%")
+	}
+	if query.EmailPrefix != "" {
+		filtered = filtered.Where("email LIKE ?", escapeLike(query.EmailPrefix)+"%")
+	}
+	if query.Segment != "" {
+		scored := cr.db.Model(&models.CustomerRFMScore{}).Select("customer_id").Where("segment = ?", query.Segment)
+		filtered = filtered.Where("id IN (?)", scored)
+	}
+	if query.MinTotal != nil {
+		filtered = filtered.Where("total_transaction_amount >= CAST(? AS DECIMAL(20,2))", *query.MinTotal)
+	}
+	if query.MaxTotal != nil {
+		filtered = filtered.Where("total_transaction_amount <= CAST(? AS DECIMAL(20,2))", *query.MaxTotal)
+	}
+	filtered = filtered.Session(&gorm.Session{})
+
+	var totalCount int64
+	if err := filtered.Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+
+	column := customerSortColumns[query.SortBy]
+	direction, op := "ASC", ">"
+	if query.Order == models.Desc {
+		direction, op = "DESC", "<"
+	}
+
+	page := filtered
+	if query.Cursor != nil {
+		placeholder := "?"
+		if query.SortBy == models.SortByTotalTransactionAmount {
+			placeholder = "CAST(? AS DECIMAL(20,2))"
+		}
+		cond := fmt.Sprintf("%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND id %[2]s ?)", column, op, placeholder)
+		page = page.Where(cond, query.Cursor.Value, query.Cursor.Value, query.Cursor.ID)
+	}
+	page = page.Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(query.PageSize)
+
+	var customers []*models.CustomerDTO
+	if byTotal {
+		if err := page.Scan(&customers).Error; err != nil {
+			return nil, 0, err
+		}
+		return customers, totalCount, nil
+	}
+
+	// Sum the transactions of the page's customers only, flagging those that cannot be converted
+	var rows []struct {
+		models.CustomerDTO
+		MissingRate *string
+	}
+	err := cr.db.Table("(?) AS p", page).
+		Select("p.id, p.name, p.email, p.gender, p.created_at, "+
+			"COALESCE(SUM(?), 0) AS total_transaction_amount, "+
+			"MIN(CASE WHEN t.id IS NOT NULL AND (?) IS NULL THEN CONCAT(DATE(t.time), ' ', t.currency) END) AS missing_rate",
+			netAmount(query.Currency), convertedAmount(query.Currency)).
+		Joins("LEFT JOIN transactions AS t ON t.customer_id = p.id AND t.time >= ? AND t.time < ?", query.Window.From, query.Window.To).
+		Group("p.id, p.name, p.email, p.gender, p.created_at").
+		Order(fmt.Sprintf("p.%s %s, p.id %s", column, direction, direction)).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	customers = make([]*models.CustomerDTO, len(rows))
+	for i := range rows {
+		if rows[i].MissingRate != nil {
+			return nil, 0, parseMissingRate(*rows[i].MissingRate, query.Currency)
+		}
+		customers[i] = &rows[i].CustomerDTO
+	}
+	return customers, totalCount, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 // CreateCustomer inserts a single customer into the database
 func (cr *customerRepository) CreateCustomer(customer *models.Customer) error {
 	return cr.db.Create(customer).Error
@@ -70,6 +200,64 @@ func (cr *customerRepository) GetCustomerByID(id uuid.UUID) (*models.Customer, e
 	return &customer, nil
 }
 
+// GetCustomerByEmail retrieves a customer by email, including the Password field for verification
+func (cr *customerRepository) GetCustomerByEmail(email string) (*models.Customer, error) {
+	var customer models.Customer
+	if err := cr.db.First(&customer, "email = ?", email).Error; err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// GetCustomerWithPassword retrieves a customer by ID, including the Password field for verification
+func (cr *customerRepository) GetCustomerWithPassword(id uuid.UUID) (*models.Customer, error) {
+	var customer models.Customer
+	if err := cr.db.First(&customer, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// GetRegistrationTimes retrieves the registration time of each of the given customers.
+// Customers that do not exist are absent from the result.
+func (cr *customerRepository) GetRegistrationTimes(ids []uuid.UUID) (map[uuid.UUID]time.Time, error) {
+	registrationTimes := make(map[uuid.UUID]time.Time)
+	if len(ids) == 0 {
+		return registrationTimes, nil
+	}
+
+	var results []struct {
+		ID        uuid.UUID
+		CreatedAt time.Time
+	}
+	if err := cr.db.Model(&models.Customer{}).Select("id, created_at").Where("id IN ?", ids).Scan(&results).Error; err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		registrationTimes[result.ID] = result.CreatedAt
+	}
+	return registrationTimes, nil
+}
+
+// GetExistingIDs reports which of the given customer IDs exist in the database
+func (cr *customerRepository) GetExistingIDs(ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	existing := make(map[uuid.UUID]bool)
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	var found []uuid.UUID
+	if err := cr.db.Model(&models.Customer{}).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return nil, err
+	}
+
+	for _, id := range found {
+		existing[id] = true
+	}
+	return existing, nil
+}
+
 // UpdateCustomer updates the Name, Email, and Gender fields of a customer
 func (cr *customerRepository) UpdateCustomer(customer *models.Customer) error {
 	return cr.db.Model(&customer).Select("Name", "Email", "Gender").Updates(customer).Error
@@ -80,14 +268,22 @@ func (cr *customerRepository) UpdatePassword(customer *models.Customer) error {
 	return cr.db.Model(&customer).Select("Password").Updates(customer).Error
 }
 
+// ReplacePasswordHash replaces a customer's password hash with an upgraded one, unless the password was
+// changed since oldHash was read
+func (cr *customerRepository) ReplacePasswordHash(id uuid.UUID, oldHash, newHash string) error {
+	return cr.db.Model(&models.Customer{}).Where("id = ? AND password = ?", id, oldHash).Update("password", newHash).Error
+}
+
 // ResetAllCustomerData deletes all customer records and associated data
 func (cr *customerRepository) ResetAllCustomerData() error {
-	err := cr.db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.Customer{}).Error
-	if err != nil {
-		return err
-	}
-	// Related data is deleted due to foreign key constraints, if any
-	return nil
+	return cr.db.Transaction(func(tx *gorm.DB) error {
+		// Audit entries restrict the deletion of their transactions, so they are removed deliberately first
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.TransactionAudit{}).Error; err != nil {
+			return err
+		}
+		// Related data is deleted due to foreign key constraints, if any
+		return tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.Customer{}).Error
+	})
 }
 
 // DeleteCustomer deletes a customer by ID
@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+// FXRateRepository defines the interface for FX rate data operations
+type FXRateRepository interface {
+	GetRates(currency models.Currency) ([]*models.FXRate, error)
+	UpsertRates(rates []*models.FXRate) error
+}
+
+// fxRateRepository is the concrete implementation of FXRateRepository
+type fxRateRepository struct {
+	db *gorm.DB
+}
+
+// NewFXRateRepository returns a new instance of fxRateRepository
+func NewFXRateRepository(db *gorm.DB) FXRateRepository {
+	return &fxRateRepository{db}
+}
+
+// GetRates retrieves the rates of a currency, or of every currency when it is empty, ordered by date
+func (fr *fxRateRepository) GetRates(currency models.Currency) ([]*models.FXRate, error) {
+	var rates []*models.FXRate
+	query := fr.db.Order("currency, effective_date")
+	if currency != "" {
+		query = query.Where("currency = ?", currency)
+	}
+	if err := query.Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// UpsertRates inserts the rates in a single database transaction, replacing existing rates
+// of the same currency and date
+func (fr *fxRateRepository) UpsertRates(rates []*models.FXRate) error {
+	batchSize := 500
+	return fr.db.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"})}).
+		CreateInBatches(rates, batchSize).Error
+}
+
+// fxRateSQL selects the rate of the currency given by expr that was in effect on the date of
+// transaction t, i.e. the latest rate dated on or before that day
+func fxRateSQL(expr string) string {
+	return fmt.Sprintf("CASE WHEN %[1]s = @base THEN 1 ELSE (SELECT r.rate FROM fx_rates r "+
+		"WHERE r.currency = %[1]s AND r.effective_date <= DATE(t.time) "+
+		"ORDER BY r.effective_date DESC LIMIT 1) END", expr)
+}
+
+// convertedAmountSQL converts the amount of transaction t into the reporting currency through the
+// base currency, rounding half away from zero to cents. It is NULL when a needed rate is missing.
+var convertedAmountSQL = "CASE WHEN t.currency = @currency THEN t.amount ELSE ROUND(t.amount * (" +
+	fxRateSQL("t.currency") + ") / (" + fxRateSQL("@currency") + "), 2) END"
+
+// convertedAmount returns the expression converting the amount of transaction t into currency
+func convertedAmount(currency models.Currency) clause.Expression {
+	return clause.NamedExpr{SQL: convertedAmountSQL, Vars: []interface{}{
+		sql.Named("currency", string(currency)),
+		sql.Named("base", string(models.BaseCurrency)),
+	}}
+}
+
+// findMissingRate returns a MissingFXRateError for the first transaction in scope, a query over
+// "transactions AS t", whose amount cannot be converted into currency
+func findMissingRate(scope *gorm.DB, currency models.Currency) error {
+	var missing []struct {
+		Currency models.Currency
+		Day      time.Time
+	}
+	err := scope.Select("t.currency, DATE(t.time) AS day").
+		Where("(?) IS NULL", convertedAmount(currency)).
+		Limit(1).
+		Scan(&missing).Error
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return &models.MissingFXRateError{From: missing[0].Currency, To: currency, Date: missing[0].Day}
+	}
+	return nil
+}
+
+// parseMissingRate converts the "YYYY-MM-DD CUR" day and currency of a transaction flagged by a report
+// query as not convertible into currency to a MissingFXRateError
+func parseMissingRate(value string, currency models.Currency) error {
+	day, from, ok := strings.Cut(value, " ")
+	date, err := time.Parse("2006-01-02", day)
+	if !ok || err != nil {
+		return fmt.Errorf("invalid missing rate marker: %q", value)
+	}
+	return &models.MissingFXRateError{From: models.Currency(from), To: currency, Date: date}
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+// IdempotencyRepository defines the interface for idempotency record operations
+type IdempotencyRepository interface {
+	GetRecord(principal, key string) (*models.IdempotencyRecord, error)
+	ClaimRecord(record *models.IdempotencyRecord, staleBefore, expiredBefore time.Time) (bool, error)
+	CompleteRecord(record *models.IdempotencyRecord) (bool, error)
+	DeleteRecord(record *models.IdempotencyRecord) error
+	PurgeRecords(before time.Time) (int64, error)
+}
+
+// idempotencyRepository implements IdempotencyRepository using Gorm
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+// NewIdempotencyRepository creates a new idempotencyRepository instance
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db}
+}
+
+// GetRecord retrieves the record for a caller's key, returning nil if the key has not been used
+func (ir *idempotencyRepository) GetRecord(principal, key string) (*models.IdempotencyRecord, error) {
+	var record models.IdempotencyRecord
+	err := ir.db.First(&record, "principal = ? AND idempotency_key = ?", principal, key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ClaimRecord claims a key for an in-progress request, taking over a record that is still pending but was
+// claimed before staleBefore, whose request is assumed to have died, or any record created before
+// expiredBefore. record.CreatedAt identifies the claim in CompleteRecord and DeleteRecord.
+// Returns false if another request holds the key.
+func (ir *idempotencyRepository) ClaimRecord(record *models.IdempotencyRecord, staleBefore, expiredBefore time.Time) (bool, error) {
+	record.StatusCode = 0
+	record.ResponseBody = ""
+	record.CreatedAt = time.Now().UTC().Truncate(time.Second) // the column stores whole seconds
+	result := ir.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// A single conditional update, so only one of several concurrent retries takes the record over
+	result = ir.db.Model(&models.IdempotencyRecord{}).
+		Where("principal = ? AND idempotency_key = ?", record.Principal, record.Key).
+		Where("(status_code = 0 AND created_at < ?) OR created_at < ?", staleBefore, expiredBefore).
+		Updates(map[string]interface{}{
+			"fingerprint":   record.Fingerprint,
+			"status_code":   0,
+			"response_body": gorm.Expr("NULL"),
+			"created_at":    record.CreatedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CompleteRecord stores the response of the request holding the key. Returns false if the claim was
+// taken over in the meantime.
+func (ir *idempotencyRepository) CompleteRecord(record *models.IdempotencyRecord) (bool, error) {
+	result := ir.db.Model(&models.IdempotencyRecord{}).
+		Where("principal = ? AND idempotency_key = ? AND created_at = ? AND status_code = 0",
+			record.Principal, record.Key, record.CreatedAt).
+		Updates(map[string]interface{}{
+			"status_code":   record.StatusCode,
+			"response_body": record.ResponseBody,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteRecord releases a claimed key so the request can be retried
+func (ir *idempotencyRepository) DeleteRecord(record *models.IdempotencyRecord) error {
+	return ir.db.Delete(&models.IdempotencyRecord{},
+		"principal = ? AND idempotency_key = ? AND created_at = ? AND status_code = 0",
+		record.Principal, record.Key, record.CreatedAt).Error
+}
+
+// PurgeRecords deletes the records created before the given time and returns how many were deleted
+func (ir *idempotencyRepository) PurgeRecords(before time.Time) (int64, error) {
+	result := ir.db.Delete(&models.IdempotencyRecord{}, "created_at < ?", before)
+	return result.RowsAffected, result.Error
+}
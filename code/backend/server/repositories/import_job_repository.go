@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+// ImportJobRepository defines the interface for import job data operations
+type ImportJobRepository interface {
+	CreateJob(job *models.ImportJob) error
+	GetJobByID(id uuid.UUID) (*models.ImportJob, error)
+	UpdateProgress(job *models.ImportJob) (bool, error)
+	FinishJob(job *models.ImportJob) error
+	CancelJob(id uuid.UUID) (bool, error)
+	TouchJob(id uuid.UUID) error
+	FailStaleJobs(before time.Time, message string) (int64, error)
+}
+
+// importJobRepository implements ImportJobRepository using Gorm
+type importJobRepository struct {
+	db *gorm.DB
+}
+
+// NewImportJobRepository creates a new importJobRepository instance
+func NewImportJobRepository(db *gorm.DB) ImportJobRepository {
+	return &importJobRepository{db}
+}
+
+// CreateJob inserts a new import job
+func (jr *importJobRepository) CreateJob(job *models.ImportJob) error {
+	return jr.db.Create(job).Error
+}
+
+// GetJobByID retrieves an import job by ID
+func (jr *importJobRepository) GetJobByID(id uuid.UUID) (*models.ImportJob, error) {
+	var job models.ImportJob
+	if err := jr.db.First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// UpdateProgress stores the counters and errors of a job that is still active.
+// Returns false if the job was cancelled in the meantime, leaving it untouched.
+func (jr *importJobRepository) UpdateProgress(job *models.ImportJob) (bool, error) {
+	result := jr.db.Model(job).
+		Where("status IN ?", []models.JobStatus{models.JobQueued, models.JobRunning}).
+		Select("Status", "Processed", "Accepted", "Rejected", "Errors", "UpdatedAt").
+		Updates(job)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FinishJob stores the final status, counters and errors of a job
+func (jr *importJobRepository) FinishJob(job *models.ImportJob) error {
+	now := time.Now()
+	job.FinishedAt = &now
+	return jr.db.Model(job).
+		Select("Status", "Processed", "Accepted", "Rejected", "Errors", "Message", "FinishedAt").
+		Updates(job).Error
+}
+
+// CancelJob marks a queued or running job as cancelled.
+// Returns false if the job had already finished.
+func (jr *importJobRepository) CancelJob(id uuid.UUID) (bool, error) {
+	now := time.Now()
+	result := jr.db.Model(&models.ImportJob{}).
+		Where("id = ? AND status IN ?", id, []models.JobStatus{models.JobQueued, models.JobRunning}).
+		Updates(map[string]interface{}{"status": models.JobCancelled, "finished_at": now})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// TouchJob records that a queued or running job is still being processed
+func (jr *importJobRepository) TouchJob(id uuid.UUID) error {
+	return jr.db.Model(&models.ImportJob{}).
+		Where("id = ? AND status IN ?", id, []models.JobStatus{models.JobQueued, models.JobRunning}).
+		Update("updated_at", time.Now()).Error
+}
+
+// FailStaleJobs marks the queued and running jobs not updated since before as failed with the given
+// message, and returns how many were marked
+func (jr *importJobRepository) FailStaleJobs(before time.Time, message string) (int64, error) {
+	now := time.Now()
+	result := jr.db.Model(&models.ImportJob{}).
+		Where("status IN ? AND updated_at < ?", []models.JobStatus{models.JobQueued, models.JobRunning}, before).
+		Updates(map[string]interface{}{"status": models.JobFailed, "message": message, "finished_at": now})
+	return result.RowsAffected, result.Error
+}
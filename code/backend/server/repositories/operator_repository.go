@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+// OperatorRepository defines the storage of operator accounts
+type OperatorRepository interface {
+	GetAllOperators() ([]*models.Operator, error)
+	GetOperatorByID(id uuid.UUID) (*models.Operator, error)
+	GetOperatorByEmail(email string) (*models.Operator, error)
+	CountActiveAdmins() (int64, error)
+	CreateOperator(operator *models.Operator) error
+	UpdateOperator(operator *models.Operator) error
+	ReplacePasswordHash(id uuid.UUID, oldHash, newHash string) error
+	DeleteOperator(id uuid.UUID) error
+}
+
+// operatorRepository implements OperatorRepository using Gorm
+type operatorRepository struct {
+	db *gorm.DB
+}
+
+// NewOperatorRepository creates a new operatorRepository instance
+func NewOperatorRepository(db *gorm.DB) OperatorRepository {
+	return &operatorRepository{db}
+}
+
+// GetAllOperators retrieves all operators ordered by email
+func (opr *operatorRepository) GetAllOperators() ([]*models.Operator, error) {
+	var operators []*models.Operator
+	if err := opr.db.Order("email").Find(&operators).Error; err != nil {
+		return nil, err
+	}
+	return operators, nil
+}
+
+// GetOperatorByID retrieves an operator by ID
+func (opr *operatorRepository) GetOperatorByID(id uuid.UUID) (*models.Operator, error) {
+	var operator models.Operator
+	if err := opr.db.First(&operator, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &operator, nil
+}
+
+// GetOperatorByEmail retrieves an operator by email, including the Password field for verification
+func (opr *operatorRepository) GetOperatorByEmail(email string) (*models.Operator, error) {
+	var operator models.Operator
+	if err := opr.db.First(&operator, "email = ?", email).Error; err != nil {
+		return nil, err
+	}
+	return &operator, nil
+}
+
+// CountActiveAdmins counts the admins that are not disabled
+func (opr *operatorRepository) CountActiveAdmins() (int64, error) {
+	var count int64
+	err := opr.db.Model(&models.Operator{}).
+		Where("role = ? AND disabled_at IS NULL", models.RoleAdmin).
+		Count(&count).Error
+	return count, err
+}
+
+// CreateOperator inserts a new operator
+func (opr *operatorRepository) CreateOperator(operator *models.Operator) error {
+	return opr.db.Create(operator).Error
+}
+
+// UpdateOperator updates the Name, Role and DisabledAt fields of an operator
+func (opr *operatorRepository) UpdateOperator(operator *models.Operator) error {
+	return opr.db.Model(operator).Select("Name", "Role", "DisabledAt").Updates(operator).Error
+}
+
+// ReplacePasswordHash replaces an operator's password hash with an upgraded one, unless the password was
+// changed since oldHash was read
+func (opr *operatorRepository) ReplacePasswordHash(id uuid.UUID, oldHash, newHash string) error {
+	return opr.db.Model(&models.Operator{}).Where("id = ? AND password = ?", id, oldHash).Update("password", newHash).Error
+}
+
+// DeleteOperator deletes an operator by ID; their sessions are deleted with them
+func (opr *operatorRepository) DeleteOperator(id uuid.UUID) error {
+	result := opr.db.Delete(&models.Operator{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
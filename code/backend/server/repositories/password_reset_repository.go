@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+// PasswordResetRepository defines the storage of password reset tokens
+type PasswordResetRepository interface {
+	ReplaceToken(token *models.PasswordResetToken) error
+	GetTokenByHash(tokenHash string) (*models.PasswordResetToken, error)
+	ConsumeToken(id uuid.UUID, passwordHash string, now time.Time) error
+}
+
+// passwordResetRepository implements PasswordResetRepository using Gorm
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetRepository creates a new passwordResetRepository instance
+func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
+	return &passwordResetRepository{db}
+}
+
+// ReplaceToken inserts a new token for a customer and deletes their unused ones, so that only the
+// latest reset link works
+func (pr *passwordResetRepository) ReplaceToken(token *models.PasswordResetToken) error {
+	return pr.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("customer_id = ? AND used_at IS NULL", token.CustomerID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// GetTokenByHash retrieves a token by the SHA-256 of its value
+func (pr *passwordResetRepository) GetTokenByHash(tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	if err := pr.db.First(&token, "token_hash = ?", tokenHash).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ConsumeToken marks the token as used and sets its customer's password hash in one transaction.
+// It returns gorm.ErrRecordNotFound if the token was used already or has expired at now, so that of
+// two concurrent resets with the same token only one succeeds.
+func (pr *passwordResetRepository) ConsumeToken(id uuid.UUID, passwordHash string, now time.Time) error {
+	return pr.db.Transaction(func(tx *gorm.DB) error {
+		var token models.PasswordResetToken
+		if err := tx.First(&token, "id = ?", id).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", id, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&models.Customer{}).Where("id = ?", token.CustomerID).Update("password", passwordHash).Error
+	})
+}
@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+// ReportRepository defines the cross-customer aggregations behind the reports
+type ReportRepository interface {
+	GetRevenueByPeriod(buckets []models.TimeBucket, currency models.Currency) ([]*models.RevenuePeriod, error)
+	GetActiveCustomersByPeriod(buckets []models.TimeBucket) ([]*models.CustomerCountPeriod, error)
+	GetNewCustomersByPeriod(buckets []models.TimeBucket) ([]*models.CustomerCountPeriod, error)
+	GetTopCustomers(window *models.AggregationWindow, currency models.Currency, limit int) ([]*models.TopCustomer, error)
+	GetRevenueByGender(window *models.AggregationWindow, currency models.Currency) ([]*models.GenderRevenue, error)
+}
+
+// reportRepository implements ReportRepository using Gorm
+type reportRepository struct {
+	db *gorm.DB
+}
+
+// NewReportRepository creates a new reportRepository instance
+func NewReportRepository(db *gorm.DB) ReportRepository {
+	return &reportRepository{db}
+}
+
+// bucketTable returns the buckets as the rows (idx, start_at, end_at) of a derived table, so that
+// joining data onto it still produces a row for every empty bucket
+func bucketTable(buckets []models.TimeBucket) clause.Expr {
+	parts := make([]string, len(buckets))
+	vars := make([]interface{}, 0, 3*len(buckets))
+	for i, bucket := range buckets {
+		parts[i] = "SELECT ? AS idx, ? AS start_at, ? AS end_at"
+		vars = append(vars, i, bucket.Start, bucket.End)
+	}
+	return clause.Expr{SQL: strings.Join(parts, " UNION ALL "), Vars: vars}
+}
+
+// GetRevenueByPeriod sums the net amounts of all transactions in each bucket, converted into currency
+func (rr *reportRepository) GetRevenueByPeriod(buckets []models.TimeBucket, currency models.Currency) ([]*models.RevenuePeriod, error) {
+	periods := make([]*models.RevenuePeriod, len(buckets))
+	for i, bucket := range buckets {
+		periods[i] = &models.RevenuePeriod{Start: bucket.Start, End: bucket.End}
+	}
+	if len(buckets) == 0 {
+		return periods, nil
+	}
+
+	scope := rr.db.Table("transactions AS t").
+		Where("t.time >= ? AND t.time < ?", buckets[0].Start, buckets[len(buckets)-1].End)
+	if err := findMissingRate(scope, currency); err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		Idx              int
+		Revenue          models.Money
+		TransactionCount int64
+	}
+	err := rr.db.Table("(?) AS b", bucketTable(buckets)).
+		Select("b.idx, COALESCE(SUM(?), 0) AS revenue, COUNT(t.id) AS transaction_count", netAmount(currency)).
+		Joins("LEFT JOIN transactions AS t ON t.time >= b.start_at AND t.time < b.end_at").
+		Group("b.idx").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		if result.Idx >= 0 && result.Idx < len(periods) {
+			periods[result.Idx].Revenue = result.Revenue
+			periods[result.Idx].TransactionCount = result.TransactionCount
+		}
+	}
+	return periods, nil
+}
+
+// GetActiveCustomersByPeriod counts the distinct customers with at least one purchase in each bucket
+func (rr *reportRepository) GetActiveCustomersByPeriod(buckets []models.TimeBucket) ([]*models.CustomerCountPeriod, error) {
+	return rr.countCustomersByPeriod(buckets, "COUNT(DISTINCT t.customer_id)",
+		"LEFT JOIN transactions AS t ON t.type = ? AND t.time >= b.start_at AND t.time < b.end_at", models.TypePurchase)
+}
+
+// GetNewCustomersByPeriod counts the customers registered in each bucket
+func (rr *reportRepository) GetNewCustomersByPeriod(buckets []models.TimeBucket) ([]*models.CustomerCountPeriod, error) {
+	return rr.countCustomersByPeriod(buckets, "COUNT(c.id)",
+		"LEFT JOIN customers AS c ON c.created_at >= b.start_at AND c.created_at < b.end_at")
+}
+
+// countCustomersByPeriod evaluates count over the rows joined onto each bucket
+func (rr *reportRepository) countCustomersByPeriod(buckets []models.TimeBucket, count string, join string, joinArgs ...interface{}) ([]*models.CustomerCountPeriod, error) {
+	periods := make([]*models.CustomerCountPeriod, len(buckets))
+	for i, bucket := range buckets {
+		periods[i] = &models.CustomerCountPeriod{Start: bucket.Start, End: bucket.End}
+	}
+	if len(buckets) == 0 {
+		return periods, nil
+	}
+
+	var results []struct {
+		Idx       int
+		Customers int64
+	}
+	err := rr.db.Table("(?) AS b", bucketTable(buckets)).
+		Select("b.idx, "+count+" AS customers").
+		Joins(join, joinArgs...).
+		Group("b.idx").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		if result.Idx >= 0 && result.Idx < len(periods) {
+			periods[result.Idx].Customers = result.Customers
+		}
+	}
+	return periods, nil
+}
+
+// GetTopCustomers ranks customers by their net amounts within the window, converted into currency,
+// and returns the first limit of them. Ties are broken by customer ID.
+func (rr *reportRepository) GetTopCustomers(window *models.AggregationWindow, currency models.Currency, limit int) ([]*models.TopCustomer, error) {
+	inWindow := rr.db.Table("transactions AS t").
+		Where("t.time >= ? AND t.time < ?", window.From, window.To).
+		Session(&gorm.Session{})
+	if err := findMissingRate(inWindow, currency); err != nil {
+		return nil, err
+	}
+	totals := inWindow.Select("t.customer_id, SUM(?) AS total_amount", netAmount(currency)).
+		Group("t.customer_id")
+
+	var customers []*models.TopCustomer
+	err := rr.db.Table("(?) AS totals", totals).
+		Select("c.id, c.name, c.email, totals.total_amount").
+		Joins("JOIN customers AS c ON c.id = totals.customer_id").
+		Order("totals.total_amount DESC, c.id").
+		Limit(limit).
+		Scan(&customers).Error
+	if err != nil {
+		return nil, err
+	}
+	return customers, nil
+}
+
+// GetRevenueByGender sums the net amounts within the window per customer gender, converted into currency.
+// Every gender is reported, with zeros when its customers have no transactions in the window.
+func (rr *reportRepository) GetRevenueByGender(window *models.AggregationWindow, currency models.Currency) ([]*models.GenderRevenue, error) {
+	inWindow := rr.db.Table("transactions AS t").
+		Where("t.time >= ? AND t.time < ?", window.From, window.To).
+		Session(&gorm.Session{})
+	if err := findMissingRate(inWindow, currency); err != nil {
+		return nil, err
+	}
+
+	// Gender is cast to CHAR so that it scans as a string rather than the enum index
+	var results []*models.GenderRevenue
+	err := inWindow.Select("CAST(c.gender AS CHAR) AS gender, COUNT(DISTINCT t.customer_id) AS customers, SUM(?) AS revenue", netAmount(currency)).
+		Joins("JOIN customers AS c ON c.id = t.customer_id").
+		Group("c.gender").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byGender := make(map[models.Gender]*models.GenderRevenue, len(results))
+	for _, result := range results {
+		byGender[result.Gender] = result
+	}
+	genders := []models.Gender{models.Male, models.Female, models.Other}
+	revenues := make([]*models.GenderRevenue, len(genders))
+	for i, gender := range genders {
+		revenues[i] = &models.GenderRevenue{Gender: gender}
+		if result, ok := byGender[gender]; ok {
+			revenues[i] = result
+		}
+	}
+	return revenues, nil
+}
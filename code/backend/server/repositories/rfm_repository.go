@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+// RFMRepository defines the storage and computation of customer RFM scores
+type RFMRepository interface {
+	ReplaceScores(run *models.RFMRun) (bool, error)
+	GetLatestRun() (*models.RFMRun, error)
+	GetSegmentCounts() ([]*models.RFMSegmentCount, error)
+	GetSegmentMembers(segment models.RFMSegment, pageSize int, cursor *models.RFMMemberCursor) ([]*models.RFMMember, error)
+}
+
+// rfmLockName is the MySQL named lock that serializes RFM recomputations across replicas
+const rfmLockName = "rfm_recompute"
+
+// rfmRepository implements RFMRepository using Gorm
+type rfmRepository struct {
+	db *gorm.DB
+}
+
+// NewRFMRepository creates a new rfmRepository instance
+func NewRFMRepository(db *gorm.DB) RFMRepository {
+	return &rfmRepository{db}
+}
+
+// rfmSegmentSQL maps the r_score and f_score columns of s to a segment following RFMSegmentRules
+var rfmSegmentSQL = func() string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for _, rule := range models.RFMSegmentRules {
+		fmt.Fprintf(&b, " WHEN s.r_score BETWEEN %d AND %d AND s.f_score BETWEEN %d AND %d THEN '%s'",
+			rule.MinR, rule.MaxR, rule.MinF, rule.MaxF, rule.Segment)
+	}
+	b.WriteString(" END")
+	return b.String()
+}()
+
+// ReplaceScores records the run and replaces all stored scores with those computed over its window.
+// Customers with purchases in the window are ranked into quantiles: R by the time of their last
+// purchase, F by their number of purchases and M by their net amount converted into the run's
+// currency. Ties are split by customer ID. The run's Customers is set to the number of scored customers.
+// Returns false without computing anything if another process is replacing the scores.
+func (rr *rfmRepository) ReplaceScores(run *models.RFMRun) (bool, error) {
+	acquired := false
+	err := rr.db.Connection(func(conn *gorm.DB) error {
+		var locked int
+		if err := conn.Raw("SELECT GET_LOCK(?, 0)", rfmLockName).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if locked != 1 {
+			return nil
+		}
+		acquired = true
+		defer conn.Exec("SELECT RELEASE_LOCK(?)", rfmLockName)
+		return replaceScores(conn, run)
+	})
+	return acquired, err
+}
+
+// replaceScores computes and stores the scores of run on db, which holds the RFM lock
+func replaceScores(db *gorm.DB, run *models.RFMRun) error {
+	inWindow := db.Table("transactions AS t").
+		Where("t.time >= ? AND t.time < ?", run.WindowFrom, run.WindowTo).
+		Session(&gorm.Session{})
+	if err := findMissingRate(inWindow, run.Currency); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(run).Error; err != nil {
+			return err
+		}
+
+		perCustomer := tx.Table("transactions AS t").
+			Select("t.customer_id, "+
+				"MAX(CASE WHEN t.type = ? THEN t.time END) AS last_purchase, "+
+				"SUM(CASE WHEN t.type = ? THEN 1 ELSE 0 END) AS frequency, "+
+				"SUM(?) AS monetary",
+				models.TypePurchase, models.TypePurchase, netAmount(run.Currency)).
+			Where("t.time >= ? AND t.time < ?", run.WindowFrom, run.WindowTo).
+			Group("t.customer_id").
+			Having("SUM(CASE WHEN t.type = ? THEN 1 ELSE 0 END) > 0", models.TypePurchase)
+		scored := tx.Table("(?) AS a", perCustomer).
+			Select(fmt.Sprintf("a.*, "+
+				"NTILE(%[1]d) OVER (ORDER BY a.last_purchase, a.customer_id) AS r_score, "+
+				"NTILE(%[1]d) OVER (ORDER BY a.frequency, a.customer_id) AS f_score, "+
+				"NTILE(%[1]d) OVER (ORDER BY a.monetary, a.customer_id) AS m_score", models.RFMScoreLevels))
+		rows := tx.Table("(?) AS s", scored).
+			Select("s.customer_id, ?, DATEDIFF(?, s.last_purchase), s.frequency, s.monetary, s.r_score, s.f_score, s.m_score, "+rfmSegmentSQL,
+				run.ID, run.WindowTo)
+
+		if err := tx.Exec("DELETE FROM customer_rfm_scores").Error; err != nil {
+			return err
+		}
+		result := tx.Exec("INSERT INTO customer_rfm_scores "+
+			"(customer_id, run_id, recency_days, frequency, monetary, r_score, f_score, m_score, segment) ?", rows)
+		if result.Error != nil {
+			return result.Error
+		}
+		run.Customers = result.RowsAffected
+		return tx.Model(run).Update("customers", run.Customers).Error
+	})
+}
+
+// GetLatestRun retrieves the most recent run, or gorm.ErrRecordNotFound if scores were never computed
+func (rr *rfmRepository) GetLatestRun() (*models.RFMRun, error) {
+	var run models.RFMRun
+	if err := rr.db.Order("id DESC").First(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetSegmentCounts counts the scored customers of each segment that has any
+func (rr *rfmRepository) GetSegmentCounts() ([]*models.RFMSegmentCount, error) {
+	var counts []*models.RFMSegmentCount
+	err := rr.db.Model(&models.CustomerRFMScore{}).
+		Select("segment, COUNT(*) AS customers").
+		Group("segment").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// GetSegmentMembers retrieves one page of the customers in a segment, highest monetary value first,
+// starting after the cursor if one is given
+func (rr *rfmRepository) GetSegmentMembers(segment models.RFMSegment, pageSize int, cursor *models.RFMMemberCursor) ([]*models.RFMMember, error) {
+	query := rr.db.Table("customer_rfm_scores AS s").
+		Select("s.*, c.name, c.email").
+		Joins("JOIN customers AS c ON c.id = s.customer_id").
+		Where("s.segment = ?", segment)
+	if cursor != nil {
+		query = query.Where("s.monetary < CAST(? AS DECIMAL(20,2)) OR (s.monetary = CAST(? AS DECIMAL(20,2)) AND s.customer_id > ?)",
+			cursor.Monetary, cursor.Monetary, cursor.ID)
+	}
+
+	var members []*models.RFMMember
+	err := query.Order("s.monetary DESC, s.customer_id").
+		Limit(pageSize).
+		Scan(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
@@ -1,78 +1,535 @@
 package repositories
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
 )
 
 // TransactionRepository defines the interface for transaction data operations
 type TransactionRepository interface {
-	GetTransactionsByCustomerID(id uuid.UUID) ([]*models.Transaction, error)
-	GetDateRangeTransactionsByCustomerID(customerID uuid.UUID, from string, to string) ([]*models.Transaction, error)
-	CreateMultiTransactions(transactions []*models.Transaction) error
-	GetTotalAmountsByCustomersInPastYear() (map[uuid.UUID]float64, error)
+	QueryTransactions(query *models.TransactionQuery) ([]*models.Transaction, error)
+	GetTransactionByID(id uuid.UUID) (*models.Transaction, error)
+	GetTransactionsByIDs(ids []uuid.UUID) (map[uuid.UUID]*models.Transaction, error)
+	GetRefundedAmounts(originalIDs []uuid.UUID) (map[uuid.UUID]models.Money, error)
+	CreateMultiTransactions(transactions []*models.Transaction, actor string) error
+	CreateTransactionsPartially(transactions []*models.Transaction, actor string) map[int]error
+	AppendCorrection(original, reversal, replacement *models.Transaction, actor, reason string) error
+	GetTransactionHistory(id uuid.UUID) ([]*models.TransactionAudit, error)
+	GetLedgerCustomerIDs() ([]uuid.UUID, error)
+	GetLedger(customerID uuid.UUID) ([]*models.Transaction, error)
+	GetLedgerHead(customerID uuid.UUID) (*models.LedgerHead, error)
+	GetUnsignedLedgerCustomerIDs() ([]uuid.UUID, error)
+	SignLegacyLedger(customerID uuid.UUID) ([]models.LedgerViolation, error)
+	GetTotalAmountsByCustomersInPastYear(currency models.Currency) (map[uuid.UUID]models.Money, error)
+	GetTotalAmountsByCustomerIDs(customerIDs []uuid.UUID, window *models.AggregationWindow, currency models.Currency) (map[uuid.UUID]models.Money, error)
+	GetSpendingStats(customerID uuid.UUID, buckets []models.TimeBucket, currency models.Currency) ([]*models.SpendingStats, error)
+	GetCohortActivity(months []models.TimeBucket, cohorts int, currency models.Currency) ([]*models.Cohort, error)
 	// CreateTransaction(transaction *models.Transaction) error
-	// UpdateTransaction(transaction *models.Transaction) error
-	// DeleteTransaction(id uuid.UUID) error
 }
 
 // transactionRepository is the concrete implementation of TransactionRepository
 type transactionRepository struct {
-	db *gorm.DB
+	db        *gorm.DB
+	ledgerKey *models.LedgerKey
 }
 
-// NewTransactionRepository returns a new instance of transactionRepository
-func NewTransactionRepository(db *gorm.DB) TransactionRepository {
-	return &transactionRepository{db}
+// NewTransactionRepository returns a new instance of transactionRepository that chains the ledgers
+// under ledgerKey
+func NewTransactionRepository(db *gorm.DB, ledgerKey *models.LedgerKey) TransactionRepository {
+	return &transactionRepository{db: db, ledgerKey: ledgerKey}
 }
 
-// GetTransactionsByCustomerID retrieves all transactions for a specific customer
-func (tr *transactionRepository) GetTransactionsByCustomerID(customerID uuid.UUID) ([]*models.Transaction, error) {
+// QueryTransactions retrieves one page of a customer's transactions, applying the time range,
+// amount filters, ordering and keyset pagination in SQL so that the (customer_id, time) index
+// serves the scan. Rows are returned in scan order: for a prev cursor that is the reverse of the
+// requested order. Amounts are converted into the query currency unless it is empty, in which
+// case the amount filters apply to the transactions' own amounts. Sequences count the purchases
+// of the customer's whole history, with purchases at the same time ordered by ID.
+// It fails with a MissingFXRateError if any amount in the range cannot be converted.
+func (tr *transactionRepository) QueryTransactions(query *models.TransactionQuery) ([]*models.Transaction, error) {
+	scope := tr.db.Table("transactions AS t").Where("t.customer_id = ?", query.CustomerID)
+	if !query.From.IsZero() && !query.To.IsZero() {
+		scope = scope.Where("t.time >= ? AND t.time < ?", query.From, query.To)
+	}
+	scope = scope.Session(&gorm.Session{})
+
+	columns, columnArgs := "t.*, COALESCE(n.sequence, 0) AS sequence", []interface{}{}
+	var amount clause.Expression = clause.Expr{SQL: "t.amount"}
+	if query.Currency != "" {
+		if err := findMissingRate(scope, query.Currency); err != nil {
+			return nil, err
+		}
+		amount = convertedAmount(query.Currency)
+		columns += ", ? AS converted_amount"
+		columnArgs = append(columnArgs, amount)
+	}
+
+	page := scope
+	if query.MinAmount != nil {
+		page = page.Where("(?) >= CAST(? AS DECIMAL(20,2))", amount, *query.MinAmount)
+	}
+	if query.MaxAmount != nil {
+		page = page.Where("(?) <= CAST(? AS DECIMAL(20,2))", amount, *query.MaxAmount)
+	}
+
+	ascending := query.Order != models.Desc
+	if query.Cursor != nil && query.Cursor.Direction == models.CursorPrev {
+		ascending = !ascending
+	}
+	direction, op := "ASC", ">"
+	if !ascending {
+		direction, op = "DESC", "<"
+	}
+	if query.Cursor != nil {
+		cond := fmt.Sprintf("t.time %[1]s ? OR (t.time = ? AND t.id %[1]s ?)", op)
+		page = page.Where(cond, query.Cursor.Time, query.Cursor.Time, query.Cursor.ID)
+	}
+
+	// Purchases are numbered over the customer's whole history, independent of the page filters
+	numbered := tr.db.Model(&models.Transaction{}).
+		Select("id, ROW_NUMBER() OVER (PARTITION BY customer_id ORDER BY time, id) AS sequence").
+		Where("customer_id = ? AND type = ?", query.CustomerID, models.TypePurchase)
+
 	var transactions []*models.Transaction
-	if err := tr.db.Where("customer_id = ?", customerID).Find(&transactions).Error; err != nil {
+	err := page.Select(columns, columnArgs...).
+		Joins("LEFT JOIN (?) AS n ON n.id = t.id", numbered).
+		Order(fmt.Sprintf("t.time %s, t.id %s", direction, direction)).
+		Limit(query.PageSize).
+		Find(&transactions).Error
+	if err != nil {
 		return nil, err
 	}
 	return transactions, nil
 }
 
-// GetDateRangeTransactionsByCustomerID retrieves transactions for a customer within a date range
-func (tr *transactionRepository) GetDateRangeTransactionsByCustomerID(customerID uuid.UUID, from string, to string) ([]*models.Transaction, error) {
+// GetTransactionByID retrieves a single transaction
+func (tr *transactionRepository) GetTransactionByID(id uuid.UUID) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := tr.db.First(&transaction, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// GetTransactionsByIDs retrieves the given transactions keyed by ID; IDs that do not exist are absent
+func (tr *transactionRepository) GetTransactionsByIDs(ids []uuid.UUID) (map[uuid.UUID]*models.Transaction, error) {
+	found := make(map[uuid.UUID]*models.Transaction)
+	if len(ids) == 0 {
+		return found, nil
+	}
+
 	var transactions []*models.Transaction
-	if err := tr.db.Where("customer_id = ? AND time BETWEEN ? AND ?", customerID, from, to).Find(&transactions).Error; err != nil {
+	if err := tr.db.Where("id IN ?", ids).Find(&transactions).Error; err != nil {
 		return nil, err
 	}
-	return transactions, nil
+	for _, txn := range transactions {
+		found[txn.ID] = txn
+	}
+	return found, nil
 }
 
-// CreateMultiTransactions inserts multiple transaction records into the database
-func (tr *transactionRepository) CreateMultiTransactions(transactions []*models.Transaction) error {
+// ExceedsOriginalError is returned when appending a refund or reversal would take back more than the
+// purchase it references
+type ExceedsOriginalError struct {
+	Index    int // of the offending transaction among those being appended
+	Refunded models.Money
+	Original models.Money
+}
+
+func (e *ExceedsOriginalError) Error() string {
+	return fmt.Sprintf("%s already refunded of the original %s", e.Refunded, e.Original)
+}
+
+// GetRefundedAmounts sums the refunds and reversals already recorded against each of the given purchases
+func (tr *transactionRepository) GetRefundedAmounts(originalIDs []uuid.UUID) (map[uuid.UUID]models.Money, error) {
+	return refundedAmounts(tr.db, originalIDs)
+}
+
+// refundedAmounts sums the refunds and reversals recorded against each of the given purchases
+func refundedAmounts(db *gorm.DB, originalIDs []uuid.UUID) (map[uuid.UUID]models.Money, error) {
+	refunded := make(map[uuid.UUID]models.Money)
+	if len(originalIDs) == 0 {
+		return refunded, nil
+	}
+
+	var results []struct {
+		OriginalTransactionID uuid.UUID
+		Refunded              models.Money
+	}
+	err := db.Model(&models.Transaction{}).
+		Select("original_transaction_id, SUM(amount) AS refunded").
+		Where("original_transaction_id IN ? AND type IN ?", originalIDs, []models.TransactionType{models.TypeRefund, models.TypeReversal}).
+		Group("original_transaction_id").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		refunded[result.OriginalTransactionID] = result.Refunded
+	}
+	return refunded, nil
+}
+
+// CreateMultiTransactions appends multiple transactions to their customers' ledgers in a single
+// database transaction, auditing each of them as created by actor
+func (tr *transactionRepository) CreateMultiTransactions(transactions []*models.Transaction, actor string) error {
+	return tr.db.Transaction(func(tx *gorm.DB) error {
+		return appendToLedger(tx, tr.ledgerKey, transactions, actor, "")
+	})
+}
+
+// CreateTransactionsPartially appends transactions in batches, falling back to row-by-row inserts
+// for a batch the database refuses. It returns the errors of the rows that could not be inserted,
+// keyed by their index in transactions.
+func (tr *transactionRepository) CreateTransactionsPartially(transactions []*models.Transaction, actor string) map[int]error {
+	failures := make(map[int]error)
 	batchSize := 100
-	return tr.db.CreateInBatches(transactions, batchSize).Error
+	for start := 0; start < len(transactions); start += batchSize {
+		end := min(start+batchSize, len(transactions))
+		err := tr.db.Transaction(func(tx *gorm.DB) error {
+			return appendToLedger(tx, tr.ledgerKey, transactions[start:end], actor, "")
+		})
+		if err == nil {
+			continue
+		}
+
+		// Isolate the offending rows; the failed batch was rolled back entirely
+		for i := start; i < end; i++ {
+			err := tr.db.Transaction(func(tx *gorm.DB) error {
+				return appendToLedger(tx, tr.ledgerKey, transactions[i:i+1], actor, "")
+			})
+			if err != nil {
+				failures[i] = err
+			}
+		}
+	}
+	return failures
+}
+
+// AppendCorrection appends the reversal of original and its replacement in a single database
+// transaction, auditing the correction with the values before and after. It fails with an
+// ExceedsOriginalError if original was refunded or reversed, checked under the ledger lock so that
+// concurrent corrections cannot both reverse it.
+func (tr *transactionRepository) AppendCorrection(original, reversal, replacement *models.Transaction, actor, reason string) error {
+	return tr.db.Transaction(func(tx *gorm.DB) error {
+		if err := appendToLedger(tx, tr.ledgerKey, []*models.Transaction{reversal, replacement}, actor, reason); err != nil {
+			return err
+		}
+		return tx.Create(&models.TransactionAudit{
+			TransactionID: original.ID,
+			Action:        models.AuditCorrect,
+			Actor:         actor,
+			Reason:        reason,
+			Before:        original.Snapshot(),
+			After:         replacement.Snapshot(),
+		}).Error
+	})
+}
+
+// appendToLedger chains transactions under key onto the signed heads of their customers' ledgers,
+// inserts them together with an audit entry each and moves the heads. It must run inside a database
+// transaction: the customers are locked so that concurrent appends to the same ledger are serialized.
+// Refunds and reversals that would take back more than their purchase fail with an ExceedsOriginalError.
+func appendToLedger(tx *gorm.DB, key *models.LedgerKey, transactions []*models.Transaction, actor, reason string) error {
+	seen := make(map[uuid.UUID]bool)
+	var customerIDs []uuid.UUID
+	for _, txn := range transactions {
+		if !seen[txn.CustomerID] {
+			seen[txn.CustomerID] = true
+			customerIDs = append(customerIDs, txn.CustomerID)
+		}
+	}
+
+	// Lock in ID order so that two batches sharing customers cannot deadlock
+	var locked []uuid.UUID
+	err := tx.Model(&models.Customer{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", customerIDs).
+		Order("id").
+		Pluck("id", &locked).Error
+	if err != nil {
+		return err
+	}
+	if err := checkRefundLimits(tx, transactions); err != nil {
+		return err
+	}
+
+	heads, err := loadLedgerHeads(tx, key, customerIDs)
+	if err != nil {
+		return err
+	}
+	lastSeq := make(map[uuid.UUID]int64)
+	lastHash := make(map[uuid.UUID]string)
+	for _, head := range heads {
+		lastSeq[head.CustomerID] = head.LedgerSeq
+		lastHash[head.CustomerID] = head.EntryHash
+	}
+
+	audits := make([]*models.TransactionAudit, len(transactions))
+	for i, txn := range transactions {
+		prevHash, ok := lastHash[txn.CustomerID]
+		if !ok {
+			prevHash = models.GenesisHash
+		}
+		txn.LedgerSeq = lastSeq[txn.CustomerID] + 1
+		txn.PrevHash = prevHash
+		txn.EntryHash = txn.ComputeHash(prevHash, key)
+		lastSeq[txn.CustomerID] = txn.LedgerSeq
+		lastHash[txn.CustomerID] = txn.EntryHash
+
+		audits[i] = &models.TransactionAudit{
+			TransactionID: txn.ID,
+			Action:        models.AuditCreate,
+			Actor:         actor,
+			Reason:        reason,
+			After:         txn.Snapshot(),
+		}
+	}
+
+	batchSize := 100
+	if err := tx.CreateInBatches(transactions, batchSize).Error; err != nil {
+		return err
+	}
+	if err := tx.CreateInBatches(audits, batchSize).Error; err != nil {
+		return err
+	}
+
+	lastEntries := make(map[uuid.UUID]*models.Transaction)
+	for _, txn := range transactions {
+		lastEntries[txn.CustomerID] = txn
+	}
+	newHeads := make([]*models.LedgerHead, 0, len(lastEntries))
+	for _, customerID := range customerIDs {
+		head := &models.LedgerHead{}
+		head.Sign(lastEntries[customerID], key)
+		newHeads = append(newHeads, head)
+	}
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(newHeads).Error
+}
+
+// loadLedgerHeads retrieves the signed heads of the customers' ledgers. Ledgers with entries must have
+// a head signed under key: chaining onto a forged or missing head would hide removed entries, so the
+// append is refused instead.
+func loadLedgerHeads(tx *gorm.DB, key *models.LedgerKey, customerIDs []uuid.UUID) ([]*models.LedgerHead, error) {
+	var heads []*models.LedgerHead
+	if err := tx.Where("customer_id IN ?", customerIDs).Find(&heads).Error; err != nil {
+		return nil, err
+	}
+	signed := make(map[uuid.UUID]bool)
+	for _, head := range heads {
+		if !head.Verify(key) {
+			return nil, fmt.Errorf("the ledger head of customer %s is not signed with the ledger key; run `server ledger verify`", head.CustomerID)
+		}
+		signed[head.CustomerID] = true
+	}
+
+	var unsigned []uuid.UUID
+	for _, customerID := range customerIDs {
+		if !signed[customerID] {
+			unsigned = append(unsigned, customerID)
+		}
+	}
+	if len(unsigned) == 0 {
+		return heads, nil
+	}
+	var withEntries []uuid.UUID
+	if err := tx.Model(&models.Transaction{}).Distinct().Where("customer_id IN ?", unsigned).Pluck("customer_id", &withEntries).Error; err != nil {
+		return nil, err
+	}
+	if len(withEntries) > 0 {
+		return nil, fmt.Errorf("the ledger of customer %s has no signed head; run `server ledger rekey`", withEntries[0])
+	}
+	return heads, nil
+}
+
+// checkRefundLimits verifies that the refunds and reversals among transactions, added to those already
+// recorded, do not exceed their purchases. Refunds belong to the customer of their purchase, so once
+// appendToLedger has locked the customers no concurrent request can refund the same purchases.
+func checkRefundLimits(tx *gorm.DB, transactions []*models.Transaction) error {
+	seen := make(map[uuid.UUID]bool)
+	var originalIDs []uuid.UUID
+	for _, txn := range transactions {
+		if txn.Type.LinksOriginal() && txn.OriginalTransactionID != nil && !seen[*txn.OriginalTransactionID] {
+			seen[*txn.OriginalTransactionID] = true
+			originalIDs = append(originalIDs, *txn.OriginalTransactionID)
+		}
+	}
+	if len(originalIDs) == 0 {
+		return nil
+	}
+
+	var originals []struct {
+		ID     uuid.UUID
+		Amount models.Money
+	}
+	if err := tx.Model(&models.Transaction{}).Select("id, amount").Where("id IN ?", originalIDs).Scan(&originals).Error; err != nil {
+		return err
+	}
+	originalAmounts := make(map[uuid.UUID]models.Money, len(originals))
+	for _, original := range originals {
+		originalAmounts[original.ID] = original.Amount
+	}
+	refunded, err := refundedAmounts(tx, originalIDs)
+	if err != nil {
+		return err
+	}
+
+	for i, txn := range transactions {
+		if !txn.Type.LinksOriginal() || txn.OriginalTransactionID == nil {
+			continue
+		}
+		id := *txn.OriginalTransactionID
+		if refunded[id]+txn.Amount > originalAmounts[id] {
+			return &ExceedsOriginalError{Index: i, Refunded: refunded[id], Original: originalAmounts[id]}
+		}
+		refunded[id] += txn.Amount
+	}
+	return nil
+}
+
+// GetTransactionHistory retrieves the audit entries of a transaction and of the refunds and
+// reversals recorded against it, oldest first
+func (tr *transactionRepository) GetTransactionHistory(id uuid.UUID) ([]*models.TransactionAudit, error) {
+	related := tr.db.Model(&models.Transaction{}).Select("id").Where("original_transaction_id = ?", id)
+	var audits []*models.TransactionAudit
+	if err := tr.db.Where("transaction_id = ? OR transaction_id IN (?)", id, related).Order("id").Find(&audits).Error; err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
+
+// GetLedgerCustomerIDs retrieves the IDs of the customers that have at least one transaction or a ledger head
+func (tr *transactionRepository) GetLedgerCustomerIDs() ([]uuid.UUID, error) {
+	var customerIDs []uuid.UUID
+	err := tr.db.Raw("SELECT customer_id FROM transactions UNION SELECT customer_id FROM ledger_heads ORDER BY customer_id").
+		Scan(&customerIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return customerIDs, nil
+}
+
+// GetLedger retrieves a customer's transactions in ledger order
+func (tr *transactionRepository) GetLedger(customerID uuid.UUID) ([]*models.Transaction, error) {
+	var transactions []*models.Transaction
+	if err := tr.db.Where("customer_id = ?", customerID).Order("ledger_seq, id").Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	return transactions, nil
 }
 
-// GetTotalAmountsByCustomersInPastYear calculates the total transaction amounts for each customer in the past year
-func (tr *transactionRepository) GetTotalAmountsByCustomersInPastYear() (map[uuid.UUID]float64, error) {
+// GetLedgerHead retrieves the signed head of a customer's ledger, returning nil if there is none
+func (tr *transactionRepository) GetLedgerHead(customerID uuid.UUID) (*models.LedgerHead, error) {
+	var heads []*models.LedgerHead
+	if err := tr.db.Where("customer_id = ?", customerID).Limit(1).Find(&heads).Error; err != nil {
+		return nil, err
+	}
+	if len(heads) == 0 {
+		return nil, nil
+	}
+	return heads[0], nil
+}
+
+// GetUnsignedLedgerCustomerIDs retrieves the IDs of the customers whose transactions have no ledger
+// head, i.e. ledgers chained before the hashes were keyed
+func (tr *transactionRepository) GetUnsignedLedgerCustomerIDs() ([]uuid.UUID, error) {
+	var customerIDs []uuid.UUID
+	err := tr.db.Model(&models.Transaction{}).
+		Distinct().
+		Where("NOT EXISTS (SELECT 1 FROM ledger_heads h WHERE h.customer_id = transactions.customer_id)").
+		Order("customer_id").
+		Pluck("customer_id", &customerIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return customerIDs, nil
+}
+
+// SignLegacyLedger rechains a ledger whose hashes are not keyed under the ledger key and gives it a
+// signed head, provided its unkeyed chain is intact. Otherwise it returns the violations of the
+// unkeyed chain and leaves the ledger untouched. Ledgers that already have a head are skipped.
+func (tr *transactionRepository) SignLegacyLedger(customerID uuid.UUID) ([]models.LedgerViolation, error) {
+	var violations []models.LedgerViolation
+	err := tr.db.Transaction(func(tx *gorm.DB) error {
+		var locked []uuid.UUID
+		err := tx.Model(&models.Customer{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", customerID).
+			Pluck("id", &locked).Error
+		if err != nil {
+			return err
+		}
+		var heads int64
+		if err := tx.Model(&models.LedgerHead{}).Where("customer_id = ?", customerID).Count(&heads).Error; err != nil {
+			return err
+		}
+		var ledger []*models.Transaction
+		if err := tx.Where("customer_id = ?", customerID).Order("ledger_seq, id").Find(&ledger).Error; err != nil {
+			return err
+		}
+		if heads > 0 || len(ledger) == 0 {
+			return nil
+		}
+		if violations = models.CheckChain(customerID, ledger, nil); len(violations) > 0 {
+			return nil
+		}
+
+		prevHash := models.GenesisHash
+		for _, txn := range ledger {
+			txn.PrevHash = prevHash
+			txn.EntryHash = txn.ComputeHash(prevHash, tr.ledgerKey)
+			err := tx.Model(&models.Transaction{}).Where("id = ?", txn.ID).
+				Updates(map[string]interface{}{"prev_hash": txn.PrevHash, "entry_hash": txn.EntryHash}).Error
+			if err != nil {
+				return err
+			}
+			prevHash = txn.EntryHash
+		}
+		head := &models.LedgerHead{}
+		head.Sign(ledger[len(ledger)-1], tr.ledgerKey)
+		return tx.Create(head).Error
+	})
+	return violations, err
+}
+
+// netSignSQL is the sign a transaction t contributes to a total: refunds and reversals take money back
+var netSignSQL = fmt.Sprintf("(CASE WHEN t.type IN ('%s', '%s') THEN -1 ELSE 1 END)", models.TypeRefund, models.TypeReversal)
+
+// netAmount returns the amount of transaction t converted into currency, negated for refunds and reversals
+func netAmount(currency models.Currency) clause.Expression {
+	return clause.Expr{SQL: "(?) * " + netSignSQL, Vars: []interface{}{convertedAmount(currency)}}
+}
+
+// GetTotalAmountsByCustomersInPastYear calculates the net transaction amounts for each customer in the past year,
+// converted into currency at the rate in effect on each transaction's date
+func (tr *transactionRepository) GetTotalAmountsByCustomersInPastYear(currency models.Currency) (map[uuid.UUID]models.Money, error) {
 	var results []struct {
 		CustomerID  uuid.UUID
-		TotalAmount float64
+		TotalAmount models.Money
 	}
 
 	oneYearAgo := time.Now().AddDate(-1, 0, 0).Truncate(24 * time.Hour)
-	err := tr.db.Model(&models.Transaction{}).
-		Select("customer_id, SUM(amount) as total_amount").
-		Where("time >= ?", oneYearAgo).
-		Group("customer_id").
+	scope := tr.db.Table("transactions AS t").Where("t.time >= ?", oneYearAgo).Session(&gorm.Session{})
+	if err := findMissingRate(scope, currency); err != nil {
+		return nil, err
+	}
+	err := scope.Select("t.customer_id, SUM(?) as total_amount", netAmount(currency)).
+		Group("t.customer_id").
 		Scan(&results).Error
 	if err != nil {
 		return nil, err
 	}
 
 	// Map customer IDs to their respective total transaction amounts
-	totalAmounts := make(map[uuid.UUID]float64)
+	totalAmounts := make(map[uuid.UUID]models.Money)
 	for _, result := range results {
 		totalAmounts[result.CustomerID] = result.TotalAmount
 	}
@@ -80,17 +537,198 @@ func (tr *transactionRepository) GetTotalAmountsByCustomersInPastYear() (map[uui
 	return totalAmounts, nil
 }
 
+// GetTotalAmountsByCustomerIDs calculates the net transaction amounts within the window
+// for the given customers only, so the aggregation does not scan the whole table.
+// Amounts are converted into currency at the rate in effect on each transaction's date.
+func (tr *transactionRepository) GetTotalAmountsByCustomerIDs(customerIDs []uuid.UUID, window *models.AggregationWindow, currency models.Currency) (map[uuid.UUID]models.Money, error) {
+	totalAmounts := make(map[uuid.UUID]models.Money)
+	if len(customerIDs) == 0 {
+		return totalAmounts, nil
+	}
+
+	var results []struct {
+		CustomerID  uuid.UUID
+		TotalAmount models.Money
+	}
+
+	scope := tr.db.Table("transactions AS t").
+		Where("t.customer_id IN ? AND t.time >= ? AND t.time < ?", customerIDs, window.From, window.To).
+		Session(&gorm.Session{})
+	if err := findMissingRate(scope, currency); err != nil {
+		return nil, err
+	}
+	err := scope.Select("t.customer_id, SUM(?) as total_amount", netAmount(currency)).
+		Group("t.customer_id").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		totalAmounts[result.CustomerID] = result.TotalAmount
+	}
+
+	return totalAmounts, nil
+}
+
+// spendingPercentiles are the percentiles reported by GetSpendingStats, keyed by column name
+var spendingPercentiles = []struct {
+	Column   string
+	Fraction float64
+}{{"p50", 0.5}, {"p90", 0.9}, {"p99", 0.99}}
+
+// GetSpendingStats aggregates a customer's net transaction amounts, converted into currency, over each
+// of the given consecutive buckets. Every bucket gets a result in order, zeroed when it is empty.
+// Percentiles use the nearest-rank method over the amounts ranked within each bucket.
+func (tr *transactionRepository) GetSpendingStats(customerID uuid.UUID, buckets []models.TimeBucket, currency models.Currency) ([]*models.SpendingStats, error) {
+	if len(buckets) == 0 {
+		return []*models.SpendingStats{}, nil
+	}
+
+	scope := tr.db.Table("transactions AS t").
+		Where("t.customer_id = ? AND t.time >= ? AND t.time < ?", customerID, buckets[0].Start, buckets[len(buckets)-1].End).
+		Session(&gorm.Session{})
+	if err := findMissingRate(scope, currency); err != nil {
+		return nil, err
+	}
+
+	bucketRows := bucketTable(buckets)
+	amounts := scope.Select("t.time, ? AS amount", netAmount(currency))
+	ranked := tr.db.Table("(?) AS bb", bucketRows).
+		Select("bb.idx, v.amount, ROW_NUMBER() OVER (PARTITION BY bb.idx ORDER BY v.amount) AS rn, COUNT(*) OVER (PARTITION BY bb.idx) AS cnt").
+		Joins("JOIN (?) AS v ON v.time >= bb.start_at AND v.time < bb.end_at", amounts)
+
+	columns := []string{
+		"b.idx",
+		"COUNT(x.amount) AS txn_count",
+		"COALESCE(SUM(x.amount), 0) AS total",
+		"COALESCE(AVG(x.amount), 0) AS average",
+		"COALESCE(MIN(x.amount), 0) AS minimum",
+		"COALESCE(MAX(x.amount), 0) AS maximum",
+	}
+	for _, p := range spendingPercentiles {
+		columns = append(columns, fmt.Sprintf("COALESCE(MIN(CASE WHEN x.rn >= CEIL(%g * x.cnt) THEN x.amount END), 0) AS %s", p.Fraction, p.Column))
+	}
+
+	var results []struct {
+		Idx      int
+		TxnCount int64
+		Total    models.Money
+		Average  models.Money
+		Minimum  models.Money
+		Maximum  models.Money
+		P50      models.Money
+		P90      models.Money
+		P99      models.Money
+	}
+	err := tr.db.Table("(?) AS b", bucketRows).
+		Select(strings.Join(columns, ", ")).
+		Joins("LEFT JOIN (?) AS x ON x.idx = b.idx", ranked).
+		Group("b.idx").
+		Order("b.idx").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*models.SpendingStats, len(buckets))
+	for i, bucket := range buckets {
+		stats[i] = &models.SpendingStats{Start: bucket.Start, End: bucket.End}
+	}
+	for _, result := range results {
+		if result.Idx < 0 || result.Idx >= len(stats) {
+			continue
+		}
+		stat := stats[result.Idx]
+		stat.Count = result.TxnCount
+		stat.Sum = result.Total
+		stat.Avg = result.Average
+		stat.Min = result.Minimum
+		stat.Max = result.Maximum
+		stat.P50 = result.P50
+		stat.P90 = result.P90
+		stat.P99 = result.P99
+	}
+	return stats, nil
+}
+
+// GetCohortActivity follows the customers registering in each of the first cohorts of the given consecutive
+// months through the remaining months, in a single query. Every cohort gets a result in order with a cell
+// for each month from its registration month to its (len(months)-cohorts)-th month after it; a cell counts
+// the cohort's customers making purchases in that month and sums their net amounts, converted into currency.
+// The same query flags the followed transactions that cannot be converted, returning a MissingFXRateError.
+func (tr *transactionRepository) GetCohortActivity(months []models.TimeBucket, cohorts int, currency models.Currency) ([]*models.Cohort, error) {
+	if cohorts <= 0 || cohorts > len(months) {
+		return []*models.Cohort{}, nil
+	}
+	maxOffset := len(months) - cohorts
+
+	monthRows := bucketTable(months)
+	sizes := tr.db.Table("(?) AS cm", monthRows).
+		Select("cm.idx AS cohort, COUNT(*) AS customers").
+		Joins("JOIN customers AS c ON c.created_at >= cm.start_at AND c.created_at < cm.end_at").
+		Where("cm.idx < ?", cohorts).
+		Group("cm.idx")
+	perCustomer := tr.db.Table("(?) AS cm", monthRows).
+		Select("cm.idx AS cohort, tm.idx - cm.idx AS month_offset, "+
+			"SUM(CASE WHEN t.type = ? THEN 1 ELSE 0 END) AS purchases, SUM(?) AS revenue, "+
+			"MIN(CASE WHEN (?) IS NULL THEN CONCAT(DATE(t.time), ' ', t.currency) END) AS missing_rate",
+			models.TypePurchase, netAmount(currency), convertedAmount(currency)).
+		Joins("JOIN customers AS c ON c.created_at >= cm.start_at AND c.created_at < cm.end_at").
+		Joins("JOIN transactions AS t ON t.customer_id = c.id").
+		Joins("JOIN (?) AS tm ON t.time >= tm.start_at AND t.time < tm.end_at AND tm.idx BETWEEN cm.idx AND cm.idx + ?", monthRows, maxOffset).
+		Where("cm.idx < ?", cohorts).
+		Group("cm.idx, tm.idx, t.customer_id")
+	cells := tr.db.Table("(?) AS a", perCustomer).
+		Select("a.cohort, a.month_offset, SUM(CASE WHEN a.purchases > 0 THEN 1 ELSE 0 END) AS active_customers, " +
+			"SUM(a.revenue) AS revenue, MIN(a.missing_rate) AS missing_rate").
+		Group("a.cohort, a.month_offset")
+
+	var results []struct {
+		Cohort          int
+		Customers       int64
+		MonthOffset     *int
+		ActiveCustomers int64
+		Revenue         models.Money
+		MissingRate     *string
+	}
+	err := tr.db.Table("(?) AS s", sizes).
+		Select("s.cohort, s.customers, x.month_offset, COALESCE(x.active_customers, 0) AS active_customers, "+
+			"COALESCE(x.revenue, 0) AS revenue, x.missing_rate").
+		Joins("LEFT JOIN (?) AS x ON x.cohort = s.cohort", cells).
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range results {
+		if row.MissingRate != nil {
+			return nil, parseMissingRate(*row.MissingRate, currency)
+		}
+	}
+
+	result := make([]*models.Cohort, cohorts)
+	for i := range result {
+		result[i] = &models.Cohort{Start: months[i].Start, Months: make([]*models.CohortCell, maxOffset+1)}
+		for offset := range result[i].Months {
+			result[i].Months[offset] = &models.CohortCell{Offset: offset, Start: months[i+offset].Start}
+		}
+	}
+	for _, row := range results {
+		if row.Cohort < 0 || row.Cohort >= cohorts {
+			continue
+		}
+		cohort := result[row.Cohort]
+		cohort.Customers = row.Customers
+		if row.MonthOffset != nil && *row.MonthOffset >= 0 && *row.MonthOffset <= maxOffset {
+			cell := cohort.Months[*row.MonthOffset]
+			cell.ActiveCustomers = row.ActiveCustomers
+			cell.Revenue = row.Revenue
+		}
+	}
+	return result, nil
+}
+
 // CreateTransaction inserts a new transaction record into the database
 // func (tr *transactionRepository) CreateTransaction(transaction *models.Transaction) error {
 // 	return tr.db.Create(transaction).Error
 // }
-
-// UpdateTransaction modifies an existing transaction record in the database
-// func (tr *transactionRepository) UpdateTransaction(transaction *models.Transaction) error {
-// 	return tr.db.Save(transaction).Error
-// }
-
-// DeleteTransaction removes a transaction record by its ID
-// func (tr *transactionRepository) DeleteTransaction(id uuid.UUID) error {
-// 	return tr.db.Delete(&models.Transaction{}, "id = ?", id).Error
-// }
@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+const (
+	benchCustomers               = 5000
+	benchTransactionsPerCustomer = 20
+	benchPageSize                = 50
+)
+
+var (
+	benchOnce sync.Once
+	benchDB   *gorm.DB
+	benchIDs  []uuid.UUID
+	benchErr  error
+)
+
+// benchSchema mirrors the columns and the (customer_id, time, id) index of the migrated MariaDB schema
+// that the total amount queries touch
+var benchSchema = []string{
+	`CREATE TABLE transactions (
+		id char(36) NOT NULL PRIMARY KEY,
+		customer_id char(36) NOT NULL,
+		type varchar(16) NOT NULL DEFAULT 'purchase',
+		amount decimal(18,2) NOT NULL,
+		currency char(3) NOT NULL DEFAULT 'TWD',
+		time timestamp NULL
+	)`,
+	`CREATE INDEX idx_transactions_customer_time ON transactions (customer_id, time, id)`,
+	`CREATE TABLE fx_rates (
+		currency char(3) NOT NULL,
+		effective_date date NOT NULL,
+		rate decimal(18,8) NOT NULL,
+		PRIMARY KEY (currency, effective_date)
+	)`,
+}
+
+// seededBenchDB returns an in-memory SQLite database holding benchCustomers customers with
+// benchTransactionsPerCustomer transactions each, spread over the past two years
+func seededBenchDB(b *testing.B) (*gorm.DB, []uuid.UUID) {
+	benchOnce.Do(func() {
+		db, err := gorm.Open(sqlite.Open("file:transactions_bench?mode=memory&cache=shared"), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		})
+		if err != nil {
+			benchErr = err
+			return
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			benchErr = err
+			return
+		}
+		sqlDB.SetMaxOpenConns(1)
+		for _, statement := range benchSchema {
+			if err := db.Exec(statement).Error; err != nil {
+				benchErr = err
+				return
+			}
+		}
+
+		rng := rand.New(rand.NewSource(1))
+		now := time.Now().UTC().Truncate(time.Second)
+		ids := make([]uuid.UUID, benchCustomers)
+		benchErr = db.Transaction(func(tx *gorm.DB) error {
+			rows := make([]map[string]interface{}, 0, benchTransactionsPerCustomer)
+			for i := range ids {
+				ids[i] = uuid.New()
+				rows = rows[:0]
+				for j := 0; j < benchTransactionsPerCustomer; j++ {
+					// Whole amounts keep SQLite's SUM in integers, which scan into Money exactly
+					rows = append(rows, map[string]interface{}{
+						"id":          uuid.NewString(),
+						"customer_id": ids[i].String(),
+						"amount":      rng.Intn(100000) + 1,
+						"time":        now.Add(-time.Duration(rng.Int63n(int64(2 * 365 * 24 * time.Hour)))),
+					})
+				}
+				if err := tx.Table("transactions").Create(rows).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		benchDB, benchIDs = db, ids
+	})
+	if benchErr != nil {
+		b.Fatalf("seeding the benchmark database: %v", benchErr)
+	}
+	return benchDB, benchIDs
+}
+
+// BenchmarkTotalAmounts compares building one page of the customer list from the per-ID aggregation
+// with the full-table GROUP BY it replaced, which summed every customer's transactions of the past year
+func BenchmarkTotalAmounts(b *testing.B) {
+	db, ids := seededBenchDB(b)
+	repo := NewTransactionRepository(db, models.NewLedgerKey("benchmark-ledger-secret-0123456789"))
+	page := ids[:benchPageSize]
+	window := models.PastYearWindow(time.Now())
+
+	byIDs, err := repo.GetTotalAmountsByCustomerIDs(page, window, models.BaseCurrency)
+	if err != nil {
+		b.Fatal(err)
+	}
+	all, err := repo.GetTotalAmountsByCustomersInPastYear(models.BaseCurrency)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, id := range page {
+		if byIDs[id] != all[id] {
+			b.Fatalf("totals of %s differ: %s by ID, %s in the full aggregation", id, byIDs[id], all[id])
+		}
+	}
+
+	b.Run(fmt.Sprintf("by-ids-%d", benchPageSize), func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetTotalAmountsByCustomerIDs(page, window, models.BaseCurrency); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run(fmt.Sprintf("full-table-%d", benchCustomers), func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetTotalAmountsByCustomersInPastYear(models.BaseCurrency); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+// ErrInvalidAnalyticsQuery is wrapped by the errors returned for analytics and report requests that cannot be served
+var ErrInvalidAnalyticsQuery = errors.New("invalid analytics query")
+
+type AnalyticsService interface {
+	GetCustomerAnalytics(query *models.AnalyticsQuery) (*models.CustomerAnalytics, error)
+}
+
+type analyticsService struct {
+	transactionRepo repositories.TransactionRepository
+	customerRepo    repositories.CustomerRepository
+}
+
+// NewAnalyticsService creates a new instance of AnalyticsService
+func NewAnalyticsService(transactionRepo repositories.TransactionRepository, customerRepo repositories.CustomerRepository) AnalyticsService {
+	return &analyticsService{transactionRepo: transactionRepo, customerRepo: customerRepo}
+}
+
+// GetCustomerAnalytics computes a customer's spending statistics over the whole query range and over
+// each bucket of it. It returns gorm.ErrRecordNotFound if the customer does not exist.
+func (as *analyticsService) GetCustomerAnalytics(query *models.AnalyticsQuery) (*models.CustomerAnalytics, error) {
+	if _, err := as.customerRepo.GetCustomerByID(query.CustomerID); err != nil {
+		return nil, err
+	}
+
+	buckets, err := splitReportBuckets(&query.ReportQuery)
+	if err != nil {
+		return nil, err
+	}
+	bucketStats, err := as.transactionRepo.GetSpendingStats(query.CustomerID, buckets, query.Currency)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := as.transactionRepo.GetSpendingStats(query.CustomerID, []models.TimeBucket{{Start: query.From, End: query.To}}, query.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CustomerAnalytics{
+		CustomerID: query.CustomerID,
+		From:       query.From,
+		To:         query.To,
+		TimeZone:   query.Location.String(),
+		Bucket:     query.Bucket,
+		Currency:   query.Currency,
+		Summary:    summary[0],
+		Buckets:    bucketStats,
+	}, nil
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+var (
+	// ErrInvalidCredentials is returned when a login's email and password do not match an account
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidToken is returned for tokens that are malformed, expired, of the wrong type or revoked
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// tokenIssuer identifies the tokens signed by this server
+const tokenIssuer = "titansoft-pre-test"
+
+type AuthService interface {
+	Login(email, password string) (*models.TokenPair, error)
+	OperatorLogin(email, password string) (*models.TokenPair, error)
+	Refresh(refreshToken string) (*models.TokenPair, error)
+	Logout(sessionID uuid.UUID) error
+	Authenticate(token string) (*models.Principal, error)
+}
+
+// AuthSettings configures the tokens of AuthService
+type AuthSettings struct {
+	Secret     string // key signing access and refresh tokens
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+type authService struct {
+	customerRepo   repositories.CustomerRepository
+	operatorRepo   repositories.OperatorRepository
+	sessionRepo    repositories.AuthSessionRepository
+	passwordHasher PasswordHasher
+	settings       AuthSettings
+}
+
+// NewAuthService creates a new instance of AuthService
+func NewAuthService(customerRepo repositories.CustomerRepository, operatorRepo repositories.OperatorRepository, sessionRepo repositories.AuthSessionRepository, passwordHasher PasswordHasher, settings AuthSettings) AuthService {
+	return &authService{
+		customerRepo:   customerRepo,
+		operatorRepo:   operatorRepo,
+		sessionRepo:    sessionRepo,
+		passwordHasher: passwordHasher,
+		settings:       settings,
+	}
+}
+
+// tokenClaims are the claims of access and refresh tokens. Subject is the customer or operator ID and
+// Id the token ID; refresh tokens carry the session's current refresh ID.
+type tokenClaims struct {
+	jwt.StandardClaims
+	SessionID string      `json:"sid"`
+	Type      string      `json:"typ"`
+	Role      models.Role `json:"role"`
+}
+
+// Login verifies a customer's email and password and starts a new session
+func (as *authService) Login(email, password string) (*models.TokenPair, error) {
+	customer, err := as.customerRepo.GetCustomerByEmail(email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if customer == nil {
+		return nil, as.rejectUnknownAccount(password)
+	}
+	rehash := func(newHash string) error {
+		return as.customerRepo.ReplacePasswordHash(customer.ID, customer.Password, newHash)
+	}
+	if err := as.checkPassword(password, customer.Password, true, rehash); err != nil {
+		return nil, err
+	}
+	return as.startSession(&models.AuthSession{CustomerID: &customer.ID, Role: models.RoleCustomer})
+}
+
+// OperatorLogin verifies an operator's email and password and starts a new session with their role.
+// Disabled operators cannot log in.
+func (as *authService) OperatorLogin(email, password string) (*models.TokenPair, error) {
+	operator, err := as.operatorRepo.GetOperatorByEmail(email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if operator == nil {
+		return nil, as.rejectUnknownAccount(password)
+	}
+	rehash := func(newHash string) error {
+		return as.operatorRepo.ReplacePasswordHash(operator.ID, operator.Password, newHash)
+	}
+	if err := as.checkPassword(password, operator.Password, operator.DisabledAt == nil, rehash); err != nil {
+		return nil, err
+	}
+	return as.startSession(&models.AuthSession{OperatorID: &operator.ID, Role: operator.Role})
+}
+
+// checkPassword fails with ErrInvalidCredentials unless the account is allowed to log in and the password
+// matches its hash. Outdated hashes of allowed accounts are upgraded through rehash; failing to do so is
+// logged without failing the login, which is retried on the next one.
+func (as *authService) checkPassword(password, hash string, allowed bool, rehash func(newHash string) error) error {
+	ok, needsRehash, err := as.passwordHasher.Verify(password, hash)
+	if err != nil {
+		return err
+	}
+	if !allowed || !ok {
+		return ErrInvalidCredentials
+	}
+
+	if needsRehash {
+		newHash, err := as.passwordHasher.Hash(password)
+		if err == nil {
+			err = rehash(newHash)
+		}
+		if err != nil {
+			log.Printf("Failed to upgrade password hash: %v", err)
+		}
+	}
+	return nil
+}
+
+// rejectUnknownAccount fails a login for an email without an account with ErrInvalidCredentials. It hashes
+// the password all the same so that response times do not reveal which accounts exist.
+func (as *authService) rejectUnknownAccount(password string) error {
+	if _, err := as.passwordHasher.Hash(password); err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// startSession stores a new session for the principal and issues its first tokens
+func (as *authService) startSession(session *models.AuthSession) (*models.TokenPair, error) {
+	now := time.Now()
+	session.ID = uuid.New()
+	session.RefreshID = uuid.New()
+	session.ExpiresAt = now.Add(as.settings.RefreshTTL)
+	if err := as.sessionRepo.CreateSession(session); err != nil {
+		return nil, err
+	}
+	return as.issueTokens(session, now)
+}
+
+// Refresh exchanges a refresh token for a new token pair and rotates the session's refresh token.
+// Presenting a refresh token that was already rotated revokes the session, since it may have leaked.
+func (as *authService) Refresh(refreshToken string) (*models.TokenPair, error) {
+	claims, err := as.parseToken(refreshToken, models.TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, refreshID, err := claimIDs(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session, err := as.activeSession(sessionID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	newRefreshID := uuid.New()
+	expiresAt := now.Add(as.settings.RefreshTTL)
+	rotated, err := as.sessionRepo.RotateRefreshID(session.ID, refreshID, newRefreshID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		if err := as.sessionRepo.RevokeSession(session.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidToken
+	}
+	session.RefreshID = newRefreshID
+	session.ExpiresAt = expiresAt
+	return as.issueTokens(session, now)
+}
+
+// Logout revokes the session, invalidating its access and refresh tokens
+func (as *authService) Logout(sessionID uuid.UUID) error {
+	return as.sessionRepo.RevokeSession(sessionID)
+}
+
+// Authenticate identifies the caller presenting a valid access token whose session is still active
+func (as *authService) Authenticate(token string) (*models.Principal, error) {
+	claims, err := as.parseToken(token, models.TokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	session, err := as.activeSession(sessionID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if session.PrincipalID().String() != claims.Subject {
+		return nil, ErrInvalidToken
+	}
+	return &models.Principal{ID: session.PrincipalID(), Role: session.Role, SessionID: session.ID}, nil
+}
+
+// activeSession retrieves the session, failing with ErrInvalidToken unless it is active at now
+func (as *authService) activeSession(id uuid.UUID, now time.Time) (*models.AuthSession, error) {
+	session, err := as.sessionRepo.GetSession(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !session.IsActive(now) {
+		return nil, ErrInvalidToken
+	}
+	return session, nil
+}
+
+// issueTokens signs a new access token and a refresh token carrying the session's current refresh ID
+func (as *authService) issueTokens(session *models.AuthSession, now time.Time) (*models.TokenPair, error) {
+	access, err := as.signToken(session, uuid.New(), models.TokenTypeAccess, now, now.Add(as.settings.AccessTTL))
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := as.signToken(session, session.RefreshID, models.TokenTypeRefresh, now, session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(as.settings.AccessTTL / time.Second),
+	}, nil
+}
+
+// signToken signs a token of the given type for the session's principal with HMAC-SHA256
+func (as *authService) signToken(session *models.AuthSession, id uuid.UUID, tokenType string, issuedAt, expiresAt time.Time) (string, error) {
+	claims := tokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        id.String(),
+			Subject:   session.PrincipalID().String(),
+			Issuer:    tokenIssuer,
+			IssuedAt:  issuedAt.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+		SessionID: session.ID.String(),
+		Type:      tokenType,
+		Role:      session.Role,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(as.settings.Secret))
+}
+
+// parseToken verifies the token's signature, expiry, issuer and type
+func (as *authService) parseToken(token, tokenType string) (*tokenClaims, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return []byte(as.settings.Secret), nil
+	})
+	if err != nil || claims.Issuer != tokenIssuer || claims.Type != tokenType {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// claimIDs parses the session ID and token ID of a refresh token
+func claimIDs(claims *tokenClaims) (uuid.UUID, uuid.UUID, error) {
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, ErrInvalidToken
+	}
+	tokenID, err := uuid.Parse(claims.Id)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, ErrInvalidToken
+	}
+	return sessionID, tokenID, nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+type CohortService interface {
+	GetRetentionReport(query *models.CohortQuery) (*models.CohortRetentionReport, error)
+}
+
+type cohortService struct {
+	transactionRepo repositories.TransactionRepository
+}
+
+// NewCohortService creates a new instance of CohortService
+func NewCohortService(transactionRepo repositories.TransactionRepository) CohortService {
+	return &cohortService{transactionRepo: transactionRepo}
+}
+
+// GetRetentionReport follows each monthly registration cohort of the query range through the query's number
+// of months. The range defaults to the months from the first registration to the current month, limited
+// to the latest MaxCohorts of them: the latest MaxCohorts months are queried and the empty cohorts before
+// the first registration are dropped, so the report still takes a single query.
+func (cs *cohortService) GetRetentionReport(query *models.CohortQuery) (*models.CohortRetentionReport, error) {
+	now := time.Now()
+	from, to := query.From, query.To
+	if to.IsZero() {
+		to = models.MonthStart(now, query.Location).AddDate(0, 1, 0)
+	}
+	trimEmpty := from.IsZero()
+	if trimEmpty {
+		from = to.AddDate(0, -models.MaxCohorts, 0)
+	}
+
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must not be after to", ErrInvalidAnalyticsQuery)
+	}
+
+	months, err := models.SplitBuckets(from, to.AddDate(0, query.Months, 0), models.BucketMonth, query.Location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAnalyticsQuery, err)
+	}
+	cohortCount := len(months) - query.Months
+	if cohortCount > models.MaxCohorts {
+		return nil, fmt.Errorf("%w: range must not span more than %d cohorts", ErrInvalidAnalyticsQuery, models.MaxCohorts)
+	}
+
+	cohorts, err := cs.transactionRepo.GetCohortActivity(months, cohortCount, query.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if trimEmpty {
+		first := len(cohorts) - 1
+		for i, cohort := range cohorts {
+			if cohort.Customers > 0 {
+				first = i
+				break
+			}
+		}
+		cohorts = cohorts[first:]
+		from = cohorts[0].Start
+	}
+	for _, cohort := range cohorts {
+		cohort.Month = cohort.Start.In(query.Location).Format("2006-01")
+		begun := 0
+		for _, cell := range cohort.Months {
+			if cell.Start.After(now) {
+				break
+			}
+			if cohort.Customers > 0 {
+				cell.Retention = math.Round(float64(cell.ActiveCustomers)/float64(cohort.Customers)*10000) / 10000
+			}
+			begun++
+		}
+		cohort.Months = cohort.Months[:begun]
+	}
+
+	return &models.CohortRetentionReport{
+		From:     from,
+		To:       to,
+		TimeZone: query.Location.String(),
+		Months:   query.Months,
+		Currency: query.Currency,
+		Cohorts:  cohorts,
+	}, nil
+}
+
+// WriteCohortRetentionCSV writes the report as CSV with one row per cohort and month.
+// Months are formatted in the report's time zone, in which the cells start.
+func WriteCohortRetentionCSV(w io.Writer, report *models.CohortRetentionReport) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"cohort", "customers", "offset", "month", "active_customers", "retention", "revenue", "currency"}); err != nil {
+		return err
+	}
+	for _, cohort := range report.Cohorts {
+		for _, cell := range cohort.Months {
+			record := []string{
+				cohort.Month,
+				strconv.FormatInt(cohort.Customers, 10),
+				strconv.Itoa(cell.Offset),
+				cell.Start.Format("2006-01"),
+				strconv.FormatInt(cell.ActiveCustomers, 10),
+				strconv.FormatFloat(cell.Retention, 'f', 4, 64),
+				cell.Revenue.String(),
+				string(report.Currency),
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -1,13 +1,12 @@
 package services
 
 import (
-	"encoding/base64"
 	"fmt"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/scrypt"
 
 	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
 	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
@@ -16,11 +15,11 @@ import (
 type CustomerService interface {
 	GetAllCustomers() ([]*models.CustomerDTO, error)
 	GetLimitedCustomers(num int) ([]*models.CustomerDTO, error)
+	QueryCustomers(query *models.CustomerQuery) (*models.CustomerPage, error)
 	CreateCustomer(customer *models.Customer) error
-	CreateMultiCustomers(customers []*models.Customer) (int, int, error)
-	GetCustomerByID(id uuid.UUID) (*models.Customer, error)
+	CreateMultiCustomers(customers []*models.Customer) (*models.CustomerBatchResult, error)
+	GetCustomerByID(id uuid.UUID, window *models.AggregationWindow, currency models.Currency) (*models.CustomerDTO, error)
 	UpdateCustomer(customer *models.Customer) error
-	UpdateCustomerPassword(customer *models.Customer) error
 	ResetAllCustomerData() error
 	// DeleteCustomer(id uuid.UUID) error
 }
@@ -28,15 +27,15 @@ type CustomerService interface {
 type customerService struct {
 	customerRepo    repositories.CustomerRepository
 	transactionRepo repositories.TransactionRepository
-	salt            string
+	passwordHasher  PasswordHasher
 }
 
 // NewCustomerService creates a new instance of CustomerService with required dependencies.
-func NewCustomerService(repo repositories.CustomerRepository, transactionRepo repositories.TransactionRepository, salt string) CustomerService {
+func NewCustomerService(repo repositories.CustomerRepository, transactionRepo repositories.TransactionRepository, passwordHasher PasswordHasher) CustomerService {
 	return &customerService{
 		customerRepo:    repo,
 		transactionRepo: transactionRepo,
-		salt:            salt,
+		passwordHasher:  passwordHasher,
 	}
 }
 
@@ -47,7 +46,7 @@ func (cs *customerService) GetAllCustomers() ([]*models.CustomerDTO, error) {
 		return nil, err
 	}
 
-	customerDTOs, err := cs.buildCustomerDTOsWithTransactions(customers)
+	customerDTOs, err := cs.buildCustomerDTOsWithTransactions(customers, models.PastYearWindow(time.Now()), models.BaseCurrency)
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +63,7 @@ func (cs *customerService) GetLimitedCustomers(num int) ([]*models.CustomerDTO,
 	}
 
 	// Build and return customer DTOs enriched with transaction data
-	customerDTOs, err := cs.buildCustomerDTOsWithTransactions(customers)
+	customerDTOs, err := cs.buildCustomerDTOsWithTransactions(customers, models.PastYearWindow(time.Now()), models.BaseCurrency)
 	if err != nil {
 		return nil, err
 	}
@@ -72,10 +71,67 @@ func (cs *customerService) GetLimitedCustomers(num int) ([]*models.CustomerDTO,
 	return customerDTOs, nil
 }
 
-// buildCustomerDTOsWithTransactions constructs CustomerDTOs with total transaction amounts from the past year.
-func (cs *customerService) buildCustomerDTOsWithTransactions(customers []*models.Customer) ([]*models.CustomerDTO, error) {
-	// Retrieve total transaction amounts for each customer from the past year
-	totalAmounts, err := cs.transactionRepo.GetTotalAmountsByCustomersInPastYear()
+// QueryCustomers retrieves one page of customers matching the query and the cursor for the next page.
+func (cs *customerService) QueryCustomers(query *models.CustomerQuery) (*models.CustomerPage, error) {
+	// Fetch one extra row to find out whether another page follows
+	pageQuery := *query
+	pageQuery.PageSize = query.PageSize + 1
+	customers, totalCount, err := cs.customerRepo.QueryCustomers(&pageQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, customer := range customers {
+		customer.Currency = query.Currency
+	}
+	page := &models.CustomerPage{
+		Items:      customers,
+		TotalCount: totalCount,
+		Window:     query.Window,
+		Currency:   query.Currency,
+	}
+	if len(customers) > query.PageSize {
+		page.Items = customers[:query.PageSize]
+		last := page.Items[len(page.Items)-1]
+		cursor := &models.CustomerCursor{
+			SortBy:   query.SortBy,
+			Order:    query.Order,
+			Value:    customerSortValue(last, query.SortBy),
+			ID:       last.ID,
+			Currency: query.Currency,
+		}
+		page.NextCursor = cursor.Encode()
+	}
+	if page.Items == nil {
+		page.Items = []*models.CustomerDTO{}
+	}
+	return page, nil
+}
+
+// customerSortValue returns the value of the sort key for a customer, as stored in a cursor.
+func customerSortValue(customer *models.CustomerDTO, sortBy models.CustomerSortKey) string {
+	switch sortBy {
+	case models.SortByEmail:
+		return customer.Email
+	case models.SortByGender:
+		return string(customer.Gender)
+	case models.SortByTotalTransactionAmount:
+		return customer.TotalTransactionAmount.String()
+	default:
+		return customer.Name
+	}
+}
+
+// buildCustomerDTOsWithTransactions constructs CustomerDTOs with total transaction amounts within the window,
+// converted into currency.
+func (cs *customerService) buildCustomerDTOsWithTransactions(customers []*models.Customer, window *models.AggregationWindow, currency models.Currency) ([]*models.CustomerDTO, error) {
+	customerIDs := make([]uuid.UUID, len(customers))
+	for i, customer := range customers {
+		customerIDs[i] = customer.ID
+	}
+
+	// Retrieve transaction totals within the window for these customers only
+	totalAmounts, err := cs.transactionRepo.GetTotalAmountsByCustomerIDs(customerIDs, window, currency)
 	if err != nil {
 		return nil, err
 	}
@@ -90,15 +146,20 @@ func (cs *customerService) buildCustomerDTOsWithTransactions(customers []*models
 			Email:                  customer.Email,
 			Gender:                 customer.Gender,
 			TotalTransactionAmount: totalAmount,
+			Currency:               currency,
+			CreatedAt:              customer.CreatedAt,
 		}
 		customerDTOs = append(customerDTOs, customerDTO)
 	}
 	return customerDTOs, nil
 }
 
-// CreateCustomer hashes the customer's password and saves the customer to the repository.
+// CreateCustomer checks the strength of the customer's password, hashes it and saves the customer to the repository.
 func (cs *customerService) CreateCustomer(customer *models.Customer) error {
-	hashedPassword, err := cs.hashPassword(customer.Password)
+	if err := validatePassword(customer.Password, customer.Email, customer.Name); err != nil {
+		return err
+	}
+	hashedPassword, err := cs.passwordHasher.Hash(customer.Password)
 	if err != nil {
 		return err
 	}
@@ -106,15 +167,17 @@ func (cs *customerService) CreateCustomer(customer *models.Customer) error {
 	return cs.customerRepo.CreateCustomer(customer)
 }
 
-// CreateMultiCustomers hashes passwords for multiple customers and saves them in batch.
-// Returns the count of successful and failed creations.
-func (cs *customerService) CreateMultiCustomers(customers []*models.Customer) (int, int, error) {
-	successCount := 0
-	failCount := 0
+// CreateMultiCustomers validates and hashes passwords for multiple customers and saves them in batch.
+// Returns a result per input customer with its assigned ID or the reason it was rejected.
+func (cs *customerService) CreateMultiCustomers(customers []*models.Customer) (*models.CustomerBatchResult, error) {
+	batchResult := &models.CustomerBatchResult{Results: make([]models.CustomerItemResult, len(customers))}
 	validCustomers := make([]*models.Customer, 0, len(customers))
+	validIndexes := make(map[uuid.UUID]int, len(customers))
 
 	type result struct {
+		index    int
 		customer *models.Customer
+		reason   models.RejectReason
 		err      error
 	}
 
@@ -125,29 +188,44 @@ func (cs *customerService) CreateMultiCustomers(customers []*models.Customer) (i
 	maxGoroutines := runtime.GOMAXPROCS(2)
 	sem := make(chan struct{}, maxGoroutines)
 
-	for _, customer := range customers {
+	for i, customer := range customers {
 		wg.Add(1)
 		sem <- struct{}{} // Acquire semaphore slot for goroutine
-		go func(c *models.Customer) {
+		go func(i int, c *models.Customer) {
 			defer wg.Done()
 			defer func() { <-sem }() // Release semaphore slot
 
 			// Validate password length
 			if len(c.Password) < 8 {
-				results <- result{nil, fmt.Errorf("password too short for customer %v", c.Email)}
+				results <- result{i, c, models.ReasonPasswordTooShort, fmt.Errorf("password must be at least 8 characters")}
 				return
 			}
+			if err := validatePassword(c.Password, c.Email, c.Name); err != nil {
+				results <- result{i, c, models.ReasonWeakPassword, err}
+				return
+			}
+
+			// Validate gender before it reaches the enum column and fails the whole batch
+			if !c.Gender.IsValid() {
+				results <- result{i, c, models.ReasonInvalidGender, fmt.Errorf("invalid gender: %s", c.Gender)}
+				return
+			}
+
+			// Registration times supplied by the caller may not lie in the future
+			if c.CreatedAt.After(time.Now()) {
+				c.CreatedAt = time.Time{}
+			}
 
 			// Generate UUID and hash password
 			c.ID = uuid.New()
-			hashedPassword, err := cs.hashPassword(c.Password)
+			hashedPassword, err := cs.passwordHasher.Hash(c.Password)
 			if err != nil {
-				results <- result{nil, fmt.Errorf("failed to hash password for customer %v: %w", c.Email, err)}
+				results <- result{i, c, models.ReasonHashFailed, fmt.Errorf("failed to hash password: %w", err)}
 				return
 			}
 			c.Password = hashedPassword
-			results <- result{c, nil}
-		}(customer)
+			results <- result{i, c, "", nil}
+		}(i, customer)
 	}
 
 	// Close results channel once all goroutines complete
@@ -158,32 +236,71 @@ func (cs *customerService) CreateMultiCustomers(customers []*models.Customer) (i
 
 	// Collect results from goroutines
 	for res := range results {
+		item := models.CustomerItemResult{Index: res.index, Email: res.customer.Email}
 		if res.err != nil {
-			failCount++
+			item.Status = models.CustomerItemRejected
+			item.Reason = res.reason
+			item.Message = res.err.Error()
 		} else {
 			validCustomers = append(validCustomers, res.customer)
-			successCount++
+			validIndexes[res.customer.ID] = res.index
 		}
+		batchResult.Results[res.index] = item
 	}
 
-	if len(validCustomers) == 0 {
-		return successCount, failCount, nil
-	}
+	if len(validCustomers) > 0 {
+		// Batch insert valid customers into the database
+		if _, err := cs.customerRepo.CreateMultiCustomers(validCustomers); err != nil {
+			return nil, fmt.Errorf("batch insert error: %w", err)
+		}
 
-	// Batch insert valid customers into the database
-	rowsAffected, err := cs.customerRepo.CreateMultiCustomers(validCustomers)
-	if err != nil {
-		// Assuming rowsAffected is accurate, adjust successCount and failCount accordingly
-		failCount += len(validCustomers) - int(rowsAffected)
-		return int(rowsAffected), failCount, fmt.Errorf("batch insert error: %w", err)
+		// Rows skipped by the email conflict clause are the ones whose fresh ID is missing
+		ids := make([]uuid.UUID, 0, len(validCustomers))
+		for _, c := range validCustomers {
+			ids = append(ids, c.ID)
+		}
+		existing, err := cs.customerRepo.GetExistingIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range validCustomers {
+			item := &batchResult.Results[validIndexes[c.ID]]
+			if existing[c.ID] {
+				id := c.ID
+				item.ID = &id
+				item.Status = models.CustomerItemCreated
+			} else {
+				item.Status = models.CustomerItemRejected
+				item.Reason = models.ReasonDuplicateEmail
+				item.Message = "email is already registered"
+			}
+		}
 	}
 
-	return int(rowsAffected), failCount, nil
+	for _, item := range batchResult.Results {
+		if item.Status == models.CustomerItemCreated {
+			batchResult.SuccessCount++
+		} else {
+			batchResult.FailCount++
+		}
+	}
+	return batchResult, nil
 }
 
-// GetCustomerByID retrieves a customer by their unique ID.
-func (cs *customerService) GetCustomerByID(id uuid.UUID) (*models.Customer, error) {
-	return cs.customerRepo.GetCustomerByID(id)
+// GetCustomerByID retrieves a customer by their unique ID along with their transaction total within the window,
+// converted into currency.
+func (cs *customerService) GetCustomerByID(id uuid.UUID, window *models.AggregationWindow, currency models.Currency) (*models.CustomerDTO, error) {
+	customer, err := cs.customerRepo.GetCustomerByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	customerDTOs, err := cs.buildCustomerDTOsWithTransactions([]*models.Customer{customer}, window, currency)
+	if err != nil {
+		return nil, err
+	}
+	customerDTOs[0].Window = window
+	return customerDTOs[0], nil
 }
 
 // UpdateCustomer updates the customer's information in the repository.
@@ -191,18 +308,6 @@ func (cs *customerService) UpdateCustomer(customer *models.Customer) error {
 	return cs.customerRepo.UpdateCustomer(customer)
 }
 
-// UpdateCustomerPassword hashes the new password (if provided) and updates it in the repository.
-func (cs *customerService) UpdateCustomerPassword(customer *models.Customer) error {
-	if customer.Password != "" {
-		hashedPassword, err := cs.hashPassword(customer.Password)
-		if err != nil {
-			return err
-		}
-		customer.Password = hashedPassword
-	}
-	return cs.customerRepo.UpdatePassword(customer)
-}
-
 // ResetAllCustomerData clears all customer data in the repository.
 func (cs *customerService) ResetAllCustomerData() error {
 	return cs.customerRepo.ResetAllCustomerData()
@@ -212,13 +317,3 @@ func (cs *customerService) ResetAllCustomerData() error {
 // func (cs *customerService) DeleteCustomer(id uuid.UUID) error {
 // 	return cs.customerRepo.DeleteCustomer(id)
 // }
-
-// hashPassword hashes a password using the scrypt algorithm and encodes it in base64.
-func (cs *customerService) hashPassword(password string) (string, error) {
-	salt := []byte(cs.salt)
-	dk, err := scrypt.Key([]byte(password), salt, 1024, 8, 1, 32) // N=1024 for demonstration, higher values recommended
-	if err != nil {
-		return "", err
-	}
-	return base64.StdEncoding.EncodeToString(dk), nil
-}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+// ErrInvalidFXRate is wrapped by the errors returned for rates that fail validation
+var ErrInvalidFXRate = errors.New("invalid FX rate")
+
+type FXRateService interface {
+	GetRates(currency models.Currency) ([]*models.FXRateDTO, error)
+	UpsertRates(rates []*models.FXRateDTO) (int, error)
+	LoadRatesCSV(r io.Reader) (int, error)
+}
+
+type fxRateService struct {
+	repo repositories.FXRateRepository
+}
+
+// NewFXRateService creates a new instance of FXRateService
+func NewFXRateService(repo repositories.FXRateRepository) FXRateService {
+	return &fxRateService{repo: repo}
+}
+
+// GetRates retrieves the rates of a currency, or of every currency when it is empty
+func (fs *fxRateService) GetRates(currency models.Currency) ([]*models.FXRateDTO, error) {
+	rates, err := fs.repo.GetRates(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	rateDTOs := make([]*models.FXRateDTO, len(rates))
+	for i, rate := range rates {
+		rateDTOs[i] = rate.ToDTO()
+	}
+	return rateDTOs, nil
+}
+
+// UpsertRates validates the rates and stores them, replacing rates of the same currency and date.
+// Nothing is stored if any rate is invalid. Returns the number of rates stored.
+func (fs *fxRateService) UpsertRates(rateDTOs []*models.FXRateDTO) (int, error) {
+	rates := make([]*models.FXRate, len(rateDTOs))
+	for i, dto := range rateDTOs {
+		rate, err := dto.ToModel()
+		if err != nil {
+			return 0, fmt.Errorf("%w: item %d: %v", ErrInvalidFXRate, i, err)
+		}
+		rates[i] = rate
+	}
+	return fs.store(rates)
+}
+
+// LoadRatesCSV reads rates from CSV with a currency, effective_date, rate header and stores them
+// like UpsertRates
+func (fs *fxRateService) LoadRatesCSV(r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid CSV header: %v", ErrInvalidFXRate, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"currency", "effective_date", "rate"} {
+		if _, ok := columns[name]; !ok {
+			return 0, fmt.Errorf("%w: CSV header is missing the %s column", ErrInvalidFXRate, name)
+		}
+	}
+
+	var rates []*models.FXRate
+	for {
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrInvalidFXRate, err)
+		}
+		dto := &models.FXRateDTO{
+			Currency:      models.Currency(values[columns["currency"]]),
+			EffectiveDate: values[columns["effective_date"]],
+			Rate:          values[columns["rate"]],
+		}
+		rate, err := dto.ToModel()
+		if err != nil {
+			return 0, fmt.Errorf("%w: line %d: %v", ErrInvalidFXRate, line, err)
+		}
+		rates = append(rates, rate)
+	}
+	return fs.store(rates)
+}
+
+// store saves validated rates
+func (fs *fxRateService) store(rates []*models.FXRate) (int, error) {
+	if len(rates) == 0 {
+		return 0, nil
+	}
+	if err := fs.repo.UpsertRates(rates); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+// idempotencyPurgeInterval is how often expired idempotency records are deleted
+const idempotencyPurgeInterval = time.Hour
+
+// IdempotencyService defines the maintenance of stored idempotency records
+type IdempotencyService interface {
+	PurgeExpired() (int64, error)
+	RunPurge(ctx context.Context)
+}
+
+type idempotencyService struct {
+	repo repositories.IdempotencyRepository
+	ttl  time.Duration
+}
+
+// NewIdempotencyService creates a new instance of IdempotencyService that keeps records for ttl
+func NewIdempotencyService(repo repositories.IdempotencyRepository, ttl time.Duration) IdempotencyService {
+	return &idempotencyService{repo: repo, ttl: ttl}
+}
+
+// PurgeExpired deletes the records older than the TTL and returns how many were deleted
+func (is *idempotencyService) PurgeExpired() (int64, error) {
+	return is.repo.PurgeRecords(time.Now().Add(-is.ttl))
+}
+
+// RunPurge purges expired records every idempotencyPurgeInterval until ctx is done. Running it on
+// every replica is safe, as deleting the same rows twice is harmless.
+func (is *idempotencyService) RunPurge(ctx context.Context) {
+	ticker := time.NewTicker(idempotencyPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := is.PurgeExpired()
+			if err != nil {
+				log.Printf("Purging idempotency records failed: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d expired idempotency records", purged)
+			}
+		}
+	}
+}
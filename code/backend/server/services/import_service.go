@@ -0,0 +1,486 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+const (
+	customerImportBatchSize    = 1000
+	transactionImportBatchSize = 5000
+	maxStoredImportErrors      = 100
+	maxImportLineLength        = 1024 * 1024
+	// Running jobs record a heartbeat every importHeartbeatInterval; active jobs without one for
+	// importStaleAfter were left behind by a replica that stopped and are marked failed
+	importHeartbeatInterval = time.Minute
+	importStaleAfter        = 5 * time.Minute
+)
+
+// ErrUploadTooLarge is returned for uploads exceeding the configured maximum size
+var ErrUploadTooLarge = errors.New("upload exceeds the maximum size")
+
+type ImportService interface {
+	StartImport(kind models.ImportKind, format models.ImportFormat, body io.Reader, startedBy string) (*models.ImportJob, error)
+	GetJobByID(id uuid.UUID) (*models.ImportJob, error)
+	CancelJob(id uuid.UUID) (bool, error)
+	FailOrphanedJobs() (int64, error)
+	RunOrphanSweep(ctx context.Context)
+}
+
+type importService struct {
+	jobRepo            repositories.ImportJobRepository
+	customerService    CustomerService
+	transactionService TransactionService
+	maxUploadSize      int64
+
+	// cancels holds the cancel functions of jobs running in this process
+	mu      sync.Mutex
+	cancels map[uuid.UUID]context.CancelFunc
+}
+
+// NewImportService creates a new instance of ImportService with required dependencies.
+// Uploads larger than maxUploadSize bytes are refused.
+func NewImportService(jobRepo repositories.ImportJobRepository, customerService CustomerService, transactionService TransactionService, maxUploadSize int64) ImportService {
+	return &importService{
+		jobRepo:            jobRepo,
+		customerService:    customerService,
+		transactionService: transactionService,
+		maxUploadSize:      maxUploadSize,
+		cancels:            make(map[uuid.UUID]context.CancelFunc),
+	}
+}
+
+// StartImport spools the upload to a temporary file, records a queued job and processes it in the background.
+// startedBy is the audit actor of the uploader, to whom imported ledger entries are attributed.
+func (is *importService) StartImport(kind models.ImportKind, format models.ImportFormat, body io.Reader, startedBy string) (*models.ImportJob, error) {
+	// The upload must be fully received before the request returns, so buffer it on disk
+	file, err := os.CreateTemp("", "import-*")
+	if err != nil {
+		return nil, err
+	}
+	// Read one byte past the limit to tell an upload of exactly the maximum size from a larger one
+	size, err := io.Copy(file, io.LimitReader(body, is.maxUploadSize+1))
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to receive upload: %w", err)
+	}
+	if size > is.maxUploadSize {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("%w of %d bytes", ErrUploadTooLarge, is.maxUploadSize)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	job := &models.ImportJob{
+		ID:        uuid.New(),
+		Kind:      kind,
+		Format:    format,
+		Status:    models.JobQueued,
+		StartedBy: startedBy,
+		Errors:    []models.ImportError{},
+	}
+	if err := is.jobRepo.CreateJob(job); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	is.mu.Lock()
+	is.cancels[job.ID] = cancel
+	is.mu.Unlock()
+
+	worker := *job
+	go is.runJob(ctx, &worker, file)
+	return job, nil
+}
+
+// GetJobByID retrieves an import job by its unique ID.
+func (is *importService) GetJobByID(id uuid.UUID) (*models.ImportJob, error) {
+	return is.jobRepo.GetJobByID(id)
+}
+
+// CancelJob marks a job as cancelled. A job running in this process stops immediately;
+// one running in another replica stops after its current batch.
+// Returns false if the job had already finished.
+func (is *importService) CancelJob(id uuid.UUID) (bool, error) {
+	cancelled, err := is.jobRepo.CancelJob(id)
+	if err != nil || !cancelled {
+		return cancelled, err
+	}
+
+	is.mu.Lock()
+	if cancel, ok := is.cancels[id]; ok {
+		cancel()
+	}
+	is.mu.Unlock()
+	return true, nil
+}
+
+// runJob processes the spooled upload batch by batch and records the outcome.
+func (is *importService) runJob(ctx context.Context, job *models.ImportJob, file *os.File) {
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+		is.mu.Lock()
+		if cancel, ok := is.cancels[job.ID]; ok {
+			cancel()
+			delete(is.cancels, job.ID)
+		}
+		is.mu.Unlock()
+	}()
+
+	go is.heartbeat(ctx, job.ID)
+
+	job.Status = models.JobRunning
+	err := is.importRecords(ctx, job, newRecordReader(job.Format, file))
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.Status = models.JobCancelled
+	case err != nil:
+		job.Status = models.JobFailed
+		job.Message = err.Error()
+	default:
+		job.Status = models.JobCompleted
+	}
+	if err := is.jobRepo.FinishJob(job); err != nil {
+		log.Printf("Failed to finish import job %s: %v", job.ID, err)
+	}
+}
+
+// heartbeat keeps a job from being taken for orphaned while it runs, until ctx is done
+func (is *importService) heartbeat(ctx context.Context, id uuid.UUID) {
+	ticker := time.NewTicker(importHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := is.jobRepo.TouchJob(id); err != nil {
+				log.Printf("Failed to record heartbeat of import job %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// FailOrphanedJobs marks the queued and running jobs that no replica processes anymore as failed, as
+// their uploads were spooled on a replica that stopped. Returns how many jobs were marked.
+func (is *importService) FailOrphanedJobs() (int64, error) {
+	return is.jobRepo.FailStaleJobs(time.Now().Add(-importStaleAfter), "import was interrupted by a server restart; upload the file again")
+}
+
+// RunOrphanSweep fails orphaned jobs every importStaleAfter until ctx is done
+func (is *importService) RunOrphanSweep(ctx context.Context) {
+	ticker := time.NewTicker(importStaleAfter)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			failed, err := is.FailOrphanedJobs()
+			if err != nil {
+				log.Printf("Failing orphaned import jobs failed: %v", err)
+			} else if failed > 0 {
+				log.Printf("Marked %d orphaned import jobs as failed", failed)
+			}
+		}
+	}
+}
+
+// importRecords decodes every record and stores them in batches through the services.
+func (is *importService) importRecords(ctx context.Context, job *models.ImportJob, reader recordReader) error {
+	var customers []*models.Customer
+	var transactions []*models.TransactionDTO
+	var lines []int
+
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+		var err error
+		if job.Kind == models.ImportCustomers {
+			err = is.importCustomers(job, customers, lines)
+		} else {
+			err = is.importTransactions(job, transactions, lines)
+		}
+		if err != nil {
+			return err
+		}
+		customers, transactions, lines = customers[:0], transactions[:0], lines[:0]
+
+		active, err := is.jobRepo.UpdateProgress(job)
+		if err != nil {
+			return err
+		}
+		if !active {
+			return context.Canceled
+		}
+		return ctx.Err()
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *rowError
+		if errors.As(err, &rowErr) {
+			job.Processed++
+			recordImportError(job, line, models.ReasonInvalidRow, rowErr.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		job.Processed++
+		if job.Kind == models.ImportCustomers {
+			customer, err := decodeCustomerRecord(record)
+			if err != nil {
+				recordImportError(job, line, models.ReasonInvalidRow, err.Error())
+				continue
+			}
+			customers = append(customers, customer)
+		} else {
+			transaction, err := decodeTransactionRecord(record)
+			if err != nil {
+				recordImportError(job, line, models.ReasonInvalidRow, err.Error())
+				continue
+			}
+			transactions = append(transactions, transaction)
+		}
+		lines = append(lines, line)
+
+		batchSize := transactionImportBatchSize
+		if job.Kind == models.ImportCustomers {
+			batchSize = customerImportBatchSize
+		}
+		if len(lines) >= batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// importCustomers stores a batch of customers and records the rejected ones.
+func (is *importService) importCustomers(job *models.ImportJob, customers []*models.Customer, lines []int) error {
+	result, err := is.customerService.CreateMultiCustomers(customers)
+	if err != nil {
+		return err
+	}
+	job.Accepted += result.SuccessCount
+	for _, item := range result.Results {
+		if item.Status == models.CustomerItemRejected {
+			recordImportError(job, lines[item.Index], item.Reason, item.Message)
+		}
+	}
+	return nil
+}
+
+// importTransactions stores a batch of transactions in best-effort mode and records the rejected ones.
+func (is *importService) importTransactions(job *models.ImportJob, transactions []*models.TransactionDTO, lines []int) error {
+	result, err := is.transactionService.CreateMultiTransactions(transactions, models.BestEffort, job.StartedBy+" via import:"+job.ID.String())
+	if err != nil {
+		return err
+	}
+	job.Accepted += result.Accepted
+	for _, rejection := range result.Rejections {
+		recordImportError(job, lines[rejection.Index], rejection.Reason, rejection.Message)
+	}
+	return nil
+}
+
+// recordImportError counts a rejected row and keeps its details, up to a limit.
+func recordImportError(job *models.ImportJob, line int, reason models.RejectReason, message string) {
+	job.Rejected++
+	if len(job.Errors) < maxStoredImportErrors {
+		job.Errors = append(job.Errors, models.ImportError{Line: line, Reason: reason, Message: message})
+	}
+}
+
+// importRecord is one row of an upload: a JSON object for NDJSON, or named fields for CSV.
+type importRecord struct {
+	raw    []byte
+	fields map[string]string
+}
+
+// rowError reports a row that could not be read but does not prevent reading the following rows.
+type rowError struct {
+	msg string
+}
+
+func (e *rowError) Error() string { return e.msg }
+
+// recordReader yields the records of an upload along with their line numbers, then io.EOF.
+type recordReader interface {
+	Next() (int, importRecord, error)
+}
+
+// newRecordReader returns a reader for the given upload format
+func newRecordReader(format models.ImportFormat, r io.Reader) recordReader {
+	if format == models.FormatCSV {
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		return &csvRecordReader{reader: reader}
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLineLength)
+	return &ndjsonRecordReader{scanner: scanner}
+}
+
+type ndjsonRecordReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (nr *ndjsonRecordReader) Next() (int, importRecord, error) {
+	for nr.scanner.Scan() {
+		nr.line++
+		raw := bytes.TrimSpace(nr.scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		return nr.line, importRecord{raw: append([]byte(nil), raw...)}, nil
+	}
+	if err := nr.scanner.Err(); err != nil {
+		return nr.line + 1, importRecord{}, err
+	}
+	return nr.line, importRecord{}, io.EOF
+}
+
+type csvRecordReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func (cr *csvRecordReader) Next() (int, importRecord, error) {
+	if cr.header == nil {
+		header, err := cr.reader.Read()
+		if err == io.EOF {
+			return 0, importRecord{}, io.EOF
+		}
+		if err != nil {
+			return 1, importRecord{}, fmt.Errorf("invalid CSV header: %w", err)
+		}
+		for i := range header {
+			header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+		}
+		cr.header = header
+	}
+
+	values, err := cr.reader.Read()
+	if err == io.EOF {
+		return 0, importRecord{}, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.Line, importRecord{}, &rowError{parseErr.Err.Error()}
+	}
+	if err != nil {
+		return 0, importRecord{}, err
+	}
+
+	line, _ := cr.reader.FieldPos(0)
+	if len(values) != len(cr.header) {
+		return line, importRecord{}, &rowError{fmt.Sprintf("expected %d fields, got %d", len(cr.header), len(values))}
+	}
+	fields := make(map[string]string, len(values))
+	for i, value := range values {
+		fields[cr.header[i]] = value
+	}
+	return line, importRecord{fields: fields}, nil
+}
+
+// decodeCustomerRecord builds a customer from a record with name, email, password, gender
+// and an optional created_at column
+func decodeCustomerRecord(record importRecord) (*models.Customer, error) {
+	customer := &models.Customer{}
+	if record.fields == nil {
+		if err := json.Unmarshal(record.raw, customer); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return customer, nil
+	}
+
+	customer.Name = record.fields["name"]
+	customer.Email = record.fields["email"]
+	customer.Password = record.fields["password"]
+	customer.Gender = models.Gender(record.fields["gender"])
+	if v := record.fields["created_at"]; v != "" {
+		createdAt, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid created_at: %s", v)
+		}
+		customer.CreatedAt = createdAt
+	}
+	return customer, nil
+}
+
+// decodeTransactionRecord builds a transaction from a record with customer_id, amount, time
+// and optional currency, type and original_transaction_id columns
+func decodeTransactionRecord(record importRecord) (*models.TransactionDTO, error) {
+	transaction := &models.TransactionDTO{}
+	if record.fields == nil {
+		if err := json.Unmarshal(record.raw, transaction); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return transaction, nil
+	}
+
+	customerID, err := uuid.Parse(record.fields["customer_id"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid customer_id: %s", record.fields["customer_id"])
+	}
+	amount, err := models.ParseMoney(record.fields["amount"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount: %s", record.fields["amount"])
+	}
+	txnTime, err := time.Parse(time.RFC3339, record.fields["time"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid time: %s", record.fields["time"])
+	}
+	transaction.CustomerID = customerID
+	transaction.Amount = amount
+	transaction.Currency = models.Currency(record.fields["currency"])
+	transaction.Type = models.TransactionType(record.fields["type"])
+	if v := record.fields["original_transaction_id"]; v != "" {
+		originalID, err := uuid.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid original_transaction_id: %s", v)
+		}
+		transaction.OriginalTransactionID = &originalID
+	}
+	transaction.Time = txnTime
+	return transaction, nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+// Notifier delivers notifications to customers. Production deployments plug in a mail or SMS provider;
+// NewLogNotifier and NewFileNotifier are for development.
+type Notifier interface {
+	Notify(notification *models.Notification) error
+}
+
+type logNotifier struct{}
+
+// NewLogNotifier creates a Notifier writing notifications to the server log
+func NewLogNotifier() Notifier {
+	return &logNotifier{}
+}
+
+// Notify logs the notification, including its body
+func (ln *logNotifier) Notify(notification *models.Notification) error {
+	log.Printf("Notification to %s: %s\n%s", notification.To, notification.Subject, notification.Body)
+	return nil
+}
+
+type fileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileNotifier creates a Notifier appending notifications to a file as JSON lines
+func NewFileNotifier(path string) Notifier {
+	return &fileNotifier{path: path}
+}
+
+// Notify appends the notification with the time it was sent
+func (fn *fileNotifier) Notify(notification *models.Notification) error {
+	line, err := json.Marshal(struct {
+		SentAt time.Time `json:"sent_at"`
+		*models.Notification
+	}{time.Now(), notification})
+	if err != nil {
+		return err
+	}
+
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	file, err := os.OpenFile(fn.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
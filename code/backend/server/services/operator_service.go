@@ -0,0 +1,167 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+var (
+	// ErrInvalidOperator is wrapped by the errors returned for operator requests with invalid fields
+	ErrInvalidOperator = errors.New("invalid operator")
+	// ErrOperatorEmailTaken is returned when creating an operator with an email already in use
+	ErrOperatorEmailTaken = errors.New("an operator with this email already exists")
+	// ErrLastAdmin is returned for changes that would leave no active admin to manage operators
+	ErrLastAdmin = errors.New("at least one active admin must remain")
+)
+
+type OperatorService interface {
+	GetAllOperators() ([]*models.Operator, error)
+	CreateOperator(req *models.CreateOperatorRequest) (*models.Operator, error)
+	UpdateOperator(id uuid.UUID, req *models.UpdateOperatorRequest) (*models.Operator, error)
+	DeleteOperator(id uuid.UUID) error
+}
+
+type operatorService struct {
+	operatorRepo   repositories.OperatorRepository
+	sessionRepo    repositories.AuthSessionRepository
+	passwordHasher PasswordHasher
+}
+
+// NewOperatorService creates a new instance of OperatorService
+func NewOperatorService(operatorRepo repositories.OperatorRepository, sessionRepo repositories.AuthSessionRepository, passwordHasher PasswordHasher) OperatorService {
+	return &operatorService{operatorRepo: operatorRepo, sessionRepo: sessionRepo, passwordHasher: passwordHasher}
+}
+
+// GetAllOperators retrieves all operator accounts
+func (ops *operatorService) GetAllOperators() ([]*models.Operator, error) {
+	operators, err := ops.operatorRepo.GetAllOperators()
+	if err != nil {
+		return nil, err
+	}
+	if operators == nil {
+		operators = []*models.Operator{}
+	}
+	return operators, nil
+}
+
+// CreateOperator validates the request and creates an operator account with a hashed password
+func (ops *operatorService) CreateOperator(req *models.CreateOperatorRequest) (*models.Operator, error) {
+	if req.Name == "" || req.Password == "" {
+		return nil, fmt.Errorf("%w: name and password are required", ErrInvalidOperator)
+	}
+	if _, err := mail.ParseAddress(req.Email); err != nil {
+		return nil, fmt.Errorf("%w: invalid email: %s", ErrInvalidOperator, req.Email)
+	}
+	if !req.Role.IsOperatorRole() {
+		return nil, fmt.Errorf("%w: role must be admin or operator", ErrInvalidOperator)
+	}
+	if err := validatePassword(req.Password, req.Email, req.Name); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOperator, err)
+	}
+
+	if _, err := ops.operatorRepo.GetOperatorByEmail(req.Email); err == nil {
+		return nil, ErrOperatorEmailTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	hashedPassword, err := ops.passwordHasher.Hash(req.Password)
+	if err != nil {
+		return nil, err
+	}
+	operator := &models.Operator{
+		ID:       uuid.New(),
+		Name:     req.Name,
+		Email:    req.Email,
+		Password: hashedPassword,
+		Role:     req.Role,
+	}
+	if err := ops.operatorRepo.CreateOperator(operator); err != nil {
+		return nil, err
+	}
+	return operator, nil
+}
+
+// UpdateOperator changes an operator's name, role or disabled state. Changing the role or disabling the
+// operator revokes their sessions, so the change takes effect immediately. It returns gorm.ErrRecordNotFound
+// if the operator does not exist.
+func (ops *operatorService) UpdateOperator(id uuid.UUID, req *models.UpdateOperatorRequest) (*models.Operator, error) {
+	operator, err := ops.operatorRepo.GetOperatorByID(id)
+	if err != nil {
+		return nil, err
+	}
+	wasActiveAdmin := operator.Role == models.RoleAdmin && operator.DisabledAt == nil
+	revoke := false
+
+	if req.Name != nil {
+		if *req.Name == "" {
+			return nil, fmt.Errorf("%w: name must not be empty", ErrInvalidOperator)
+		}
+		operator.Name = *req.Name
+	}
+	if req.Role != nil && *req.Role != operator.Role {
+		if !req.Role.IsOperatorRole() {
+			return nil, fmt.Errorf("%w: role must be admin or operator", ErrInvalidOperator)
+		}
+		operator.Role = *req.Role
+		revoke = true
+	}
+	if req.Disabled != nil && *req.Disabled != (operator.DisabledAt != nil) {
+		operator.DisabledAt = nil
+		if *req.Disabled {
+			now := time.Now()
+			operator.DisabledAt = &now
+			revoke = true
+		}
+	}
+
+	if wasActiveAdmin && (operator.Role != models.RoleAdmin || operator.DisabledAt != nil) {
+		if err := ops.ensureAnotherAdmin(); err != nil {
+			return nil, err
+		}
+	}
+	if err := ops.operatorRepo.UpdateOperator(operator); err != nil {
+		return nil, err
+	}
+	if revoke {
+		if err := ops.sessionRepo.RevokeOperatorSessions(operator.ID); err != nil {
+			return nil, err
+		}
+	}
+	return operator, nil
+}
+
+// DeleteOperator deletes an operator account and its sessions. It returns gorm.ErrRecordNotFound
+// if the operator does not exist.
+func (ops *operatorService) DeleteOperator(id uuid.UUID) error {
+	operator, err := ops.operatorRepo.GetOperatorByID(id)
+	if err != nil {
+		return err
+	}
+	if operator.Role == models.RoleAdmin && operator.DisabledAt == nil {
+		if err := ops.ensureAnotherAdmin(); err != nil {
+			return err
+		}
+	}
+	return ops.operatorRepo.DeleteOperator(id)
+}
+
+// ensureAnotherAdmin fails with ErrLastAdmin unless more than one active admin exists
+func (ops *operatorService) ensureAnotherAdmin() error {
+	admins, err := ops.operatorRepo.CountActiveAdmins()
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Password hashing algorithms
+const (
+	PasswordArgon2id = "argon2id"
+	PasswordScrypt   = "scrypt"
+)
+
+// Parameters of new hashes, following the OWASP recommendations. Hashes made with other parameters
+// are still verified and upgraded on the next successful login.
+const (
+	argon2Memory  = 19456 // KiB
+	argon2Time    = 2
+	argon2Threads = 1
+	scryptLogN    = 16
+	scryptR       = 8
+	scryptP       = 2
+	saltLength    = 16
+	keyLength     = 32
+)
+
+// errMalformedHash is returned for stored hashes that cannot be parsed
+var errMalformedHash = errors.New("malformed password hash")
+
+// PasswordHasher hashes passwords into self-describing PHC strings such as
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>, with a random salt per password
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches the stored hash, and whether the hash should be replaced
+	// by a new one because it was made with a legacy format, another algorithm, other parameters or
+	// another pepper
+	Verify(password, hash string) (ok bool, needsRehash bool, err error)
+}
+
+// PasswordSettings configures PasswordHasher
+type PasswordSettings struct {
+	Algorithm  string // algorithm of new hashes, PasswordArgon2id or PasswordScrypt
+	Pepper     string // server-side secret mixed into every new hash; empty disables it
+	LegacySalt string // global salt of the unprefixed scrypt hashes stored before PHC strings
+}
+
+type passwordHasher struct {
+	settings PasswordSettings
+	keyID    string
+}
+
+// NewPasswordHasher creates a new instance of PasswordHasher
+func NewPasswordHasher(settings PasswordSettings) PasswordHasher {
+	return &passwordHasher{settings: settings, keyID: pepperKeyID(settings.Pepper)}
+}
+
+// phcHash is a parsed PHC string
+type phcHash struct {
+	algorithm string
+	version   string
+	params    map[string]string
+	salt      []byte
+	key       []byte
+}
+
+// Hash hashes the password with the configured algorithm, a new random salt and the pepper
+func (ph *passwordHasher) Hash(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	hash := &phcHash{algorithm: ph.settings.Algorithm, params: make(map[string]string), salt: salt}
+	switch ph.settings.Algorithm {
+	case PasswordArgon2id:
+		hash.version = strconv.Itoa(argon2.Version)
+		hash.params["m"] = strconv.Itoa(argon2Memory)
+		hash.params["t"] = strconv.Itoa(argon2Time)
+		hash.params["p"] = strconv.Itoa(argon2Threads)
+	case PasswordScrypt:
+		hash.params["ln"] = strconv.Itoa(scryptLogN)
+		hash.params["r"] = strconv.Itoa(scryptR)
+		hash.params["p"] = strconv.Itoa(scryptP)
+	default:
+		return "", fmt.Errorf("unsupported password hashing algorithm: %s", ph.settings.Algorithm)
+	}
+	if ph.keyID != "" {
+		hash.params["keyid"] = ph.keyID
+	}
+
+	key, err := ph.derive(password, hash)
+	if err != nil {
+		return "", err
+	}
+	hash.key = key
+	return hash.String(), nil
+}
+
+// Verify compares the password with the stored hash in constant time
+func (ph *passwordHasher) Verify(password, hash string) (bool, bool, error) {
+	if !strings.HasPrefix(hash, "$") {
+		ok, err := ph.verifyLegacy(password, hash)
+		return ok, true, err
+	}
+
+	parsed, err := parsePHC(hash)
+	if err != nil {
+		return false, false, err
+	}
+	key, err := ph.derive(password, parsed)
+	if err != nil {
+		return false, false, err
+	}
+	if subtle.ConstantTimeCompare(key, parsed.key) != 1 {
+		return false, false, nil
+	}
+	return true, ph.needsRehash(parsed), nil
+}
+
+// verifyLegacy verifies an unprefixed hash, which is base64 scrypt with N=1024 and the global salt
+func (ph *passwordHasher) verifyLegacy(password, hash string) (bool, error) {
+	dk, err := scrypt.Key([]byte(password), []byte(ph.settings.LegacySalt), 1024, 8, 1, keyLength)
+	if err != nil {
+		return false, err
+	}
+	computed := base64.StdEncoding.EncodeToString(dk)
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1, nil
+}
+
+// derive computes the key of the password with the algorithm, parameters and salt of hash
+func (ph *passwordHasher) derive(password string, hash *phcHash) ([]byte, error) {
+	input, err := ph.pepper(password, hash.params["keyid"])
+	if err != nil {
+		return nil, err
+	}
+	keyLen := keyLength
+	if hash.key != nil {
+		keyLen = len(hash.key)
+	}
+
+	switch hash.algorithm {
+	case PasswordArgon2id:
+		if hash.version != strconv.Itoa(argon2.Version) {
+			return nil, fmt.Errorf("%w: unsupported argon2 version %s", errMalformedHash, hash.version)
+		}
+		m, t, p, err := hash.intParams("m", "t", "p")
+		if err != nil {
+			return nil, err
+		}
+		if p > 255 {
+			return nil, fmt.Errorf("%w: invalid parameters", errMalformedHash)
+		}
+		return argon2.IDKey(input, hash.salt, uint32(t), uint32(m), uint8(p), uint32(keyLen)), nil
+	case PasswordScrypt:
+		ln, r, p, err := hash.intParams("ln", "r", "p")
+		if err != nil {
+			return nil, err
+		}
+		if ln > 30 {
+			return nil, fmt.Errorf("%w: invalid parameters", errMalformedHash)
+		}
+		return scrypt.Key(input, hash.salt, 1<<ln, r, p, keyLen)
+	default:
+		return nil, fmt.Errorf("%w: unsupported algorithm %s", errMalformedHash, hash.algorithm)
+	}
+}
+
+// pepper mixes the pepper identified by keyID into the password. Hashes made with a pepper that is no
+// longer configured cannot be verified, so that changing the pepper by mistake fails loudly.
+func (ph *passwordHasher) pepper(password, keyID string) ([]byte, error) {
+	if keyID == "" {
+		return []byte(password), nil
+	}
+	if keyID != ph.keyID {
+		return nil, fmt.Errorf("password hash was made with pepper %s, which is not configured", keyID)
+	}
+	mac := hmac.New(sha256.New, []byte(ph.settings.Pepper))
+	mac.Write([]byte(password))
+	return mac.Sum(nil), nil
+}
+
+// needsRehash reports whether a verified hash differs from the ones Hash makes now
+func (ph *passwordHasher) needsRehash(hash *phcHash) bool {
+	if hash.algorithm != ph.settings.Algorithm || hash.params["keyid"] != ph.keyID ||
+		len(hash.salt) < saltLength || len(hash.key) != keyLength {
+		return true
+	}
+	switch hash.algorithm {
+	case PasswordArgon2id:
+		return hash.params["m"] != strconv.Itoa(argon2Memory) || hash.params["t"] != strconv.Itoa(argon2Time) ||
+			hash.params["p"] != strconv.Itoa(argon2Threads)
+	default:
+		return hash.params["ln"] != strconv.Itoa(scryptLogN) || hash.params["r"] != strconv.Itoa(scryptR) ||
+			hash.params["p"] != strconv.Itoa(scryptP)
+	}
+}
+
+// parsePHC parses $<algorithm>[$v=<version>]$<params>$<salt>$<hash>, with salt and hash in unpadded base64
+func parsePHC(value string) (*phcHash, error) {
+	fields := strings.Split(value, "$")
+	if len(fields) < 5 || fields[0] != "" {
+		return nil, errMalformedHash
+	}
+	hash := &phcHash{algorithm: fields[1], params: make(map[string]string)}
+	fields = fields[2:]
+	if version, ok := strings.CutPrefix(fields[0], "v="); ok {
+		hash.version = version
+		fields = fields[1:]
+	}
+	if len(fields) != 3 {
+		return nil, errMalformedHash
+	}
+
+	for _, param := range strings.Split(fields[0], ",") {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return nil, errMalformedHash
+		}
+		hash.params[name] = value
+	}
+	var err error
+	if hash.salt, err = base64.RawStdEncoding.DecodeString(fields[1]); err != nil {
+		return nil, errMalformedHash
+	}
+	if hash.key, err = base64.RawStdEncoding.DecodeString(fields[2]); err != nil || len(hash.key) == 0 {
+		return nil, errMalformedHash
+	}
+	return hash, nil
+}
+
+// String encodes the hash as a PHC string
+func (h *phcHash) String() string {
+	var params []string
+	for _, name := range []string{"m", "t", "ln", "r", "p", "keyid"} {
+		if value, ok := h.params[name]; ok {
+			params = append(params, name+"="+value)
+		}
+	}
+
+	fields := []string{"", h.algorithm}
+	if h.version != "" {
+		fields = append(fields, "v="+h.version)
+	}
+	fields = append(fields, strings.Join(params, ","),
+		base64.RawStdEncoding.EncodeToString(h.salt), base64.RawStdEncoding.EncodeToString(h.key))
+	return strings.Join(fields, "$")
+}
+
+// intParams parses the named parameters as positive integers
+func (h *phcHash) intParams(a, b, c string) (int, int, int, error) {
+	values := make([]int, 3)
+	for i, name := range []string{a, b, c} {
+		value, err := strconv.Atoi(h.params[name])
+		if err != nil || value <= 0 {
+			return 0, 0, 0, fmt.Errorf("%w: invalid parameter %s", errMalformedHash, name)
+		}
+		values[i] = value
+	}
+	return values[0], values[1], values[2], nil
+}
+
+// pepperKeyID identifies the pepper in the hashes made with it without revealing it
+func pepperKeyID(pepper string) string {
+	if pepper == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte("password-pepper:" + pepper))
+	return base64.RawStdEncoding.EncodeToString(sum[:6])
+}
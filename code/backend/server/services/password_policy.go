@@ -0,0 +1,75 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrWeakPassword is returned for passwords that do not meet the strength rules
+var ErrWeakPassword = errors.New("weak password")
+
+const (
+	minPasswordLength = 8
+	// passphraseLength is the length from which a password needs no mix of character classes
+	passphraseLength = 16
+	// maxPasswordBytes bounds the input of the password hash
+	maxPasswordBytes = 128
+)
+
+// commonPasswords are frequently used passwords that meet the other rules
+var commonPasswords = map[string]bool{
+	"password1!": true, "password123": true, "passw0rd!": true, "p@ssw0rd": true, "p@ssword1": true,
+	"qwerty123!": true, "qwerty12345": true, "abc12345!": true, "welcome1!": true, "welcome123": true,
+	"letmein1!": true, "iloveyou1!": true, "admin123!": true, "changeme1!": true, "1qaz@wsx": true,
+	"1q2w3e4r5t": true, "zaq12wsx": true, "trustno1!": true, "sunshine1!": true, "football1!": true,
+}
+
+// validatePassword checks a new password against the strength rules: it must have at least 8 characters
+// and at most 128 bytes, mix three of lowercase letters, uppercase letters, digits and symbols unless it has
+// at least 16 characters, not be a common password and not contain the account's email name or name
+func validatePassword(password, email, name string) error {
+	length := utf8.RuneCountInString(password)
+	if length < minPasswordLength {
+		return fmt.Errorf("%w: password must be at least %d characters", ErrWeakPassword, minPasswordLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("%w: password must be at most %d bytes", ErrWeakPassword, maxPasswordBytes)
+	}
+	if length < passphraseLength && characterClasses(password) < 3 {
+		return fmt.Errorf("%w: password must mix at least three of lowercase letters, uppercase letters, digits and symbols, or have at least %d characters",
+			ErrWeakPassword, passphraseLength)
+	}
+
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return fmt.Errorf("%w: password is too common", ErrWeakPassword)
+	}
+	emailName, _, _ := strings.Cut(email, "@")
+	for _, personal := range []string{emailName, name} {
+		if len(personal) >= 3 && strings.Contains(lower, strings.ToLower(personal)) {
+			return fmt.Errorf("%w: password must not contain the email or name", ErrWeakPassword)
+		}
+	}
+	return nil
+}
+
+// characterClasses counts which of lowercase letters, uppercase letters, digits and symbols the password uses
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
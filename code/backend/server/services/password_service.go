@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+var (
+	// ErrIncorrectPassword is returned when a password change presents the wrong current password
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	// ErrInvalidResetToken is returned for reset tokens that are unknown, used or expired
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+	// ErrPasswordResetDisabled is returned for reset requests when no notifier is configured to deliver them
+	ErrPasswordResetDisabled = errors.New("password reset is not available")
+	// ErrPasswordResetBusy is returned for reset requests while too many are waiting to be delivered
+	ErrPasswordResetBusy = errors.New("too many password reset requests, try again later")
+)
+
+type PasswordService interface {
+	ChangePassword(customerID uuid.UUID, req *models.ChangePasswordRequest, keepSessionID uuid.UUID) error
+	RequestReset(email string) error
+	RunResetDelivery(ctx context.Context)
+	ResetPassword(req *models.ResetPasswordRequest) error
+}
+
+// PasswordResetSettings configures the reset tokens of PasswordService
+type PasswordResetSettings struct {
+	TTL time.Duration // lifetime of a reset token
+	URL string        // page the reset link points to, with the token in its "token" query parameter
+}
+
+type passwordService struct {
+	customerRepo   repositories.CustomerRepository
+	resetRepo      repositories.PasswordResetRepository
+	sessionRepo    repositories.AuthSessionRepository
+	passwordHasher PasswordHasher
+	notifier       Notifier
+	settings       PasswordResetSettings
+	resetRequests  chan string
+}
+
+// resetQueueSize bounds the reset requests waiting for RunResetDelivery
+const resetQueueSize = 256
+
+// NewPasswordService creates a new instance of PasswordService. A nil notifier disables password resets.
+func NewPasswordService(customerRepo repositories.CustomerRepository, resetRepo repositories.PasswordResetRepository, sessionRepo repositories.AuthSessionRepository,
+	passwordHasher PasswordHasher, notifier Notifier, settings PasswordResetSettings) PasswordService {
+	return &passwordService{
+		customerRepo:   customerRepo,
+		resetRepo:      resetRepo,
+		sessionRepo:    sessionRepo,
+		passwordHasher: passwordHasher,
+		notifier:       notifier,
+		settings:       settings,
+		resetRequests:  make(chan string, resetQueueSize),
+	}
+}
+
+// ChangePassword sets a customer's new password after verifying their current one, and revokes their
+// sessions other than keepSessionID, which may be uuid.Nil. It returns gorm.ErrRecordNotFound if the
+// customer does not exist.
+func (ps *passwordService) ChangePassword(customerID uuid.UUID, req *models.ChangePasswordRequest, keepSessionID uuid.UUID) error {
+	customer, err := ps.customerRepo.GetCustomerWithPassword(customerID)
+	if err != nil {
+		return err
+	}
+	ok, _, err := ps.passwordHasher.Verify(req.CurrentPassword, customer.Password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrIncorrectPassword
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return fmt.Errorf("%w: new password must differ from the current one", ErrWeakPassword)
+	}
+	if err := validatePassword(req.NewPassword, customer.Email, customer.Name); err != nil {
+		return err
+	}
+
+	hashedPassword, err := ps.passwordHasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := ps.customerRepo.UpdatePassword(&models.Customer{ID: customer.ID, Password: hashedPassword}); err != nil {
+		return err
+	}
+	return ps.sessionRepo.RevokeCustomerSessions(customer.ID, keepSessionID)
+}
+
+// RequestReset queues a reset link for the customer with the email and returns at once. Looking the
+// email up, creating the token and delivering it happen in RunResetDelivery, so that the response, and
+// how long it takes, do not reveal which accounts exist. It returns ErrPasswordResetDisabled if no
+// notifier is configured, and ErrPasswordResetBusy if the queue is full.
+func (ps *passwordService) RequestReset(email string) error {
+	if ps.notifier == nil {
+		return ErrPasswordResetDisabled
+	}
+	select {
+	case ps.resetRequests <- email:
+		return nil
+	default:
+		return ErrPasswordResetBusy
+	}
+}
+
+// RunResetDelivery sends the reset links queued by RequestReset until ctx is done
+func (ps *passwordService) RunResetDelivery(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case email := <-ps.resetRequests:
+			if err := ps.sendResetLink(email); err != nil {
+				log.Printf("Sending a password reset link failed: %v", err)
+			}
+		}
+	}
+}
+
+// sendResetLink sends a customer a link with a new reset token, which replaces their earlier ones.
+// Emails without an account are ignored.
+func (ps *passwordService) sendResetLink(email string) error {
+	customer, err := ps.customerRepo.GetCustomerByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	expiresAt := time.Now().Add(ps.settings.TTL)
+	if err := ps.resetRepo.ReplaceToken(&models.PasswordResetToken{
+		ID:         uuid.New(),
+		CustomerID: customer.ID,
+		TokenHash:  resetTokenHash(token),
+		ExpiresAt:  expiresAt,
+	}); err != nil {
+		return err
+	}
+
+	return ps.notifier.Notify(&models.Notification{
+		To:      customer.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Open %s?token=%s to set a new password. The link can be used once and expires at %s. "+
+			"If you did not ask to reset your password, ignore this message.",
+			ps.settings.URL, url.QueryEscape(token), expiresAt.UTC().Format(time.RFC3339)),
+	})
+}
+
+// ResetPassword sets a new password with a reset token, which cannot be used again, and revokes all
+// sessions of the customer
+func (ps *passwordService) ResetPassword(req *models.ResetPasswordRequest) error {
+	now := time.Now()
+	token, err := ps.resetRepo.GetTokenByHash(resetTokenHash(req.Token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if token.UsedAt != nil || !now.Before(token.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	customer, err := ps.customerRepo.GetCustomerByID(token.CustomerID)
+	if err != nil {
+		return err
+	}
+	if err := validatePassword(req.NewPassword, customer.Email, customer.Name); err != nil {
+		return err
+	}
+	hashedPassword, err := ps.passwordHasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	err = ps.resetRepo.ConsumeToken(token.ID, hashedPassword, now)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	return ps.sessionRepo.RevokeCustomerSessions(customer.ID, uuid.Nil)
+}
+
+// resetTokenHash returns the hex SHA-256 under which a reset token is stored
+func resetTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+// passwordTestSchema mirrors the columns of the migrated MariaDB schema that the password service touches
+var passwordTestSchema = []string{
+	`CREATE TABLE customers (
+		id char(36) NOT NULL PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		password varchar(255) NOT NULL,
+		email varchar(255) NOT NULL UNIQUE,
+		gender varchar(16) NOT NULL,
+		created_at timestamp NULL
+	)`,
+	`CREATE TABLE auth_sessions (
+		id char(36) NOT NULL PRIMARY KEY,
+		customer_id char(36) NULL,
+		operator_id char(36) NULL,
+		role varchar(32) NOT NULL DEFAULT 'customer',
+		refresh_id char(36) NOT NULL,
+		expires_at timestamp NULL,
+		revoked_at timestamp NULL,
+		created_at timestamp NULL
+	)`,
+	`CREATE TABLE password_reset_tokens (
+		id char(36) NOT NULL PRIMARY KEY,
+		customer_id char(36) NOT NULL,
+		token_hash char(64) NOT NULL UNIQUE,
+		expires_at timestamp NULL,
+		used_at timestamp NULL,
+		created_at timestamp NULL
+	)`,
+}
+
+// recordingNotifier keeps the notifications it is asked to deliver
+type recordingNotifier struct {
+	sent []*models.Notification
+}
+
+func (rn *recordingNotifier) Notify(notification *models.Notification) error {
+	rn.sent = append(rn.sent, notification)
+	return nil
+}
+
+// newPasswordTestDB returns an empty in-memory SQLite database with the tables of passwordTestSchema
+func newPasswordTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+uuid.NewString()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	for _, statement := range passwordTestSchema {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestChangePassword(t *testing.T) {
+	const currentPassword = "Correct-Horse-42"
+
+	tests := []struct {
+		name        string
+		current     string
+		newPassword string
+		wantErr     error
+	}{
+		{"correct current password", currentPassword, "Battery-Staple-77", nil},
+		{"wrong current password", "Wrong-Horse-42", "Battery-Staple-77", ErrIncorrectPassword},
+		{"empty current password", "", "Battery-Staple-77", ErrIncorrectPassword},
+		{"unchanged password", currentPassword, currentPassword, ErrWeakPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newPasswordTestDB(t)
+			customerRepo := repositories.NewCustomerRepository(db)
+			sessionRepo := repositories.NewAuthSessionRepository(db)
+			hasher := NewPasswordHasher(PasswordSettings{Algorithm: PasswordArgon2id})
+			service := NewPasswordService(customerRepo, repositories.NewPasswordResetRepository(db), sessionRepo,
+				hasher, &recordingNotifier{}, PasswordResetSettings{TTL: time.Hour, URL: "http://localhost/reset"})
+
+			hash, err := hasher.Hash(currentPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			customer := &models.Customer{ID: uuid.New(), Name: "Test Customer", Password: hash,
+				Email: "customer@example.com", Gender: models.Other, CreatedAt: time.Now()}
+			if err := customerRepo.CreateCustomer(customer); err != nil {
+				t.Fatal(err)
+			}
+			sessionIDs := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+			for _, id := range sessionIDs {
+				session := &models.AuthSession{ID: id, CustomerID: &customer.ID, Role: models.RoleCustomer,
+					RefreshID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now()}
+				if err := db.Create(session).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = service.ChangePassword(customer.ID, &models.ChangePasswordRequest{
+				CurrentPassword: tt.current,
+				NewPassword:     tt.newPassword,
+			}, sessionIDs[0])
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangePassword() error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, err := customerRepo.GetCustomerWithPassword(customer.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantPassword := currentPassword
+			if tt.wantErr == nil {
+				wantPassword = tt.newPassword
+			}
+			if ok, _, err := hasher.Verify(wantPassword, stored.Password); err != nil || !ok {
+				t.Errorf("stored hash does not verify %q: ok = %v, err = %v", wantPassword, ok, err)
+			}
+
+			var sessions []models.AuthSession
+			if err := db.Order("id").Find(&sessions).Error; err != nil {
+				t.Fatal(err)
+			}
+			for _, session := range sessions {
+				wantRevoked := tt.wantErr == nil && session.ID != sessionIDs[0]
+				if revoked := session.RevokedAt != nil; revoked != wantRevoked {
+					t.Errorf("session %s revoked = %v, want %v", session.ID, revoked, wantRevoked)
+				}
+			}
+		})
+	}
+}
+
+func TestChangePasswordUnknownCustomer(t *testing.T) {
+	db := newPasswordTestDB(t)
+	service := NewPasswordService(repositories.NewCustomerRepository(db), repositories.NewPasswordResetRepository(db),
+		repositories.NewAuthSessionRepository(db), NewPasswordHasher(PasswordSettings{Algorithm: PasswordArgon2id}),
+		&recordingNotifier{}, PasswordResetSettings{TTL: time.Hour})
+
+	err := service.ChangePassword(uuid.New(), &models.ChangePasswordRequest{
+		CurrentPassword: "Correct-Horse-42",
+		NewPassword:     "Battery-Staple-77",
+	}, uuid.Nil)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("ChangePassword() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func TestRequestReset(t *testing.T) {
+	db := newPasswordTestDB(t)
+	customerRepo := repositories.NewCustomerRepository(db)
+	notifier := &recordingNotifier{}
+	service := NewPasswordService(customerRepo, repositories.NewPasswordResetRepository(db),
+		repositories.NewAuthSessionRepository(db), NewPasswordHasher(PasswordSettings{Algorithm: PasswordArgon2id}),
+		notifier, PasswordResetSettings{TTL: time.Hour, URL: "http://localhost/reset"})
+	customer := &models.Customer{ID: uuid.New(), Name: "Test Customer", Password: "unused",
+		Email: "customer@example.com", Gender: models.Other, CreatedAt: time.Now()}
+	if err := customerRepo.CreateCustomer(customer); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both requests are only queued, so neither touches the database before responding
+	for _, email := range []string{"unknown@example.com", customer.Email} {
+		if err := service.RequestReset(email); err != nil {
+			t.Fatalf("RequestReset(%q) error = %v", email, err)
+		}
+	}
+	var tokens int64
+	if err := db.Model(&models.PasswordResetToken{}).Count(&tokens).Error; err != nil {
+		t.Fatal(err)
+	}
+	if tokens != 0 || len(notifier.sent) != 0 {
+		t.Fatalf("RequestReset created %d tokens and sent %d notifications before delivery", tokens, len(notifier.sent))
+	}
+
+	ps := service.(*passwordService)
+	for len(ps.resetRequests) > 0 {
+		if err := ps.sendResetLink(<-ps.resetRequests); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Model(&models.PasswordResetToken{}).Where("customer_id = ?", customer.ID).Count(&tokens).Error; err != nil {
+		t.Fatal(err)
+	}
+	if tokens != 1 {
+		t.Errorf("customer has %d reset tokens, want 1", tokens)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].To != customer.Email {
+		t.Errorf("sent %+v, want a single notification to %s", notifier.sent, customer.Email)
+	}
+}
+
+func TestRequestResetDisabled(t *testing.T) {
+	db := newPasswordTestDB(t)
+	service := NewPasswordService(repositories.NewCustomerRepository(db), repositories.NewPasswordResetRepository(db),
+		repositories.NewAuthSessionRepository(db), NewPasswordHasher(PasswordSettings{Algorithm: PasswordArgon2id}),
+		nil, PasswordResetSettings{TTL: time.Hour})
+
+	if err := service.RequestReset("customer@example.com"); !errors.Is(err, ErrPasswordResetDisabled) {
+		t.Fatalf("RequestReset() error = %v, want %v", err, ErrPasswordResetDisabled)
+	}
+}
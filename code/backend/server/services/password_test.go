@@ -0,0 +1,299 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+const (
+	testPassword   = "Correct-Horse-42"
+	testLegacySalt = "legacy-salt"
+)
+
+// legacyHash returns the unprefixed base64 scrypt hash stored before PHC strings
+func legacyHash(t *testing.T, password, salt string) string {
+	t.Helper()
+	dk, err := scrypt.Key([]byte(password), []byte(salt), 1024, 8, 1, keyLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(dk)
+}
+
+func TestPasswordHasherRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   PasswordSettings
+		wantPrefix string
+	}{
+		{"argon2id", PasswordSettings{Algorithm: PasswordArgon2id}, "$argon2id$v=19$m=19456,t=2,p=1$"},
+		{"scrypt", PasswordSettings{Algorithm: PasswordScrypt}, "$scrypt$ln=16,r=8,p=2$"},
+		{"argon2id with pepper", PasswordSettings{Algorithm: PasswordArgon2id, Pepper: "pepper"}, "$argon2id$v=19$m=19456,t=2,p=1,keyid="},
+		{"scrypt with pepper", PasswordSettings{Algorithm: PasswordScrypt, Pepper: "pepper"}, "$scrypt$ln=16,r=8,p=2,keyid="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher := NewPasswordHasher(tt.settings)
+			hash, err := hasher.Hash(testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(hash, tt.wantPrefix) {
+				t.Errorf("Hash() = %q, want prefix %q", hash, tt.wantPrefix)
+			}
+
+			parsed, err := parsePHC(hash)
+			if err != nil {
+				t.Fatalf("parsePHC(%q) returned error: %v", hash, err)
+			}
+			if got := parsed.String(); got != hash {
+				t.Errorf("parsePHC(%q).String() = %q", hash, got)
+			}
+			if len(parsed.salt) != saltLength || len(parsed.key) != keyLength {
+				t.Errorf("salt and key lengths = %d, %d, want %d, %d", len(parsed.salt), len(parsed.key), saltLength, keyLength)
+			}
+
+			ok, needsRehash, err := hasher.Verify(testPassword, hash)
+			if err != nil || !ok || needsRehash {
+				t.Errorf("Verify(correct) = %v, %v, %v, want true, false, nil", ok, needsRehash, err)
+			}
+			ok, _, err = hasher.Verify("Wrong-Horse-42", hash)
+			if err != nil || ok {
+				t.Errorf("Verify(wrong) = %v, %v, want false, nil", ok, err)
+			}
+
+			again, err := hasher.Hash(testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again == hash {
+				t.Errorf("Hash() returned the same hash twice, salts are not random")
+			}
+		})
+	}
+}
+
+func TestParsePHC(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr bool
+	}{
+		{input: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"},
+		{input: "$scrypt$ln=16,r=8,p=2$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"},
+		{input: "$scrypt$ln=16,r=8,p=2,keyid=abcd$c2FsdA$a2V5"},
+
+		{input: "", wantErr: true},
+		{input: "$", wantErr: true},
+		{input: "argon2id$v=19$m=19456,t=2,p=1$c2FsdA$a2V5", wantErr: true},
+		{input: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA", wantErr: true},
+		{input: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$a2V5$extra", wantErr: true},
+		{input: "$argon2id$v=19$m19456,t=2,p=1$c2FsdA$a2V5", wantErr: true},
+		{input: "$argon2id$v=19$m=19456,t=2,p=1$!!!$a2V5", wantErr: true},
+		{input: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$!!!", wantErr: true},
+		{input: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$", wantErr: true},
+		{input: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA==$a2V5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parsePHC(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, errMalformedHash) {
+					t.Fatalf("parsePHC(%q) error = %v, want %v", tt.input, err, errMalformedHash)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePHC(%q) returned error: %v", tt.input, err)
+			}
+			if got.String() != tt.input {
+				t.Errorf("parsePHC(%q).String() = %q", tt.input, got.String())
+			}
+		})
+	}
+}
+
+func TestVerifyMalformedHash(t *testing.T) {
+	const salt, key = "c2FsdHNhbHRzYWx0c2FsdA", "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"too few fields", "$argon2id$v=19$m=19456,t=2,p=1$" + salt},
+		{"unknown algorithm", "$bcrypt$r=10$" + salt + "$" + key},
+		{"unsupported argon2 version", "$argon2id$v=16$m=19456,t=2,p=1$" + salt + "$" + key},
+		{"missing argon2 version", "$argon2id$m=19456,t=2,p=1$" + salt + "$" + key},
+		{"zero memory", "$argon2id$v=19$m=0,t=2,p=1$" + salt + "$" + key},
+		{"missing time", "$argon2id$v=19$m=19456,p=1$" + salt + "$" + key},
+		{"too many threads", "$argon2id$v=19$m=19456,t=2,p=256$" + salt + "$" + key},
+		{"negative scrypt cost", "$scrypt$ln=-1,r=8,p=2$" + salt + "$" + key},
+		{"excessive scrypt cost", "$scrypt$ln=31,r=8,p=2$" + salt + "$" + key},
+		{"non-numeric block size", "$scrypt$ln=16,r=x,p=2$" + salt + "$" + key},
+		{"invalid salt", "$scrypt$ln=16,r=8,p=2$!!!$" + key},
+	}
+	hasher := NewPasswordHasher(PasswordSettings{Algorithm: PasswordArgon2id})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, _, err := hasher.Verify(testPassword, tt.hash)
+			if ok || !errors.Is(err, errMalformedHash) {
+				t.Errorf("Verify(%q) = %v, %v, want false, %v", tt.hash, ok, err, errMalformedHash)
+			}
+		})
+	}
+}
+
+func TestVerifyPepper(t *testing.T) {
+	peppered, err := NewPasswordHasher(PasswordSettings{Algorithm: PasswordArgon2id, Pepper: "pepper-1"}).Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpeppered, err := NewPasswordHasher(PasswordSettings{Algorithm: PasswordArgon2id}).Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		pepper          string
+		hash            string
+		wantOK          bool
+		wantNeedsRehash bool
+		wantErr         bool
+	}{
+		{name: "same pepper", pepper: "pepper-1", hash: peppered, wantOK: true},
+		{name: "rotated pepper", pepper: "pepper-2", hash: peppered, wantErr: true},
+		{name: "pepper removed", pepper: "", hash: peppered, wantErr: true},
+		{name: "pepper added", pepper: "pepper-1", hash: unpeppered, wantOK: true, wantNeedsRehash: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher := NewPasswordHasher(PasswordSettings{Algorithm: PasswordArgon2id, Pepper: tt.pepper})
+			ok, needsRehash, err := hasher.Verify(testPassword, tt.hash)
+			if (err != nil) != tt.wantErr || ok != tt.wantOK || needsRehash != tt.wantNeedsRehash {
+				t.Errorf("Verify() = %v, %v, %v, want %v, %v, error %v", ok, needsRehash, err, tt.wantOK, tt.wantNeedsRehash, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyNeedsRehash(t *testing.T) {
+	scryptHash, err := NewPasswordHasher(PasswordSettings{Algorithm: PasswordScrypt}).Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A scrypt hash with a lower cost than the one Hash uses now
+	hasher := NewPasswordHasher(PasswordSettings{Algorithm: PasswordScrypt, LegacySalt: testLegacySalt}).(*passwordHasher)
+	weak := &phcHash{algorithm: PasswordScrypt, params: map[string]string{"ln": "10", "r": "8", "p": "1"},
+		salt: []byte("0123456789abcdef")}
+	if weak.key, err = hasher.derive(testPassword, weak); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		settings        PasswordSettings
+		hash            string
+		password        string
+		wantOK          bool
+		wantNeedsRehash bool
+	}{
+		{"current scrypt", PasswordSettings{Algorithm: PasswordScrypt}, scryptHash, testPassword, true, false},
+		{"other algorithm", PasswordSettings{Algorithm: PasswordArgon2id}, scryptHash, testPassword, true, true},
+		{"other parameters", PasswordSettings{Algorithm: PasswordScrypt}, weak.String(), testPassword, true, true},
+		{"legacy", PasswordSettings{Algorithm: PasswordArgon2id, LegacySalt: testLegacySalt},
+			legacyHash(t, testPassword, testLegacySalt), testPassword, true, true},
+		{"legacy wrong password", PasswordSettings{Algorithm: PasswordArgon2id, LegacySalt: testLegacySalt},
+			legacyHash(t, testPassword, testLegacySalt), "Wrong-Horse-42", false, true},
+		{"legacy other salt", PasswordSettings{Algorithm: PasswordArgon2id, LegacySalt: "other-salt"},
+			legacyHash(t, testPassword, testLegacySalt), testPassword, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, needsRehash, err := NewPasswordHasher(tt.settings).Verify(tt.password, tt.hash)
+			if err != nil {
+				t.Fatalf("Verify() returned error: %v", err)
+			}
+			if ok != tt.wantOK || needsRehash != tt.wantNeedsRehash {
+				t.Errorf("Verify() = %v, %v, want %v, %v", ok, needsRehash, tt.wantOK, tt.wantNeedsRehash)
+			}
+		})
+	}
+}
+
+func TestLoginUpgradesPasswordHash(t *testing.T) {
+	tests := []struct {
+		name        string
+		storedHash  func(t *testing.T) string
+		wantUpgrade bool
+	}{
+		{"legacy hash", func(t *testing.T) string { return legacyHash(t, testPassword, testLegacySalt) }, true},
+		{"scrypt hash", func(t *testing.T) string {
+			hash, err := NewPasswordHasher(PasswordSettings{Algorithm: PasswordScrypt}).Hash(testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return hash
+		}, true},
+		{"current hash", func(t *testing.T) string {
+			hash, err := NewPasswordHasher(PasswordSettings{Algorithm: PasswordArgon2id}).Hash(testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return hash
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newPasswordTestDB(t)
+			customerRepo := repositories.NewCustomerRepository(db)
+			hasher := NewPasswordHasher(PasswordSettings{Algorithm: PasswordArgon2id, LegacySalt: testLegacySalt})
+			service := NewAuthService(customerRepo, repositories.NewOperatorRepository(db), repositories.NewAuthSessionRepository(db),
+				hasher, AuthSettings{Secret: "test-auth-secret-0123456789abcdef", AccessTTL: time.Minute, RefreshTTL: time.Hour})
+
+			stored := tt.storedHash(t)
+			customer := &models.Customer{ID: uuid.New(), Name: "Test Customer", Password: stored,
+				Email: "customer@example.com", Gender: models.Other, CreatedAt: time.Now()}
+			if err := customerRepo.CreateCustomer(customer); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := service.Login(customer.Email, "Wrong-Horse-42"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("Login(wrong) error = %v, want %v", err, ErrInvalidCredentials)
+			}
+			if got := storedPassword(t, customerRepo, customer.ID); got != stored {
+				t.Fatalf("failed login replaced the hash with %q", got)
+			}
+
+			if _, err := service.Login(customer.Email, testPassword); err != nil {
+				t.Fatalf("Login() returned error: %v", err)
+			}
+			upgraded := storedPassword(t, customerRepo, customer.ID)
+			if (upgraded != stored) != tt.wantUpgrade {
+				t.Fatalf("hash after login = %q, stored %q, want upgraded %v", upgraded, stored, tt.wantUpgrade)
+			}
+			ok, needsRehash, err := hasher.Verify(testPassword, upgraded)
+			if err != nil || !ok || needsRehash {
+				t.Errorf("Verify(hash after login) = %v, %v, %v, want true, false, nil", ok, needsRehash, err)
+			}
+		})
+	}
+}
+
+// storedPassword reads a customer's current password hash
+func storedPassword(t *testing.T, customerRepo repositories.CustomerRepository, id uuid.UUID) string {
+	t.Helper()
+	customer, err := customerRepo.GetCustomerWithPassword(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return customer.Password
+}
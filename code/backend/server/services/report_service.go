@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+type ReportService interface {
+	GetRevenueReport(query *models.ReportQuery) (*models.RevenueReport, error)
+	GetActiveCustomersReport(query *models.ReportQuery) (*models.CustomerCountReport, error)
+	GetNewCustomersReport(query *models.ReportQuery) (*models.CustomerCountReport, error)
+	GetTopCustomersReport(window *models.AggregationWindow, currency models.Currency, limit int) (*models.TopCustomersReport, error)
+	GetRevenueByGenderReport(window *models.AggregationWindow, currency models.Currency) (*models.GenderRevenueReport, error)
+}
+
+type reportService struct {
+	repo repositories.ReportRepository
+}
+
+// NewReportService creates a new instance of ReportService
+func NewReportService(repo repositories.ReportRepository) ReportService {
+	return &reportService{repo: repo}
+}
+
+// GetRevenueReport reports the net revenue of all customers per bucket and over the whole range
+func (rs *reportService) GetRevenueReport(query *models.ReportQuery) (*models.RevenueReport, error) {
+	buckets, err := splitReportBuckets(query)
+	if err != nil {
+		return nil, err
+	}
+	periods, err := rs.repo.GetRevenueByPeriod(buckets, query.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.RevenueReport{
+		From:     query.From,
+		To:       query.To,
+		TimeZone: query.Location.String(),
+		Bucket:   query.Bucket,
+		Currency: query.Currency,
+		Periods:  periods,
+	}
+	for _, period := range periods {
+		report.Total += period.Revenue
+	}
+	return report, nil
+}
+
+// GetActiveCustomersReport reports the number of customers making purchases per bucket
+func (rs *reportService) GetActiveCustomersReport(query *models.ReportQuery) (*models.CustomerCountReport, error) {
+	return rs.customerCountReport(query, rs.repo.GetActiveCustomersByPeriod)
+}
+
+// GetNewCustomersReport reports the number of customers registering per bucket
+func (rs *reportService) GetNewCustomersReport(query *models.ReportQuery) (*models.CustomerCountReport, error) {
+	return rs.customerCountReport(query, rs.repo.GetNewCustomersByPeriod)
+}
+
+// customerCountReport splits the query range into buckets and counts customers in them with count
+func (rs *reportService) customerCountReport(query *models.ReportQuery, count func([]models.TimeBucket) ([]*models.CustomerCountPeriod, error)) (*models.CustomerCountReport, error) {
+	buckets, err := splitReportBuckets(query)
+	if err != nil {
+		return nil, err
+	}
+	periods, err := count(buckets)
+	if err != nil {
+		return nil, err
+	}
+	return &models.CustomerCountReport{
+		From:     query.From,
+		To:       query.To,
+		TimeZone: query.Location.String(),
+		Bucket:   query.Bucket,
+		Periods:  periods,
+	}, nil
+}
+
+// GetTopCustomersReport ranks the customers with the highest net spend in the window
+func (rs *reportService) GetTopCustomersReport(window *models.AggregationWindow, currency models.Currency, limit int) (*models.TopCustomersReport, error) {
+	customers, err := rs.repo.GetTopCustomers(window, currency, limit)
+	if err != nil {
+		return nil, err
+	}
+	if customers == nil {
+		customers = []*models.TopCustomer{}
+	}
+	return &models.TopCustomersReport{Window: window, Currency: currency, Customers: customers}, nil
+}
+
+// GetRevenueByGenderReport splits the net revenue in the window by customer gender
+func (rs *reportService) GetRevenueByGenderReport(window *models.AggregationWindow, currency models.Currency) (*models.GenderRevenueReport, error) {
+	genders, err := rs.repo.GetRevenueByGender(window, currency)
+	if err != nil {
+		return nil, err
+	}
+	return &models.GenderRevenueReport{Window: window, Currency: currency, Genders: genders}, nil
+}
+
+// splitReportBuckets splits the query range into buckets, rejecting ranges with too many of them
+func splitReportBuckets(query *models.ReportQuery) ([]models.TimeBucket, error) {
+	buckets, err := models.SplitBuckets(query.From, query.To, query.Bucket, query.Location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAnalyticsQuery, err)
+	}
+	return buckets, nil
+}
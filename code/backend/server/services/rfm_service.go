@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/repositories"
+)
+
+// ErrRFMRecomputeRunning is returned when a recomputation is requested while another one is in progress
+var ErrRFMRecomputeRunning = errors.New("RFM scores are already being recomputed")
+
+type RFMService interface {
+	Recompute(window *models.AggregationWindow, trigger string) (*models.RFMSummary, error)
+	GetSummary() (*models.RFMSummary, error)
+	GetSegmentMembers(segment models.RFMSegment, pageSize int, cursor *models.RFMMemberCursor) (*models.RFMMemberPage, error)
+	RunSchedule(ctx context.Context, interval time.Duration, windowName string)
+}
+
+type rfmService struct {
+	repo repositories.RFMRepository
+}
+
+// NewRFMService creates a new instance of RFMService
+func NewRFMService(repo repositories.RFMRepository) RFMService {
+	return &rfmService{repo: repo}
+}
+
+// Recompute scores every customer with purchases in the window, in the base currency, replacing the stored
+// results, and returns the new summary. Only one recomputation runs at a time across all replicas.
+func (rs *rfmService) Recompute(window *models.AggregationWindow, trigger string) (*models.RFMSummary, error) {
+	run := &models.RFMRun{
+		Trigger:    trigger,
+		WindowName: window.Name,
+		WindowFrom: window.From,
+		WindowTo:   window.To,
+		Currency:   models.BaseCurrency,
+	}
+	replaced, err := rs.repo.ReplaceScores(run)
+	if err != nil {
+		return nil, err
+	}
+	if !replaced {
+		return nil, ErrRFMRecomputeRunning
+	}
+	return rs.GetSummary()
+}
+
+// GetSummary retrieves the latest run and the number of customers in every segment, including empty ones.
+// It returns gorm.ErrRecordNotFound if scores were never computed.
+func (rs *rfmService) GetSummary() (*models.RFMSummary, error) {
+	run, err := rs.repo.GetLatestRun()
+	if err != nil {
+		return nil, err
+	}
+	counts, err := rs.repo.GetSegmentCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	bySegment := make(map[models.RFMSegment]int64, len(counts))
+	for _, count := range counts {
+		bySegment[count.Segment] = count.Customers
+	}
+	summary := &models.RFMSummary{Run: run, Segments: make([]*models.RFMSegmentCount, len(models.RFMSegmentRules))}
+	for i, rule := range models.RFMSegmentRules {
+		summary.Segments[i] = &models.RFMSegmentCount{Segment: rule.Segment, Customers: bySegment[rule.Segment]}
+	}
+	return summary, nil
+}
+
+// GetSegmentMembers retrieves one page of the customers in a segment and the cursor for the next page
+func (rs *rfmService) GetSegmentMembers(segment models.RFMSegment, pageSize int, cursor *models.RFMMemberCursor) (*models.RFMMemberPage, error) {
+	// Fetch one extra row to find out whether another page follows
+	members, err := rs.repo.GetSegmentMembers(segment, pageSize+1, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.RFMMemberPage{Segment: segment, Items: members}
+	if len(members) > pageSize {
+		page.Items = members[:pageSize]
+		last := page.Items[len(page.Items)-1]
+		next := &models.RFMMemberCursor{Monetary: last.Monetary.String(), ID: last.CustomerID}
+		page.NextCursor = next.Encode()
+	}
+	if page.Items == nil {
+		page.Items = []*models.RFMMember{}
+	}
+	return page, nil
+}
+
+// RunSchedule recomputes the scores over the named window every interval until ctx is done. Every replica
+// runs the schedule; a replica skips its turn when another one recomputed within the last half interval
+// or is recomputing.
+func (rs *rfmService) RunSchedule(ctx context.Context, interval time.Duration, windowName string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			window, err := models.ParseAggregationWindow(windowName, "", "", "", now)
+			if err != nil {
+				log.Printf("Scheduled RFM recomputation skipped: %v", err)
+				continue
+			}
+			if latest, err := rs.repo.GetLatestRun(); err == nil && now.Sub(latest.CreatedAt) < interval/2 {
+				continue
+			}
+			summary, err := rs.Recompute(window, models.RFMTriggerSchedule)
+			if errors.Is(err, ErrRFMRecomputeRunning) {
+				continue
+			}
+			if err != nil {
+				log.Printf("Scheduled RFM recomputation failed: %v", err)
+				continue
+			}
+			log.Printf("Scheduled RFM recomputation scored %d customers", summary.Run.Customers)
+		}
+	}
+}
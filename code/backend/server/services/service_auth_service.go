@@ -0,0 +1,103 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+// ErrInvalidSignature is returned for signed requests whose credentials are malformed, stale, signed
+// with an unknown key or do not match the request
+var ErrInvalidSignature = errors.New("invalid request signature")
+
+type ServiceAuthService interface {
+	Authenticate(req *models.SignedRequest) (*models.Principal, error)
+}
+
+type serviceAuthService struct {
+	keys    map[string]string
+	maxSkew time.Duration
+}
+
+// NewServiceAuthService creates a new instance of ServiceAuthService accepting signatures of any of
+// the keys, so that the generator can switch to a new key before the old one is removed
+func NewServiceAuthService(keys map[string]string, maxSkew time.Duration) ServiceAuthService {
+	return &serviceAuthService{keys: keys, maxSkew: maxSkew}
+}
+
+// Authenticate verifies the request's HMAC-SHA256 signature and identifies the caller as the generator
+// service. Requests are rejected once their timestamp is more than the maximum skew away from now,
+// which limits replays to that window; the signature also covers the Idempotency-Key, so a replayed
+// batch is answered from the idempotency store instead of being inserted again.
+func (sas *serviceAuthService) Authenticate(req *models.SignedRequest) (*models.Principal, error) {
+	params, err := parseCredentials(req.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, ok := sas.keys[params["KeyId"]]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key", ErrInvalidSignature)
+	}
+	timestamp, err := strconv.ParseInt(params["Timestamp"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid timestamp", ErrInvalidSignature)
+	}
+	if skew := time.Since(time.Unix(timestamp, 0)); skew > sas.maxSkew || skew < -sas.maxSkew {
+		return nil, fmt.Errorf("%w: timestamp is outside the allowed clock skew", ErrInvalidSignature)
+	}
+	signature, err := hex.DecodeString(params["Signature"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+
+	expected := signRequest(secret, params["Timestamp"], req)
+	if !hmac.Equal(signature, expected) {
+		return nil, ErrInvalidSignature
+	}
+	return &models.Principal{Role: models.RoleGeneratorService, KeyID: params["KeyId"]}, nil
+}
+
+// parseCredentials splits service key credentials into their KeyId, Timestamp and Signature parameters
+func parseCredentials(credentials string) (map[string]string, error) {
+	params := make(map[string]string)
+	for _, param := range strings.Split(credentials, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed credentials", ErrInvalidSignature)
+		}
+		params[name] = value
+	}
+	for _, name := range []string{"KeyId", "Timestamp", "Signature"} {
+		if params[name] == "" {
+			return nil, fmt.Errorf("%w: missing %s", ErrInvalidSignature, name)
+		}
+	}
+	return params, nil
+}
+
+// signRequest computes the HMAC-SHA256 over the scheme, timestamp, method, URI, idempotency key and
+// body digest, one per line. The generator signs its requests the same way; both sides are tested against
+// the vectors in code/backend/testdata/service_auth_vectors.json, which must change with the string to sign.
+func signRequest(secret, timestamp string, req *models.SignedRequest) []byte {
+	digest := sha256.Sum256(req.Body)
+	stringToSign := strings.Join([]string{
+		models.ServiceAuthScheme,
+		timestamp,
+		req.Method,
+		req.URI,
+		req.IdempotencyKey,
+		hex.EncodeToString(digest[:]),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
+)
+
+// serviceAuthVector is a request signed by the generator, whose tests produce the same Authorization
+// headers with its signing code
+type serviceAuthVector struct {
+	Name           string `json:"name"`
+	KeyID          string `json:"key_id"`
+	Secret         string `json:"secret"`
+	Timestamp      int64  `json:"timestamp"`
+	Method         string `json:"method"`
+	URI            string `json:"uri"`
+	IdempotencyKey string `json:"idempotency_key"`
+	Body           string `json:"body"`
+	Authorization  string `json:"authorization"`
+}
+
+// loadServiceAuthVectors reads the signed requests shared with the generator's tests
+func loadServiceAuthVectors(t *testing.T) []serviceAuthVector {
+	t.Helper()
+	data, err := os.ReadFile("../../testdata/service_auth_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []serviceAuthVector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) == 0 {
+		t.Fatal("no service auth vectors")
+	}
+	return vectors
+}
+
+func TestServiceAuthAcceptsGeneratorVectors(t *testing.T) {
+	for _, v := range loadServiceAuthVectors(t) {
+		credentials, ok := strings.CutPrefix(v.Authorization, models.ServiceAuthScheme+" ")
+		if !ok {
+			t.Fatalf("%s: Authorization %q does not use the %s scheme", v.Name, v.Authorization, models.ServiceAuthScheme)
+		}
+		signed := models.SignedRequest{
+			Credentials:    credentials,
+			Method:         v.Method,
+			URI:            v.URI,
+			IdempotencyKey: v.IdempotencyKey,
+			Body:           []byte(v.Body),
+		}
+		// The vectors were signed at fixed times, so the allowed skew reaches back to them
+		skew := time.Since(time.Unix(v.Timestamp, 0)) + time.Hour
+		service := NewServiceAuthService(map[string]string{v.KeyID: v.Secret}, skew)
+
+		tests := []struct {
+			name    string
+			modify  func(req *models.SignedRequest)
+			wantErr bool
+		}{
+			{name: "as signed", modify: func(req *models.SignedRequest) {}},
+			{name: "other method", modify: func(req *models.SignedRequest) { req.Method = "PUT" }, wantErr: true},
+			{name: "other URI", modify: func(req *models.SignedRequest) { req.URI += "/other" }, wantErr: true},
+			{name: "other idempotency key", modify: func(req *models.SignedRequest) { req.IdempotencyKey = "other" }, wantErr: true},
+			{name: "other body", modify: func(req *models.SignedRequest) { req.Body = append(req.Body, ' ') }, wantErr: true},
+			{name: "unknown key", modify: func(req *models.SignedRequest) {
+				req.Credentials = strings.Replace(req.Credentials, "KeyId="+v.KeyID, "KeyId=unknown", 1)
+			}, wantErr: true},
+		}
+		for _, tt := range tests {
+			t.Run(v.Name+"/"+tt.name, func(t *testing.T) {
+				req := signed
+				req.Body = append([]byte(nil), signed.Body...)
+				tt.modify(&req)
+
+				principal, err := service.Authenticate(&req)
+				if tt.wantErr {
+					if !errors.Is(err, ErrInvalidSignature) {
+						t.Fatalf("Authenticate() error = %v, want %v", err, ErrInvalidSignature)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Authenticate() returned error: %v", err)
+				}
+				if principal.Role != models.RoleGeneratorService || principal.KeyID != v.KeyID {
+					t.Errorf("Authenticate() = %+v, want the generator service with key %s", principal, v.KeyID)
+				}
+			})
+		}
+	}
+}
+
+func TestServiceAuthRejectsStaleVectors(t *testing.T) {
+	for _, v := range loadServiceAuthVectors(t) {
+		service := NewServiceAuthService(map[string]string{v.KeyID: v.Secret}, 5*time.Minute)
+		credentials := strings.TrimPrefix(v.Authorization, models.ServiceAuthScheme+" ")
+		_, err := service.Authenticate(&models.SignedRequest{
+			Credentials:    credentials,
+			Method:         v.Method,
+			URI:            v.URI,
+			IdempotencyKey: v.IdempotencyKey,
+			Body:           []byte(v.Body),
+		})
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: Authenticate() error = %v, want %v for a timestamp outside the skew", v.Name, err, ErrInvalidSignature)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"sort"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/xzz8868/titansoft-pre-test/code/backend/server/models"
@@ -9,98 +12,484 @@ import (
 )
 
 type TransactionService interface {
-	GetTransactionsByCustomerID(id uuid.UUID) ([]*models.TransactionDTO, error)
-	GetDateRangeTransactionsByCustomerID(customerID uuid.UUID, from string, to string) ([]*models.TransactionDTO, error)
-	CreateMultiTransactions(transactions []*models.TransactionDTO) error
+	QueryTransactions(query *models.TransactionQuery) (*models.TransactionPage, error)
+	CreateMultiTransactions(transactions []*models.TransactionDTO, mode models.BatchMode, actor string) (*models.TransactionBatchResult, error)
+	CorrectTransaction(id uuid.UUID, correction *models.TransactionCorrection, actor string) (*models.CorrectionResult, error)
+	GetTransactionHistory(id uuid.UUID) ([]*models.TransactionAudit, error)
+	VerifyLedgers() (*models.LedgerReport, error)
+	RekeyLedgers() (*models.LedgerReport, error)
+	CountUnkeyedLedgers() (int, error)
 	// CreateTransaction(transaction *models.Transaction) error
-	// UpdateTransaction(transaction *models.Transaction) error
-	// DeleteTransaction(id uuid.UUID) error
 }
 
+var (
+	// ErrInvalidCorrection is wrapped by the errors returned for correction requests that fail validation
+	ErrInvalidCorrection = errors.New("invalid correction")
+	// ErrCorrectionConflict is wrapped by the errors returned when a transaction cannot be corrected in its current state
+	ErrCorrectionConflict = errors.New("transaction cannot be corrected")
+)
+
 type transactionService struct {
-	repo repositories.TransactionRepository
+	repo         repositories.TransactionRepository
+	customerRepo repositories.CustomerRepository
+	ledgerKey    *models.LedgerKey
 }
 
-// Constructor for creating a new TransactionService instance
-func NewTransactionService(repo repositories.TransactionRepository) TransactionService {
-	return &transactionService{repo}
+// Constructor for creating a new TransactionService instance that verifies the ledgers under ledgerKey
+func NewTransactionService(repo repositories.TransactionRepository, customerRepo repositories.CustomerRepository, ledgerKey *models.LedgerKey) TransactionService {
+	return &transactionService{repo: repo, customerRepo: customerRepo, ledgerKey: ledgerKey}
 }
 
-// Retrieves all transactions for a given customer, sorts them by time, and maps to DTOs
-func (cs *transactionService) GetTransactionsByCustomerID(customerID uuid.UUID) ([]*models.TransactionDTO, error) {
-	transactions, err := cs.repo.GetTransactionsByCustomerID(customerID)
+// Retrieves one page of a customer's transactions with the cursors of the adjacent pages and the
+// page subtotal. Sequences are the lifetime purchase counts, not positions within the page.
+// Amounts are also reported in the query currency unless it is empty.
+func (cs *transactionService) QueryTransactions(query *models.TransactionQuery) (*models.TransactionPage, error) {
+	// Fetch one extra row to find out whether another page follows in the scan direction
+	pageQuery := *query
+	pageQuery.PageSize = query.PageSize + 1
+	transactions, err := cs.repo.QueryTransactions(&pageQuery)
 	if err != nil {
 		return nil, err
 	}
 
-	cs.sortTransactionsByTime(transactions)
-	return cs.mapTransactionsToDTOs(transactions), nil
-}
+	more := len(transactions) > query.PageSize
+	if more {
+		transactions = transactions[:query.PageSize]
+	}
+	backwards := query.Cursor != nil && query.Cursor.Direction == models.CursorPrev
+	if backwards {
+		// A prev page is scanned in reverse; restore the requested order
+		for i, j := 0, len(transactions)-1; i < j; i, j = i+1, j-1 {
+			transactions[i], transactions[j] = transactions[j], transactions[i]
+		}
+	}
 
-// Retrieves transactions within a date range for a customer, sorts them by time, and maps to DTOs
-func (cs *transactionService) GetDateRangeTransactionsByCustomerID(customerID uuid.UUID, from string, to string) ([]*models.TransactionDTO, error) {
-	transactions, err := cs.repo.GetDateRangeTransactionsByCustomerID(customerID, from, to)
-	if err != nil {
-		return nil, err
+	page := &models.TransactionPage{
+		Items:    cs.mapTransactionsToDTOs(transactions, query.Currency),
+		Currency: query.Currency,
 	}
+	page.Subtotal, page.SubtotalCurrency = pageSubtotal(transactions, query.Currency)
+	if len(transactions) == 0 {
+		return page, nil
+	}
+	// Going forwards there is a previous page whenever we came from a cursor; going backwards
+	// there is always a next page, the one the cursor came from
+	hasNext, hasPrev := more, query.Cursor != nil
+	if backwards {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		page.NextCursor = transactionCursor(query, models.CursorNext, transactions[len(transactions)-1]).Encode()
+	}
+	if hasPrev {
+		page.PrevCursor = transactionCursor(query, models.CursorPrev, transactions[0]).Encode()
+	}
+	return page, nil
+}
 
-	cs.sortTransactionsByTime(transactions)
-	return cs.mapTransactionsToDTOs(transactions), nil
+// transactionCursor returns a cursor continuing the query from the given transaction in the given direction
+func transactionCursor(query *models.TransactionQuery, direction models.CursorDirection, txn *models.Transaction) *models.TransactionCursor {
+	return &models.TransactionCursor{
+		Order:     query.Order,
+		Direction: direction,
+		Time:      txn.Time,
+		ID:        txn.ID,
+		Currency:  query.Currency,
+	}
 }
 
-// Helper function to sort transactions by time in ascending order
-func (cs *transactionService) sortTransactionsByTime(transactions []*models.Transaction) {
-	sort.Slice(transactions, func(i, j int) bool {
-		return transactions[i].Time.Before(transactions[j].Time)
-	})
+// pageSubtotal nets the amounts of a page in the reporting currency, or in the transactions' own
+// currency when none is given. Without a reporting currency the subtotal of a page mixing
+// currencies is nil.
+func pageSubtotal(transactions []*models.Transaction, currency models.Currency) (*models.Money, models.Currency) {
+	var subtotal models.Money
+	for _, txn := range transactions {
+		amount := txn.Amount
+		if currency != "" {
+			if txn.ConvertedAmount == nil {
+				return nil, ""
+			}
+			amount = *txn.ConvertedAmount
+		} else if txn.Currency != transactions[0].Currency {
+			return nil, ""
+		}
+		subtotal += txn.Type.Net(amount)
+	}
+	if currency == "" {
+		currency = models.BaseCurrency
+		if len(transactions) > 0 {
+			currency = transactions[0].Currency
+		}
+	}
+	return &subtotal, currency
 }
 
-// Helper function to map transaction models to TransactionDTOs and assign sequences
-func (cs *transactionService) mapTransactionsToDTOs(transactions []*models.Transaction) []*models.TransactionDTO {
+// Helper function to map transaction models to TransactionDTOs with the sequences computed by the repository
+func (cs *transactionService) mapTransactionsToDTOs(transactions []*models.Transaction, currency models.Currency) []*models.TransactionDTO {
 	transactionDTOs := make([]*models.TransactionDTO, len(transactions))
 	for i, txn := range transactions {
-		transactionDTOs[i] = &models.TransactionDTO{
-			ID:         txn.ID,
-			CustomerID: txn.CustomerID,
-			Amount:     txn.Amount,
-			Time:       txn.Time,
-			Sequence:   i + 1, // Sequence starts from 1 and increments
-		}
+		transactionDTOs[i] = toTransactionDTO(txn, currency)
 	}
 	return transactionDTOs
 }
 
-// Creates multiple transactions by mapping DTOs to ORM models and saving them in the repository
-func (cs *transactionService) CreateMultiTransactions(transactions []*models.TransactionDTO) error {
-	var transactionORMs []*models.Transaction
+// Helper function to map a transaction model to a TransactionDTO
+func toTransactionDTO(txn *models.Transaction, currency models.Currency) *models.TransactionDTO {
+	return &models.TransactionDTO{
+		ID:                    txn.ID,
+		CustomerID:            txn.CustomerID,
+		Type:                  txn.Type,
+		OriginalTransactionID: txn.OriginalTransactionID,
+		Amount:                txn.Amount,
+		Currency:              txn.Currency,
+		Time:                  txn.Time,
+		Sequence:              txn.Sequence,
+		ConvertedAmount:       txn.ConvertedAmount,
+		ReportingCurrency:     currency,
+	}
+}
+
+// Creates multiple transactions by validating each DTO, mapping the valid ones to ORM models and
+// saving them in the repository. In all-or-nothing mode nothing is saved when any item is rejected;
+// in best-effort mode the valid items are saved and the rejected ones are reported.
+func (cs *transactionService) CreateMultiTransactions(transactions []*models.TransactionDTO, mode models.BatchMode, actor string) (*models.TransactionBatchResult, error) {
+	seen := make(map[uuid.UUID]bool)
+	var customerIDs, originalIDs, clientIDs []uuid.UUID
 	for _, dto := range transactions {
-		
+		if dto.ID != uuid.Nil {
+			clientIDs = append(clientIDs, dto.ID)
+		}
+		if !seen[dto.CustomerID] {
+			seen[dto.CustomerID] = true
+			customerIDs = append(customerIDs, dto.CustomerID)
+		}
+		if dto.OriginalTransactionID != nil && !seen[*dto.OriginalTransactionID] {
+			seen[*dto.OriginalTransactionID] = true
+			originalIDs = append(originalIDs, *dto.OriginalTransactionID)
+		}
+	}
+	registrationTimes, err := cs.customerRepo.GetRegistrationTimes(customerIDs)
+	if err != nil {
+		return nil, err
+	}
+	originals, err := cs.repo.GetTransactionsByIDs(originalIDs)
+	if err != nil {
+		return nil, err
+	}
+	// Items whose client-supplied ID is already stored were saved by an earlier request
+	stored, err := cs.repo.GetTransactionsByIDs(clientIDs)
+	if err != nil {
+		return nil, err
+	}
+	// Refunded amounts grow as the batch is validated, so items of one batch cannot jointly over-refund
+	refunded, err := cs.repo.GetRefundedAmounts(originalIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.TransactionBatchResult{Mode: mode, Rejections: []models.TransactionRejection{}}
+	reject := func(index int, reason models.RejectReason, message string) {
+		result.Rejections = append(result.Rejections, models.TransactionRejection{Index: index, Reason: reason, Message: message})
+	}
+
+	// Duplicates are recognised by client-supplied ID only: identical purchases of one customer at the
+	// same time are legitimate and are all stored
+	firstIndex := make(map[uuid.UUID]int)
+
+	var transactionORMs []*models.Transaction
+	var indexes []int
+	for i, dto := range transactions {
+		registeredAt, known := registrationTimes[dto.CustomerID]
+		txnType := dto.Type
+		if txnType == "" {
+			txnType = models.TypePurchase
+		}
+		var original *models.Transaction
+		if dto.OriginalTransactionID != nil {
+			original = originals[*dto.OriginalTransactionID]
+		}
+		// Refunds and reversals are in the currency of the purchase unless stated otherwise
+		currency, validCurrency := models.ParseCurrency(string(dto.Currency))
+		if dto.Currency == "" && original != nil {
+			currency = original.Currency
+		}
+		first, repeated := firstIndex[dto.ID]
+		_, exists := stored[dto.ID]
+
+		switch {
+		case !known:
+			reject(i, models.ReasonUnknownCustomer, fmt.Sprintf("customer %s does not exist", dto.CustomerID))
+			continue
+		case !txnType.IsValid():
+			reject(i, models.ReasonInvalidType, fmt.Sprintf("unsupported transaction type: %s", dto.Type))
+			continue
+		case txnType == models.TypeAdjustment && dto.Amount == 0:
+			reject(i, models.ReasonZeroAmount, "adjustment amount must not be zero")
+			continue
+		case txnType != models.TypeAdjustment && dto.Amount <= 0:
+			reject(i, models.ReasonNonPositiveAmount, "amount must be positive")
+			continue
+		case dto.Amount > models.MaxTransactionAmount || dto.Amount < -models.MaxTransactionAmount:
+			reject(i, models.ReasonAmountOutOfRange, fmt.Sprintf("amount must not exceed %s", models.MaxTransactionAmount))
+			continue
+		case !validCurrency:
+			reject(i, models.ReasonInvalidCurrency, fmt.Sprintf("unsupported currency: %s", dto.Currency))
+			continue
+		case dto.Time.IsZero():
+			reject(i, models.ReasonZeroTime, "time is required")
+			continue
+		case dto.Time.Before(registeredAt):
+			reject(i, models.ReasonBeforeRegistration, fmt.Sprintf("customer registered at %s", registeredAt.Format(time.RFC3339)))
+			continue
+		case dto.ID != uuid.Nil && repeated:
+			reject(i, models.ReasonDuplicate, fmt.Sprintf("id %s is also used by item %d", dto.ID, first))
+			continue
+		case exists:
+			reject(i, models.ReasonDuplicate, fmt.Sprintf("transaction %s already exists", dto.ID))
+			continue
+		}
+		if reason, message := checkOriginal(dto, txnType, currency, original, refunded); reason != "" {
+			reject(i, reason, message)
+			continue
+		}
+		id := dto.ID
+		if id == uuid.Nil {
+			id = uuid.New()
+		} else {
+			firstIndex[id] = i
+		}
+		if original != nil {
+			refunded[original.ID] += dto.Amount
+		}
+
 		// Map TransactionDTO to Transaction ORM model
 		transactionORM := &models.Transaction{
-			ID:         uuid.New(),
-			CustomerID: dto.CustomerID,
-			Amount:     dto.Amount,
-			Time:       dto.Time,
+			ID:                    id,
+			CustomerID:            dto.CustomerID,
+			Type:                  txnType,
+			OriginalTransactionID: dto.OriginalTransactionID,
+			Amount:                dto.Amount,
+			Currency:              currency,
+			Time:                  dto.Time.Truncate(time.Second), // stored and hashed in whole seconds
 		}
-		
+
 		transactionORMs = append(transactionORMs, transactionORM)
+		indexes = append(indexes, i)
 	}
-	
-	// Call the Repository layer to save transactions
-	return cs.repo.CreateMultiTransactions(transactionORMs)
+
+	if mode == models.AllOrNothing {
+		if len(result.Rejections) == 0 && len(transactionORMs) > 0 {
+			// Call the Repository layer to save transactions in a single database transaction
+			err := cs.repo.CreateMultiTransactions(transactionORMs, actor)
+			// Requests committed since the validation above may have refunded the same purchases
+			var exceeds *repositories.ExceedsOriginalError
+			if errors.As(err, &exceeds) {
+				reject(indexes[exceeds.Index], models.ReasonExceedsOriginal, exceeds.Error())
+			} else if err != nil {
+				return nil, err
+			} else {
+				result.Accepted = len(transactionORMs)
+			}
+		}
+		result.Rejected = len(result.Rejections)
+		return result, nil
+	}
+
+	// Save what passed validation, reporting rows the database still refused
+	failures := cs.repo.CreateTransactionsPartially(transactionORMs, actor)
+	for i, index := range indexes {
+		err, failed := failures[i]
+		var exceeds *repositories.ExceedsOriginalError
+		if errors.As(err, &exceeds) {
+			reject(index, models.ReasonExceedsOriginal, err.Error())
+		} else if failed {
+			reject(index, models.ReasonInsertFailed, err.Error())
+		}
+	}
+	sort.Slice(result.Rejections, func(i, j int) bool {
+		return result.Rejections[i].Index < result.Rejections[j].Index
+	})
+	result.Accepted = len(transactionORMs) - len(failures)
+	result.Rejected = len(result.Rejections)
+	return result, nil
+}
+
+// checkOriginal validates the link between a transaction and the purchase it refunds or reverses.
+// It returns an empty reason if the link is valid.
+func checkOriginal(dto *models.TransactionDTO, txnType models.TransactionType, currency models.Currency, original *models.Transaction, refunded map[uuid.UUID]models.Money) (models.RejectReason, string) {
+	if !txnType.LinksOriginal() {
+		if dto.OriginalTransactionID != nil {
+			return models.ReasonInvalidOriginal, "only refunds and reversals reference an original transaction"
+		}
+		return "", ""
+	}
+
+	switch {
+	case dto.OriginalTransactionID == nil:
+		return models.ReasonInvalidOriginal, fmt.Sprintf("a %s requires original_transaction_id", txnType)
+	case original == nil:
+		return models.ReasonUnknownOriginal, fmt.Sprintf("transaction %s does not exist", *dto.OriginalTransactionID)
+	case original.CustomerID != dto.CustomerID:
+		return models.ReasonInvalidOriginal, "original transaction belongs to another customer"
+	case original.Type != models.TypePurchase:
+		return models.ReasonInvalidOriginal, fmt.Sprintf("only purchases can be refunded or reversed, original is a %s", original.Type)
+	case currency != original.Currency:
+		return models.ReasonInvalidOriginal, fmt.Sprintf("currency must match the original transaction's %s", original.Currency)
+	case dto.Time.Before(original.Time):
+		return models.ReasonInvalidOriginal, "must not precede the original transaction"
+	case txnType == models.TypeReversal && dto.Amount != original.Amount:
+		return models.ReasonInvalidOriginal, fmt.Sprintf("a reversal must be for the full original amount of %s", original.Amount)
+	case refunded[original.ID]+dto.Amount > original.Amount:
+		return models.ReasonExceedsOriginal, fmt.Sprintf("%s already refunded of the original %s", refunded[original.ID], original.Amount)
+	}
+	return "", ""
 }
 
-// Creates a new transaction record in the repository
-// func (cs *transactionService) CreateTransaction(transaction *models.Transaction) error {
-// 	return cs.repo.Create(transaction)
-// }
+// Corrects a purchase without rewriting it: the purchase is reversed in full and a replacement
+// carrying the corrected values is appended, both audited with the reason given.
+func (cs *transactionService) CorrectTransaction(id uuid.UUID, correction *models.TransactionCorrection, actor string) (*models.CorrectionResult, error) {
+	original, err := cs.repo.GetTransactionByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if correction.Reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidCorrection)
+	}
+	if original.Type != models.TypePurchase {
+		return nil, fmt.Errorf("%w: only purchases can be corrected, this is a %s", ErrCorrectionConflict, original.Type)
+	}
+	// Rejects early what the repository rejects again under the ledger lock below
+	refunded, err := cs.repo.GetRefundedAmounts([]uuid.UUID{original.ID})
+	if err != nil {
+		return nil, err
+	}
+	if refunded[original.ID] > 0 {
+		return nil, fmt.Errorf("%w: %s has already been refunded or reversed", ErrCorrectionConflict, refunded[original.ID])
+	}
 
-// Updates an existing transaction record in the repository
-// func (cs *transactionService) UpdateTransaction(transaction *models.Transaction) error {
-// 	return cs.repo.Update(transaction)
-// }
+	replacement := &models.Transaction{
+		ID:         uuid.New(),
+		CustomerID: original.CustomerID,
+		Type:       models.TypePurchase,
+		Amount:     original.Amount,
+		Currency:   original.Currency,
+		Time:       original.Time,
+	}
+	if correction.Amount != nil {
+		if *correction.Amount <= 0 || *correction.Amount > models.MaxTransactionAmount {
+			return nil, fmt.Errorf("%w: amount must be positive and must not exceed %s", ErrInvalidCorrection, models.MaxTransactionAmount)
+		}
+		replacement.Amount = *correction.Amount
+	}
+	if correction.Currency != "" {
+		currency, ok := models.ParseCurrency(string(correction.Currency))
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported currency: %s", ErrInvalidCorrection, correction.Currency)
+		}
+		replacement.Currency = currency
+	}
+	if correction.Time != nil {
+		registrationTimes, err := cs.customerRepo.GetRegistrationTimes([]uuid.UUID{original.CustomerID})
+		if err != nil {
+			return nil, err
+		}
+		if correction.Time.IsZero() || correction.Time.Before(registrationTimes[original.CustomerID]) {
+			return nil, fmt.Errorf("%w: time must not precede the customer's registration", ErrInvalidCorrection)
+		}
+		replacement.Time = correction.Time.Truncate(time.Second)
+	}
+	if replacement.Amount == original.Amount && replacement.Currency == original.Currency && replacement.Time.Equal(original.Time) {
+		return nil, fmt.Errorf("%w: the correction does not change anything", ErrInvalidCorrection)
+	}
 
-// Deletes a transaction by its ID in the repository
-// func (cs *transactionService) DeleteTransaction(id uuid.UUID) error {
-// 	return cs.repo.Delete(id)
-// }
+	// The reversal is dated like the purchase so that totals of that period are corrected
+	reversal := &models.Transaction{
+		ID:                    uuid.New(),
+		CustomerID:            original.CustomerID,
+		Type:                  models.TypeReversal,
+		OriginalTransactionID: &original.ID,
+		Amount:                original.Amount,
+		Currency:              original.Currency,
+		Time:                  original.Time,
+	}
+	// The full reversal exceeds the purchase if it was refunded, reversed or corrected in the meantime
+	err = cs.repo.AppendCorrection(original, reversal, replacement, actor, correction.Reason)
+	var exceeds *repositories.ExceedsOriginalError
+	if errors.As(err, &exceeds) {
+		return nil, fmt.Errorf("%w: %s has already been refunded or reversed", ErrCorrectionConflict, exceeds.Refunded)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CorrectionResult{
+		Reversal:    toTransactionDTO(reversal, ""),
+		Replacement: toTransactionDTO(replacement, ""),
+	}, nil
+}
+
+// Retrieves the audit trail of a transaction, including its refunds, reversals and corrections
+func (cs *transactionService) GetTransactionHistory(id uuid.UUID) ([]*models.TransactionAudit, error) {
+	if _, err := cs.repo.GetTransactionByID(id); err != nil {
+		return nil, err
+	}
+	return cs.repo.GetTransactionHistory(id)
+}
+
+// Recomputes the keyed hash chain of every customer's ledger and reports entries that were changed,
+// removed or inserted outside the application, including entries removed from the end, which no longer
+// match the ledger's signed head
+func (cs *transactionService) VerifyLedgers() (*models.LedgerReport, error) {
+	customerIDs, err := cs.repo.GetLedgerCustomerIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.LedgerReport{Violations: []models.LedgerViolation{}}
+	for _, customerID := range customerIDs {
+		ledger, err := cs.repo.GetLedger(customerID)
+		if err != nil {
+			return nil, err
+		}
+		head, err := cs.repo.GetLedgerHead(customerID)
+		if err != nil {
+			return nil, err
+		}
+		report.CustomersChecked++
+		report.EntriesChecked += len(ledger)
+		report.Violations = append(report.Violations, models.CheckChain(customerID, ledger, cs.ledgerKey)...)
+		report.Violations = append(report.Violations, models.CheckHead(customerID, ledger, head, cs.ledgerKey)...)
+	}
+	return report, nil
+}
+
+// Rechains the ledgers hashed before hashes were keyed under the ledger key and signs their heads.
+// Ledgers whose unkeyed chain is broken are left as they are and reported.
+func (cs *transactionService) RekeyLedgers() (*models.LedgerReport, error) {
+	customerIDs, err := cs.repo.GetUnsignedLedgerCustomerIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.LedgerReport{Violations: []models.LedgerViolation{}}
+	for _, customerID := range customerIDs {
+		violations, err := cs.repo.SignLegacyLedger(customerID)
+		if err != nil {
+			return nil, err
+		}
+		report.CustomersChecked++
+		report.Violations = append(report.Violations, violations...)
+	}
+	return report, nil
+}
+
+// Counts the ledgers still hashed without the ledger key, whose customers cannot get new transactions
+// until RekeyLedgers signs them
+func (cs *transactionService) CountUnkeyedLedgers() (int, error) {
+	customerIDs, err := cs.repo.GetUnsignedLedgerCustomerIDs()
+	if err != nil {
+		return 0, err
+	}
+	return len(customerIDs), nil
+}
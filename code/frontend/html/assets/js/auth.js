@@ -0,0 +1,40 @@
+// Operator session shared by the pages talking to the backend server
+(function() {
+    const SERVER_BASE_URL = window._config.SERVER_BASE_URL || 'http://localhost:8080';
+    const accessToken = sessionStorage.getItem('access_token');
+
+    // Every page but the login page needs a signed-in operator
+    if (!accessToken) {
+        window.location.href = 'login.html';
+        return;
+    }
+
+    // Authenticate every request to the backend server with the operator's access token
+    $.ajaxSetup({
+        beforeSend: function(xhr, settings) {
+            if (settings.url.startsWith(SERVER_BASE_URL)) {
+                xhr.setRequestHeader('Authorization', `Bearer ${accessToken}`);
+            }
+        }
+    });
+
+    // Sign in again once the token has expired or the session was revoked
+    $(document).ajaxError(function(event, xhr) {
+        if (xhr.status === 401) {
+            sessionStorage.removeItem('access_token');
+            window.location.href = 'login.html';
+        }
+    });
+
+    // Revoke the session on logout
+    $(document).on('click', '#logout-button', function() {
+        $.ajax({
+            url: `${SERVER_BASE_URL}/auth/logout`,
+            method: 'POST',
+            complete: function() {
+                sessionStorage.removeItem('access_token');
+                window.location.href = 'login.html';
+            }
+        });
+    });
+})();
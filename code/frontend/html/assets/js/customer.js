@@ -19,17 +19,20 @@ $(document).ready(function() {
         }
     });
 
-    // Toggle password fields visibility and requirement based on checkbox
-    $('#change-password').change(function() {
-        if ($(this).is(':checked')) {
-            $('#password-fields').show();
-            $('#new-password').attr('required', true);
-            $('#confirm-password').attr('required', true);
-        } else {
-            $('#password-fields').hide();
-            $('#new-password').removeAttr('required').val('');
-            $('#confirm-password').removeAttr('required').val('');
-        }
+    // Send the customer a link to set a new password; operators never set passwords themselves
+    $('#send-reset-link').click(function() {
+        $.ajax({
+            url: `${SERVER_BASE_URL}/auth/password/forgot`,
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({ email: $('#email').val() }),
+            success: function() {
+                alert('已寄送重設密碼連結');
+            },
+            error: function() {
+                alert('寄送重設密碼連結失敗');
+            }
+        });
     });
 
     // Handle form submission for updating customer details
@@ -49,45 +52,8 @@ $(document).ready(function() {
             contentType: 'application/json',
             data: JSON.stringify(updatedCustomer),
             success: function() {
-                // If password change is requested, validate and send password update request
-                if ($('#change-password').is(':checked')) {
-                    const newPassword = $('#new-password').val();
-                    const confirmPassword = $('#confirm-password').val();
-
-                    // Password validation
-                    if (newPassword.length < 8) {
-                        alert('密碼至少需要8個字元');
-                        return;
-                    }
-
-                    if (newPassword !== confirmPassword) {
-                        alert('新密碼和確認密碼不一致');
-                        return;
-                    }
-
-                    const passwordData = {
-                        password: newPassword,
-                        confirm_password: confirmPassword
-                    };
-
-                    // Send update request for customer password
-                    $.ajax({
-                        url: `${SERVER_BASE_URL}/customers/password/${customerId}`,
-                        method: 'PUT',
-                        contentType: 'application/json',
-                        data: JSON.stringify(passwordData),
-                        success: function() {
-                            alert('客戶資料和密碼已更新');
-                            window.location.href = 'index.html';
-                        },
-                        error: function() {
-                            alert('密碼更新失敗');
-                        }
-                    });
-                } else {
-                    alert('客戶資料已更新');
-                    window.location.href = 'index.html';
-                }
+                alert('客戶資料已更新');
+                window.location.href = 'index.html';
             },
             error: function() {
                 alert('更新失敗');
@@ -0,0 +1,25 @@
+$(document).ready(function() {
+    const SERVER_BASE_URL = window._config.SERVER_BASE_URL || 'http://localhost:8080';
+
+    // Sign in as an operator and keep the access token for this browser tab
+    $('#login-form').submit(function(event) {
+        event.preventDefault();
+
+        $.ajax({
+            url: `${SERVER_BASE_URL}/auth/operators/login`,
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({
+                email: $('#email').val(),
+                password: $('#password').val()
+            }),
+            success: function(tokens) {
+                sessionStorage.setItem('access_token', tokens.access_token);
+                window.location.href = 'index.html';
+            },
+            error: function() {
+                alert('登入失敗，請確認電子郵件與密碼!');
+            }
+        });
+    });
+});
@@ -8,36 +8,60 @@ $(document).ready(function() {
         'other': '其他'
     };
 
-    // Fetch customer list from backend
-    $.ajax({
-        url: `${SERVER_BASE_URL}/customers`,
-        method: 'GET',
-        success: function(customers) {
-            customers.forEach(function(customer) {
-                let totalAmount = customer.total_transaction_amount || 0;
-
-                // Insert customer data into table
-                $('#customer-table-body').append(
-                    `<tr>
-                        <td>${customer.name}</td>
-                        <td>${customer.email}</td>
-                        <td>${genderMap[customer.gender]}</td>
-                        <td>${totalAmount.toFixed(2)}</td>
-                        <td>
-                            <a href="customer.html?id=${customer.id}" class="btn btn-sm btn-info">查看/編輯</a>
-                            <a href="transactions.html?id=${customer.id}" class="btn btn-sm btn-secondary">查看交易</a>
-                        </td>
-                    </tr>`
-                );
-            });
-            // Update total customer count
-            $('#customer-count').text('客戶總數：' + customers.length);
-        },
-        error: function(error) {
-            console.error('Failed to fetch customer list:', error);
-        }
+    // Cursor for the next page of customers, empty when no more pages
+    let nextCursor = '';
+
+    // Initial load of the first page
+    loadCustomers();
+
+    // Load the next page when the button is clicked
+    $('#load-more-button').click(function() {
+        loadCustomers();
     });
 
+    // Fetch a page of customers from backend
+    function loadCustomers() {
+        let params = { page_size: 50 };
+        if (nextCursor) {
+            params.cursor = nextCursor;
+        }
+
+        $.ajax({
+            url: `${SERVER_BASE_URL}/customers`,
+            method: 'GET',
+            data: params,
+            success: function(page) {
+                page.items.forEach(function(customer) {
+                    // Amounts arrive as decimal strings with two places
+                    let totalAmount = customer.total_transaction_amount || '0.00';
+
+                    // Insert customer data into table
+                    $('#customer-table-body').append(
+                        `<tr>
+                            <td>${customer.name}</td>
+                            <td>${customer.email}</td>
+                            <td>${genderMap[customer.gender]}</td>
+                            <td>${totalAmount} ${customer.currency || ''}</td>
+                            <td>
+                                <a href="customer.html?id=${customer.id}" class="btn btn-sm btn-info">查看/編輯</a>
+                                <a href="transactions.html?id=${customer.id}" class="btn btn-sm btn-secondary">查看交易</a>
+                            </td>
+                        </tr>`
+                    );
+                });
+                // Update total customer count
+                $('#customer-count').text('客戶總數：' + page.total_count);
+
+                // Show the load more button only when another page exists
+                nextCursor = page.next_cursor;
+                $('#load-more-button').toggle(!!nextCursor);
+            },
+            error: function(error) {
+                console.error('Failed to fetch customer list:', error);
+            }
+        });
+    }
+
     // Handle reset button click
     $('#reset_button').click(function() {
         if (confirm('確定要清除所有資料嗎？')) {
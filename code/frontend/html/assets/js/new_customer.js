@@ -37,7 +37,12 @@ $(document).ready(function() {
                 alert('客戶新增成功');
                 window.location.href = 'index.html'; // Redirect on success
             },
-            error: function() {
+            error: function(xhr) {
+                // Passwords failing the server's strength rules are rejected with the reason
+                if (xhr.status === 400 && xhr.responseJSON) {
+                    alert(`新增失敗：${xhr.responseJSON.error}`);
+                    return;
+                }
                 alert('新增失敗');
             }
         });
@@ -0,0 +1,36 @@
+$(document).ready(function() {
+    const SERVER_BASE_URL = window._config.SERVER_BASE_URL || 'http://localhost:8080';
+
+    // The reset link carries a single-use token
+    const token = new URLSearchParams(window.location.search).get('token');
+    if (!token) {
+        alert('重設密碼連結無效');
+        $('#reset-password-form button').prop('disabled', true);
+    }
+
+    // Set the new password with the token of the reset link
+    $('#reset-password-form').submit(function(e) {
+        e.preventDefault();
+
+        const newPassword = $('#new-password').val();
+        if (newPassword !== $('#confirm-password').val()) {
+            alert('新密碼和確認密碼不一致');
+            return;
+        }
+
+        $.ajax({
+            url: `${SERVER_BASE_URL}/auth/password/reset`,
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({ token: token, new_password: newPassword }),
+            success: function() {
+                alert('密碼已重設，請使用新密碼登入');
+                $('#reset-password-form button').prop('disabled', true);
+            },
+            error: function(xhr) {
+                const message = xhr.responseJSON ? xhr.responseJSON.error : '';
+                alert(`重設密碼失敗 ${message}`);
+            }
+        });
+    });
+});
@@ -15,6 +15,18 @@ $(document).ready(function() {
     let toDate = new Date();
     $('#to-date').val(formatDate(toDate));
 
+    // Currency the total amount is reported in
+    const REPORTING_CURRENCY = 'TWD';
+
+    // Labels of the transaction types
+    const TYPE_LABELS = { purchase: '消費', refund: '退款', reversal: '沖正', adjustment: '調整' };
+
+    // Largest page the server returns
+    const PAGE_SIZE = 500;
+
+    // Dates picked in the form are days in the browser's time zone
+    const TIME_ZONE = Intl.DateTimeFormat().resolvedOptions().timeZone;
+
     // Global variable to store fetched transactions
     let transactionsData = [];
 
@@ -27,19 +39,33 @@ $(document).ready(function() {
         filterTransactions();
     });
 
-    // Fetches all transactions for the customer
+    // Fetches all transactions for the customer, following the page cursors
     function loadTransactions() {
-        $.ajax({
-            url: `${SERVER_BASE_URL}/customers/${customerId}/transactions`,
-            method: 'GET',
-            success: function(transactions) {
-                transactionsData = transactions;
-                displayTransactions(transactionsData);
-            },
-            error: function() {
-                alert('Unable to retrieve transaction records');
+        let transactions = [];
+        function loadPage(cursor) {
+            let params = { currency: REPORTING_CURRENCY, page_size: PAGE_SIZE };
+            if (cursor) {
+                params.cursor = cursor;
             }
-        });
+            $.ajax({
+                url: `${SERVER_BASE_URL}/customers/${customerId}/transactions`,
+                method: 'GET',
+                data: params,
+                success: function(page) {
+                    transactions = transactions.concat(page.items);
+                    if (page.next_cursor) {
+                        loadPage(page.next_cursor);
+                        return;
+                    }
+                    transactionsData = transactions;
+                    displayTransactions(transactionsData);
+                },
+                error: function(xhr) {
+                    alert(xhr.responseJSON && xhr.responseJSON.error || 'Unable to retrieve transaction records');
+                }
+            });
+        }
+        loadPage('');
     }
 
     // Filters transactions by date range and requests additional transactions if needed
@@ -47,9 +73,9 @@ $(document).ready(function() {
         let from = $('#from-date').val();
         let to = $('#to-date').val();
 
-        // Filter the stored data by date
+        // Filter the stored data by date in the browser's time zone
         let filteredData = transactionsData.filter(function(txn) {
-            let txnDate = txn.time.substring(0,10);
+            let txnDate = formatDate(new Date(txn.time));
             return txnDate >= from && txnDate <= to;
         });
 
@@ -59,11 +85,10 @@ $(document).ready(function() {
         $.ajax({
             url: `${SERVER_BASE_URL}/customers/${customerId}/transactions/date`,
             method: 'GET',
-            contentType: 'application/json',
-            data: JSON.stringify({ from: from, to: to }),
-            success: function(newTransactions) {
+            data: { from: from, to: to, tz: TIME_ZONE, currency: REPORTING_CURRENCY, page_size: PAGE_SIZE },
+            success: function(page) {
                 // Update transactionsData with new transactions, avoiding duplicates
-                newTransactions.forEach(function(newTxn) {
+                page.items.forEach(function(newTxn) {
                     let exists = transactionsData.some(function(txn) {
                         return txn.id === newTxn.id;
                     });
@@ -74,14 +99,14 @@ $(document).ready(function() {
 
                 // Filter again with the updated transactionsData
                 let updatedFilteredData = transactionsData.filter(function(txn) {
-                    let txnDate = txn.time.substring(0,10);
+                    let txnDate = formatDate(new Date(txn.time));
                     return txnDate >= from && txnDate <= to;
                 });
 
                 displayTransactions(updatedFilteredData);
             },
-            error: function() {
-                alert('Unable to retrieve transaction records for the selected date range');
+            error: function(xhr) {
+                alert(xhr.responseJSON && xhr.responseJSON.error || 'Unable to retrieve transaction records for the selected date range');
             }
         });
     }
@@ -89,21 +114,40 @@ $(document).ready(function() {
     // Displays a list of transactions in the table and calculates totals
     function displayTransactions(transactions) {
         $('#transaction-table-body').empty();
-        let totalAmount = 0;
+        // Sum in integer cents so the total does not drift
+        let totalCents = 0;
         transactions.forEach(function(txn) {
             $('#transaction-table-body').append(`
                 <tr>
                     <td>${new Date(txn.time).toLocaleString()}</td>
-                    <td>${txn.amount.toFixed(2)}</td>
-                    <td>${txn.sequence}</td>
+                    <td>${TYPE_LABELS[txn.type] || txn.type}</td>
+                    <td>${txn.amount} ${txn.currency}</td>
+                    <td>${txn.sequence || ''}</td>
                 </tr>
             `);
-            totalAmount += txn.amount;
+            // Refunds and reversals take money back from the total
+            let sign = (txn.type === 'refund' || txn.type === 'reversal') ? -1 : 1;
+            totalCents += sign * toCents(txn.converted_amount || txn.amount);
         });
         // Update transaction count and total amount in the UI
         $('#transactions-count').text(`交易總筆數：${transactions.length}`);
-        $('#transactions-totalAmount').text(`交易總金額：${totalAmount.toFixed(2)}`)
-    }    
+        $('#transactions-totalAmount').text(`交易總金額：${formatCents(totalCents)} ${REPORTING_CURRENCY}`)
+    }
+
+    // Converts a decimal amount string such as "12.30" to integer cents
+    function toCents(amount) {
+        let negative = amount.startsWith('-');
+        let parts = amount.replace('-', '').split('.');
+        let cents = parseInt(parts[0], 10) * 100 + parseInt((parts[1] || '0').padEnd(2, '0').substring(0, 2), 10);
+        return negative ? -cents : cents;
+    }
+
+    // Formats integer cents as a decimal string with two places
+    function formatCents(cents) {
+        let sign = cents < 0 ? '-' : '';
+        cents = Math.abs(cents);
+        return `${sign}${Math.floor(cents / 100)}.${String(cents % 100).padStart(2, '0')}`;
+    }
 
     // Formats a Date object as YYYY-MM-DD
     function formatDate(date) {